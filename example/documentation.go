@@ -100,6 +100,8 @@ in only a few lines of code we can get a server started serving gRPC and HTTP (s
 	package main
 
 	import (
+		"log"
+
 		"github.com/go-orion/Orion/example/stringsvc/service"
 		proto "github.com/go-orion/Orion/example/stringsvc/stringproto"
 		"github.com/go-orion/Orion/orion"
@@ -108,7 +110,9 @@ in only a few lines of code we can get a server started serving gRPC and HTTP (s
 	func main() {
 		server := orion.GetDefaultServer("StringService")
 		proto.RegisterStringServiceOrionServer(service.GetFactory(), server)
-		server.Start()
+		if err := server.Start(); err != nil {
+			log.Fatal(err)
+		}
 		server.Wait()
 	}
 
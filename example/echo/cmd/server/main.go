@@ -66,6 +66,8 @@ func main() {
 	proto.RegisterEchoServiceUpperEncoder(server, encoder)
 	proto.RegisterEchoServiceUpperDecoder(server, decoder)
 	proto.RegisterEchoServiceUpperHandler(server, optionsHandler)
-	server.Start()
+	if err := server.Start(); err != nil {
+		log.Fatal(err)
+	}
 	server.Wait()
 }
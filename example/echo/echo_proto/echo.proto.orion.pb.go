@@ -4,7 +4,11 @@
 package echo_proto
 
 import (
+	context "context"
+
 	orion "github.com/go-orion/Orion/orion"
+	orion_client "github.com/go-orion/Orion/orion/client"
+	grpc "google.golang.org/grpc"
 )
 
 // If you see error please update your orion-protoc-gen by running 'go get -u github.com/go-orion/Orion/protoc-gen-orion'
@@ -48,10 +52,67 @@ func RegisterEchoServiceUpperProxyDecoder(svr orion.Server, decoder orion.Decode
 	orion.RegisterDecoder(svr, "EchoService", "UpperProxy", decoder)
 }
 
+//Streams
+
 // RegisterEchoServiceOrionServer registers EchoService to Orion server
-func RegisterEchoServiceOrionServer(srv orion.ServiceFactory, orionServer orion.Server) {
-	orionServer.RegisterService(&_EchoService_serviceDesc, srv)
+// Services need to pass either ServiceFactory or ServiceFactoryV2 implementation
+func RegisterEchoServiceOrionServer(sf interface{}, orionServer orion.Server) error {
+	err := orionServer.RegisterService(&_EchoService_serviceDesc, sf)
+	if err != nil {
+		return err
+	}
 
 	RegisterEchoServiceUpperEncoder(orionServer, nil)
 	RegisterEchoServiceUpperProxyEncoder(orionServer, nil)
+	return nil
+}
+
+// DefaultEncoder
+func RegisterEchoServiceDefaultEncoder(svr orion.Server, encoder orion.Encoder) {
+	orion.RegisterDefaultEncoder(svr, "EchoService", encoder)
+}
+
+// DefaultDecoder
+func RegisterEchoServiceDefaultDecoder(svr orion.Server, decoder orion.Decoder) {
+	orion.RegisterDefaultDecoder(svr, "EchoService", decoder)
+}
+
+// Client
+type orionEchoServiceClient struct {
+	inv orion_client.Invoker
+}
+
+// NewEchoServiceOrionClient creates a EchoServiceClient calling the service through the invoker,
+// the invoker returned by orion.GetClientInvoker uses the transport configured for the service
+func NewEchoServiceOrionClient(inv orion_client.Invoker) EchoServiceClient {
+	return &orionEchoServiceClient{inv}
+}
+
+func (c *orionEchoServiceClient) Echo(ctx context.Context, in *EchoRequest, opts ...grpc.CallOption) (*EchoResponse, error) {
+	out := new(EchoResponse)
+	err := c.inv.Invoke(ctx, "/echo_proto.EchoService/Echo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orionEchoServiceClient) Upper(ctx context.Context, in *UpperRequest, opts ...grpc.CallOption) (*UpperResponse, error) {
+	out := new(UpperResponse)
+	opts = append([]grpc.CallOption{orion_client.HTTPRoute("POST", "/api/1.0/upper/{msg}")}, opts...)
+	err := c.inv.Invoke(ctx, "/echo_proto.EchoService/Upper", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orionEchoServiceClient) UpperProxy(ctx context.Context, in *UpperRequest, opts ...grpc.CallOption) (*UpperResponse, error) {
+	out := new(UpperResponse)
+	opts = append([]grpc.CallOption{orion_client.HTTPRoute("POST", "")}, opts...)
+	err := c.inv.Invoke(ctx, "/echo_proto.EchoService/UpperProxy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
@@ -11,11 +11,11 @@ import (
 
 	proto "github.com/go-orion/Orion/example/echo/echo_proto"
 	"github.com/go-orion/Orion/interceptors"
+	"github.com/go-orion/Orion/orion/client"
 	"github.com/go-orion/Orion/utils/headers"
 	"github.com/go-orion/Orion/utils/spanutils"
 	"github.com/go-orion/Orion/utils/worker"
 	"github.com/gorilla/mux"
-	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"google.golang.org/grpc"
 )
 
@@ -43,7 +43,7 @@ func GetService(config Config) proto.EchoServiceServer {
 	s := new(svc)
 	s.appendText = config.AppendText
 	s.debug = config.Debug
-	conn, err := grpc.Dial(address, grpc.WithInsecure(), grpc.WithUnaryInterceptor(grpc_middleware.ChainUnaryClient(interceptors.DefaultClientInterceptors(address)...)))
+	conn, err := client.Dial("echo", client.Config{Addresses: []string{address}})
 	if err != nil {
 		log.Fatalln("did not connect: %v", err)
 	}
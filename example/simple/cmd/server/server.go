@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"log"
 
 	"github.com/go-orion/Orion/example/simple/service"
 	proto "github.com/go-orion/Orion/example/simple/simple_proto"
@@ -22,6 +23,8 @@ func (s *svcFactory) DisposeService(svc interface{}) {
 func main() {
 	server := orion.GetDefaultServer("SimpleService")
 	proto.RegisterSimpleServiceOrionServer(&svcFactory{}, server)
-	server.Start()
+	if err := server.Start(); err != nil {
+		log.Fatal(err)
+	}
 	server.Wait()
 }
@@ -4,7 +4,11 @@
 package simple_proto
 
 import (
+	context "context"
+
 	orion "github.com/go-orion/Orion/orion"
+	orion_client "github.com/go-orion/Orion/orion/client"
+	grpc "google.golang.org/grpc"
 )
 
 // If you see error please update your orion-protoc-gen by running 'go get -u github.com/go-orion/Orion/protoc-gen-orion'
@@ -16,8 +20,45 @@ var _ = orion.ProtoGenVersion1_0
 
 // Decoders
 
+//Streams
+
 // RegisterSimpleServiceOrionServer registers SimpleService to Orion server
-func RegisterSimpleServiceOrionServer(srv orion.ServiceFactory, orionServer orion.Server) {
-	orionServer.RegisterService(&_SimpleService_serviceDesc, srv)
+// Services need to pass either ServiceFactory or ServiceFactoryV2 implementation
+func RegisterSimpleServiceOrionServer(sf interface{}, orionServer orion.Server) error {
+	err := orionServer.RegisterService(&_SimpleService_serviceDesc, sf)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DefaultEncoder
+func RegisterSimpleServiceDefaultEncoder(svr orion.Server, encoder orion.Encoder) {
+	orion.RegisterDefaultEncoder(svr, "SimpleService", encoder)
+}
+
+// DefaultDecoder
+func RegisterSimpleServiceDefaultDecoder(svr orion.Server, decoder orion.Decoder) {
+	orion.RegisterDefaultDecoder(svr, "SimpleService", decoder)
+}
+
+// Client
+type orionSimpleServiceClient struct {
+	inv orion_client.Invoker
+}
+
+// NewSimpleServiceOrionClient creates a SimpleServiceClient calling the service through the invoker,
+// the invoker returned by orion.GetClientInvoker uses the transport configured for the service
+func NewSimpleServiceOrionClient(inv orion_client.Invoker) SimpleServiceClient {
+	return &orionSimpleServiceClient{inv}
+}
 
+func (c *orionSimpleServiceClient) Echo(ctx context.Context, in *EchoRequest, opts ...grpc.CallOption) (*EchoResponse, error) {
+	out := new(EchoResponse)
+	err := c.inv.Invoke(ctx, "/simple_proto.SimpleService/Echo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
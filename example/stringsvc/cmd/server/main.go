@@ -1,6 +1,8 @@
 package main
 
 import (
+	"log"
+
 	"github.com/go-orion/Orion/example/stringsvc/service"
 	proto "github.com/go-orion/Orion/example/stringsvc/stringproto"
 	"github.com/go-orion/Orion/orion"
@@ -9,6 +11,8 @@ import (
 func main() {
 	server := orion.GetDefaultServer("StringService")
 	proto.RegisterStringServiceOrionServer(service.GetFactory(), server)
-	server.Start()
+	if err := server.Start(); err != nil {
+		log.Fatal(err)
+	}
 	server.Wait()
 }
@@ -4,7 +4,11 @@
 package stringproto
 
 import (
+	context "context"
+
 	orion "github.com/go-orion/Orion/orion"
+	orion_client "github.com/go-orion/Orion/orion/client"
+	grpc "google.golang.org/grpc"
 )
 
 // If you see error please update your orion-protoc-gen by running 'go get -u github.com/go-orion/Orion/protoc-gen-orion'
@@ -16,8 +20,54 @@ var _ = orion.ProtoGenVersion1_0
 
 // Decoders
 
+//Streams
+
 // RegisterStringServiceOrionServer registers StringService to Orion server
-func RegisterStringServiceOrionServer(srv orion.ServiceFactory, orionServer orion.Server) {
-	orionServer.RegisterService(&_StringService_serviceDesc, srv)
+// Services need to pass either ServiceFactory or ServiceFactoryV2 implementation
+func RegisterStringServiceOrionServer(sf interface{}, orionServer orion.Server) error {
+	err := orionServer.RegisterService(&_StringService_serviceDesc, sf)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DefaultEncoder
+func RegisterStringServiceDefaultEncoder(svr orion.Server, encoder orion.Encoder) {
+	orion.RegisterDefaultEncoder(svr, "StringService", encoder)
+}
+
+// DefaultDecoder
+func RegisterStringServiceDefaultDecoder(svr orion.Server, decoder orion.Decoder) {
+	orion.RegisterDefaultDecoder(svr, "StringService", decoder)
+}
+
+// Client
+type orionStringServiceClient struct {
+	inv orion_client.Invoker
+}
+
+// NewStringServiceOrionClient creates a StringServiceClient calling the service through the invoker,
+// the invoker returned by orion.GetClientInvoker uses the transport configured for the service
+func NewStringServiceOrionClient(inv orion_client.Invoker) StringServiceClient {
+	return &orionStringServiceClient{inv}
+}
+
+func (c *orionStringServiceClient) Upper(ctx context.Context, in *UpperRequest, opts ...grpc.CallOption) (*UpperResponse, error) {
+	out := new(UpperResponse)
+	err := c.inv.Invoke(ctx, "/stringproto.StringService/Upper", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
 
+func (c *orionStringServiceClient) Count(ctx context.Context, in *CountRequest, opts ...grpc.CallOption) (*CountResponse, error) {
+	out := new(CountResponse)
+	err := c.inv.Invoke(ctx, "/stringproto.StringService/Count", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
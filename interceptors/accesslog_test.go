@@ -0,0 +1,211 @@
+package interceptors
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-orion/Orion/orion/modifiers"
+	"github.com/go-orion/Orion/utils/accesslog"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// recordingSink sends the entries it writes to the channel
+type recordingSink chan *accesslog.Entry
+
+func (s recordingSink) Write(e *accesslog.Entry) error {
+	s <- e
+	return nil
+}
+
+func (s recordingSink) next(t *testing.T) *accesslog.Entry {
+	select {
+	case e := <-s:
+		return e
+	case <-time.After(time.Second):
+		t.Error("no access log entry was written")
+		return &accesslog.Entry{}
+	}
+}
+
+var accessLogServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.Service",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Upper",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(wrappers.StringValue)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					value := req.(*wrappers.StringValue).GetValue()
+					if value == "fail" {
+						return nil, status.Error(codes.InvalidArgument, "cannot upper fail")
+					}
+					return &wrappers.StringValue{Value: strings.ToUpper(value)}, nil
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/test.Service/Upper"}, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Split",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				in := new(wrappers.StringValue)
+				if err := stream.RecvMsg(in); err != nil {
+					return err
+				}
+				for _, word := range strings.Fields(in.GetValue()) {
+					if word == "fail" {
+						return status.Error(codes.Aborted, "cannot split fail")
+					}
+					if err := stream.SendMsg(&wrappers.StringValue{Value: word}); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			ServerStreams: true,
+		},
+	},
+}
+
+// startAccessLogServer serves accessLogServiceDesc with the access log interceptors followed by interceptors recording
+// the principal of the calls, it returns a connection to the server
+func startAccessLogServer(t *testing.T) *grpc.ClientConn {
+	principal := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		modifiers.SetPrincipal(ctx, "tester")
+		return handler(ctx, req)
+	}
+	streamPrincipal := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		modifiers.SetPrincipal(ss.Context(), "tester")
+		return handler(srv, ss)
+	}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(AccessLogInterceptor(), principal)),
+		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(AccessLogStreamInterceptor(), streamPrincipal)),
+	)
+	server.RegisterService(&accessLogServiceDesc, struct{}{})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(lis)
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure(), grpc.WithUserAgent("access-log-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+	return conn
+}
+
+func TestAccessLogInterceptor(t *testing.T) {
+	sink := make(recordingSink, 10)
+	_, proxy, _ := net.ParseCIDR("127.0.0.1/32")
+	accesslog.SetDefault(&accesslog.Logger{Sink: sink, TrustedProxies: []*net.IPNet{proxy}})
+	defer accesslog.SetDefault(nil)
+	conn := startAccessLogServer(t)
+
+	tests := []struct {
+		value string
+		code  codes.Code
+	}{
+		{"hello", codes.OK},
+		{"fail", codes.InvalidArgument},
+	}
+	for _, test := range tests {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-forwarded-for", "203.0.113.1")
+		req, resp := &wrappers.StringValue{Value: test.value}, new(wrappers.StringValue)
+		err := conn.Invoke(ctx, "/test.Service/Upper", req, resp)
+		assert.Equal(t, test.code, status.Code(err), test.value)
+
+		entry := sink.next(t)
+		assert.Equal(t, "grpc", entry.Transport, test.value)
+		assert.Empty(t, entry.Method, test.value)
+		assert.Equal(t, "/test.Service/Upper", entry.Route, test.value)
+		assert.Equal(t, "/test.Service/Upper", entry.Path, test.value)
+		assert.Equal(t, test.code.String(), entry.Code, test.value)
+		assert.Equal(t, int64(proto.Size(req)), entry.BytesIn, test.value)
+		if test.code == codes.OK {
+			assert.Equal(t, int64(proto.Size(resp)), entry.BytesOut, test.value)
+			assert.NoError(t, entry.Error, test.value)
+		} else {
+			assert.Zero(t, entry.BytesOut, test.value)
+			assert.Error(t, entry.Error, test.value)
+		}
+		assert.Equal(t, "tester", entry.Principal, test.value)
+		assert.Equal(t, "203.0.113.1", entry.ClientIP, test.value)
+		assert.Contains(t, entry.UserAgent, "access-log-test", test.value)
+		assert.Equal(t, test.code != codes.OK, entry.IsError(), test.value)
+	}
+}
+
+func TestAccessLogStreamInterceptor(t *testing.T) {
+	sink := make(recordingSink, 10)
+	accesslog.SetDefault(&accesslog.Logger{Sink: sink})
+	defer accesslog.SetDefault(nil)
+	conn := startAccessLogServer(t)
+
+	tests := []struct {
+		value string
+		code  codes.Code
+	}{
+		{"a b c", codes.OK},
+		{"a fail", codes.Aborted},
+	}
+	for _, test := range tests {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-forwarded-for", "203.0.113.1")
+		stream, err := conn.NewStream(ctx, &accessLogServiceDesc.Streams[0], "/test.Service/Split")
+		if !assert.NoError(t, err, test.value) {
+			continue
+		}
+		assert.NoError(t, stream.SendMsg(&wrappers.StringValue{Value: test.value}))
+		assert.NoError(t, stream.CloseSend())
+		for err == nil {
+			err = stream.RecvMsg(new(wrappers.StringValue))
+		}
+		if test.code == codes.OK {
+			assert.Equal(t, io.EOF, err, test.value)
+		} else {
+			assert.Equal(t, test.code, status.Code(err), test.value)
+		}
+
+		entry := sink.next(t)
+		assert.Equal(t, "grpc", entry.Transport, test.value)
+		assert.Equal(t, "/test.Service/Split", entry.Route, test.value)
+		assert.Equal(t, test.code.String(), entry.Code, test.value)
+		assert.Equal(t, "tester", entry.Principal, test.value)
+		assert.Equal(t, "127.0.0.1", entry.ClientIP, "forwarding headers should be ignored without trusted proxies")
+		assert.Equal(t, test.code != codes.OK, entry.IsError(), test.value)
+	}
+}
+
+func TestAccessLogInterceptorSampler(t *testing.T) {
+	sink := make(recordingSink, 10)
+	accesslog.SetDefault(&accesslog.Logger{Sink: sink, Sampler: accesslog.ErrorsAndRate(0)})
+	defer accesslog.SetDefault(nil)
+	conn := startAccessLogServer(t)
+
+	for _, value := range []string{"hello", "fail"} {
+		conn.Invoke(context.Background(), "/test.Service/Upper", &wrappers.StringValue{Value: value}, new(wrappers.StringValue))
+	}
+	// entries are written before the response is sent, the successful call is not sampled
+	assert.Len(t, sink, 1)
+	assert.Equal(t, codes.InvalidArgument.String(), sink.next(t).Code)
+}
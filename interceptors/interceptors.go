@@ -3,22 +3,29 @@ package interceptors
 import (
 	"context"
 	"fmt"
+	"net"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/afex/hystrix-go/hystrix"
 	"github.com/go-orion/Orion/orion/modifiers"
 	"github.com/go-orion/Orion/utils"
+	"github.com/go-orion/Orion/utils/accesslog"
 	"github.com/go-orion/Orion/utils/errors"
 	"github.com/go-orion/Orion/utils/errors/notifier"
 	"github.com/go-orion/Orion/utils/log"
 	"github.com/go-orion/Orion/utils/log/loggers"
+	"github.com/golang/protobuf/proto"
 	"github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/grpc-ecosystem/go-grpc-middleware/tags"
 	grpc_opentracing "github.com/grpc-ecosystem/go-grpc-middleware/tracing/opentracing"
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	newrelic "github.com/newrelic/go-agent"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -41,6 +48,7 @@ func DefaultInterceptors() []grpc.UnaryServerInterceptor {
 		ResponseTimeLoggingInterceptor(),
 		grpc_ctxtags.UnaryServerInterceptor(),
 		grpc_opentracing.UnaryServerInterceptor(grpc_opentracing.WithFilterFunc(filterFromZipkin)),
+		AccessLogInterceptor(),
 		grpc_prometheus.UnaryServerInterceptor,
 		ServerErrorInterceptor(),
 		NewRelicInterceptor(),
@@ -61,6 +69,7 @@ func DefaultStreamInterceptors() []grpc.StreamServerInterceptor {
 	return []grpc.StreamServerInterceptor{
 		grpc_ctxtags.StreamServerInterceptor(),
 		grpc_opentracing.StreamServerInterceptor(),
+		AccessLogStreamInterceptor(),
 		grpc_prometheus.StreamServerInterceptor,
 	}
 }
@@ -86,7 +95,12 @@ func ResponseTimeLoggingInterceptor() grpc.UnaryServerInterceptor {
 		// dont log for HTTP request, let HTTP Handler manage it
 		if !modifiers.IsHTTPRequest(ctx) {
 			defer func(begin time.Time) {
-				log.Info(ctx, "method", info.FullMethod, "error", err, "took", time.Since(begin))
+				if accesslog.Enabled() {
+					// requests are recorded by the access log
+					log.Debug(ctx, "method", info.FullMethod, "error", err, "took", time.Since(begin))
+				} else {
+					log.Info(ctx, "method", info.FullMethod, "error", err, "took", time.Since(begin))
+				}
 			}(time.Now())
 		}
 		resp, err = handler(ctx, req)
@@ -94,6 +108,99 @@ func ResponseTimeLoggingInterceptor() grpc.UnaryServerInterceptor {
 	}
 }
 
+// AccessLogInterceptor writes access log entries for gRPC calls and annotates the entries of HTTP requests
+func AccessLogInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		if entry := accesslog.FromContext(ctx); entry != nil {
+			// HTTP requests are logged by the HTTP handler
+			annotateEntry(ctx, entry)
+			resp, err = handler(ctx, req)
+			entry.Code = status.Code(err).String()
+			return resp, err
+		}
+		logger := accesslog.Default()
+		if logger == nil || modifiers.IsHTTPRequest(ctx) {
+			return handler(ctx, req)
+		}
+		entry := newGRPCEntry(ctx, info.FullMethod, logger.TrustedProxies)
+		if msg, ok := req.(proto.Message); ok {
+			entry.BytesIn = int64(proto.Size(msg))
+		}
+		defer func() {
+			entry.Duration = time.Since(entry.Time)
+			entry.Code, entry.Error = status.Code(err).String(), err
+			if msg, ok := resp.(proto.Message); ok && err == nil {
+				entry.BytesOut = int64(proto.Size(msg))
+			}
+			logger.Log(entry)
+		}()
+		return handler(accesslog.NewContext(ctx, entry), req)
+	}
+}
+
+// AccessLogStreamInterceptor writes access log entries for gRPC streams and annotates the entries of HTTP requests
+func AccessLogStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx := ss.Context()
+		if entry := accesslog.FromContext(ctx); entry != nil {
+			// HTTP requests are logged by the HTTP handler
+			annotateEntry(ctx, entry)
+			err = handler(srv, ss)
+			entry.Code = status.Code(err).String()
+			return err
+		}
+		logger := accesslog.Default()
+		if logger == nil {
+			return handler(srv, ss)
+		}
+		entry := newGRPCEntry(ctx, info.FullMethod, logger.TrustedProxies)
+		defer func() {
+			entry.Duration = time.Since(entry.Time)
+			entry.Code, entry.Error = status.Code(err).String(), err
+			logger.Log(entry)
+		}()
+		return handler(srv, &accessLogStream{ServerStream: ss, ctx: accesslog.NewContext(ctx, entry)})
+	}
+}
+
+type accessLogStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *accessLogStream) Context() context.Context {
+	return s.ctx
+}
+
+func annotateEntry(ctx context.Context, entry *accesslog.Entry) {
+	if traceID := accesslog.TraceID(ctx); traceID != "" {
+		entry.TraceID = traceID
+	}
+}
+
+func newGRPCEntry(ctx context.Context, method string, trustedProxies []*net.IPNet) *accesslog.Entry {
+	entry := &accesslog.Entry{
+		Time:      time.Now(),
+		Transport: "grpc",
+		Route:     method,
+		Path:      method,
+		TraceID:   accesslog.TraceID(ctx),
+	}
+	hdr := http.Header{}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			hdr[http.CanonicalHeaderKey(key)] = values
+		}
+	}
+	remoteAddr := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+	entry.ClientIP = accesslog.ClientIP(hdr, remoteAddr, trustedProxies)
+	entry.UserAgent = hdr.Get("User-Agent")
+	return entry
+}
+
 //NewRelicInterceptor intercepts all server actions and reports them to newrelic
 func NewRelicInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
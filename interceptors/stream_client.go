@@ -0,0 +1,312 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/afex/hystrix-go/hystrix"
+	"github.com/go-orion/Orion/utils"
+	"github.com/go-orion/Orion/utils/errors"
+	"github.com/go-orion/Orion/utils/errors/notifier"
+	"github.com/go-orion/Orion/utils/log"
+	"github.com/grpc-ecosystem/go-grpc-middleware"
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	newrelic "github.com/newrelic/go-agent"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+//DefaultStreamClientInterceptors are the set of default interceptors that should be applied to all client streams
+func DefaultStreamClientInterceptors(address string) []grpc.StreamClientInterceptor {
+	return []grpc.StreamClientInterceptor{
+		GRPCStreamClientInterceptor(),
+		NewRelicStreamClientInterceptor(address),
+		grpc_prometheus.StreamClientInterceptor,
+		StreamClientErrorInterceptor(),
+		HystrixStreamClientInterceptor(),
+	}
+}
+
+//DefaultStreamClientInterceptor are the set of default interceptors that should be applied to all client streams
+func DefaultStreamClientInterceptor(address string) grpc.StreamClientInterceptor {
+	return grpc_middleware.ChainStreamClient(DefaultStreamClientInterceptors(address)...)
+}
+
+//GRPCStreamClientInterceptor adds tracing info to client streams, the span covers the lifetime of the stream
+//and is tagged with the number of messages sent and received
+func GRPCStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		var parent opentracing.SpanContext
+		if span := opentracing.SpanFromContext(ctx); span != nil {
+			parent = span.Context()
+		}
+		tracer := opentracing.GlobalTracer()
+		span := tracer.StartSpan(method, opentracing.ChildOf(parent), ext.SpanKindRPCClient, opentracing.Tag{Key: "component", Value: "gRPC"})
+		// propagate the span to the server
+		md, ok := metadata.FromOutgoingContext(ctx)
+		if ok {
+			md = md.Copy()
+		} else {
+			md = metadata.MD{}
+		}
+		if err := tracer.Inject(span.Context(), opentracing.HTTPHeaders, mdCarrier(md)); err != nil {
+			log.Info(ctx, "err", err, "component", "interceptors")
+		}
+		ctx = opentracing.ContextWithSpan(metadata.NewOutgoingContext(ctx, md), span)
+
+		ctx, observe := observeClientStream(ctx, desc, func(sent, received int64, err error) {
+			span.SetTag("messages.sent", sent)
+			span.SetTag("messages.received", received)
+			if err != nil {
+				ext.Error.Set(span, true)
+				span.SetTag("error.message", err.Error())
+			}
+			span.Finish()
+		})
+		return observe(streamer(ctx, desc, cc, method, opts...))
+	}
+}
+
+//NewRelicStreamClientInterceptor reports client streams to newrelic as external segments that cover the lifetime of the stream
+func NewRelicStreamClientInterceptor(address string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		txn := utils.GetNewRelicTransactionFromContext(ctx)
+		seg := newrelic.ExternalSegment{
+			StartTime: newrelic.StartSegmentNow(txn),
+			URL:       "http://" + address + "/" + method,
+		}
+		ctx, observe := observeClientStream(ctx, desc, func(int64, int64, error) {
+			seg.End()
+		})
+		return observe(streamer(ctx, desc, cc, method, opts...))
+	}
+}
+
+//StreamClientErrorInterceptor reports the errors of client streams to error notifier
+func StreamClientErrorInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		streamCtx, observe := observeClientStream(ctx, desc, func(_, _ int64, err error) {
+			// streams cancelled by the caller are not errors
+			if err != nil && status.Code(err) != codes.Canceled {
+				notifier.Notify(err, ctx, "method", method)
+			}
+		})
+		return observe(streamer(streamCtx, desc, cc, method, opts...))
+	}
+}
+
+//HystrixStreamClientInterceptor runs the establishment of client streams in hystrix, messages are not covered by hystrix
+func HystrixStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		options := clientOptions{
+			hystrixName: method,
+		}
+		for _, opt := range opts {
+			if opt != nil {
+				if o, ok := opt.(clientOption); ok {
+					o.process(&options)
+				}
+			}
+		}
+		// the stream is cancelled when hystrix gives up on it, or when it ends
+		newCtx, cancel := context.WithCancel(ctx)
+		newCtx, observe := observeClientStream(newCtx, desc, func(int64, int64, error) {
+			cancel()
+		})
+		var mu sync.Mutex
+		var stream grpc.ClientStream
+		abandoned := false
+		err := hystrix.Do(options.hystrixName, func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = errors.Wrap(fmt.Errorf("Panic inside hystrix Method: %s", method), "Hystrix")
+					log.Error(ctx, "panic", r, "method", method)
+				}
+			}()
+			defer notifier.NotifyOnPanic(newCtx, method)
+			s, err := streamer(newCtx, desc, cc, method, opts...)
+			mu.Lock()
+			defer mu.Unlock()
+			if abandoned && s != nil {
+				// hystrix has timed out, the caller does not get this stream
+				cancel()
+			}
+			stream = s
+			return err
+		}, nil)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			abandoned = true
+			cancel()
+			return observe(nil, err)
+		}
+		return observe(stream, nil)
+	}
+}
+
+type streamObserverKey struct{}
+
+// streamObserver collects the finish callbacks of the interceptors of a call, so that the stream is wrapped once
+// by the outermost interceptor
+type streamObserver struct {
+	mu       sync.Mutex
+	finishes []func(sent, received int64, err error)
+	wrapped  bool
+}
+
+func (o *streamObserver) add(finish func(sent, received int64, err error)) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.wrapped {
+		// the context belongs to a stream that has been returned already, e.g. the context of ClientStream.Context
+		return false
+	}
+	o.finishes = append(o.finishes, finish)
+	return true
+}
+
+// seal returns the finish callbacks in the order they are called, inner interceptors first
+func (o *streamObserver) seal() []func(sent, received int64, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.wrapped = true
+	finishes := make([]func(sent, received int64, err error), 0, len(o.finishes))
+	for i := len(o.finishes) - 1; i >= 0; i-- {
+		finishes = append(finishes, o.finishes[i])
+	}
+	return finishes
+}
+
+// observeClientStream registers finish to be called once when the stream of the call ends, with the number of
+// messages sent and received. The returned context has to be passed to the streamer and its result to observe, the
+// outermost interceptor of the call wraps the stream and the inner ones return it as is
+func observeClientStream(ctx context.Context, desc *grpc.StreamDesc, finish func(sent, received int64, err error)) (context.Context, func(grpc.ClientStream, error) (grpc.ClientStream, error)) {
+	if o, ok := ctx.Value(streamObserverKey{}).(*streamObserver); ok && o.add(finish) {
+		return ctx, func(stream grpc.ClientStream, err error) (grpc.ClientStream, error) {
+			return stream, err
+		}
+	}
+	o := &streamObserver{finishes: []func(sent, received int64, err error){finish}}
+	ctx = context.WithValue(ctx, streamObserverKey{}, o)
+	return ctx, func(stream grpc.ClientStream, err error) (grpc.ClientStream, error) {
+		finishes := o.seal()
+		if err != nil {
+			for _, finish := range finishes {
+				finish(0, 0, err)
+			}
+			return nil, err
+		}
+		return newObservedClientStream(ctx, desc, stream, finishes), nil
+	}
+}
+
+// observedClientStream calls the finish callbacks once when the stream ends, with the number of messages sent and received
+type observedClientStream struct {
+	grpc.ClientStream
+	desc     *grpc.StreamDesc
+	finishes []func(sent, received int64, err error)
+	once     sync.Once
+	done     chan struct{}
+	sent     int64
+	received int64
+}
+
+// newObservedClientStream wraps stream so that the finish callbacks are called when it ends, streams end when a
+// message or an error is received, or when ctx is done
+func newObservedClientStream(ctx context.Context, desc *grpc.StreamDesc, stream grpc.ClientStream, finishes []func(sent, received int64, err error)) grpc.ClientStream {
+	s := &observedClientStream{
+		ClientStream: stream,
+		desc:         desc,
+		finishes:     finishes,
+		done:         make(chan struct{}),
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.end(status.FromContextError(ctx.Err()).Err())
+		case <-s.done:
+		}
+	}()
+	return s
+}
+
+func (s *observedClientStream) end(err error) {
+	s.once.Do(func() {
+		close(s.done)
+		sent, received := atomic.LoadInt64(&s.sent), atomic.LoadInt64(&s.received)
+		for _, finish := range s.finishes {
+			finish(sent, received, err)
+		}
+	})
+}
+
+func (s *observedClientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.end(err)
+	}
+	return md, err
+}
+
+func (s *observedClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.sent, 1)
+	} else if err != io.EOF {
+		// io.EOF means the stream has ended, its status is returned by RecvMsg
+		s.end(err)
+	}
+	return err
+}
+
+func (s *observedClientStream) CloseSend() error {
+	err := s.ClientStream.CloseSend()
+	if err != nil {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *observedClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		atomic.AddInt64(&s.received, 1)
+		if !s.desc.ServerStreams {
+			// streams with a single response end with it
+			s.end(nil)
+		}
+	case err == io.EOF:
+		s.end(nil)
+	default:
+		s.end(err)
+	}
+	return err
+}
+
+// mdCarrier injects span contexts in outgoing gRPC metadata
+type mdCarrier metadata.MD
+
+func (m mdCarrier) Set(key, val string) {
+	m[strings.ToLower(key)] = []string{val}
+}
+
+func (m mdCarrier) ForeachKey(handler func(key, val string) error) error {
+	for k, vals := range m {
+		for _, v := range vals {
+			if err := handler(k, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
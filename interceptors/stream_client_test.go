@@ -0,0 +1,284 @@
+package interceptors
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/afex/hystrix-go/hystrix"
+	"github.com/grpc-ecosystem/go-grpc-middleware"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testTracer records the tags of the spans it starts
+type testTracer struct {
+	opentracing.NoopTracer
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := &testSpan{Span: t.NoopTracer.StartSpan(operationName), tags: make(map[string]interface{})}
+	t.spans = append(t.spans, span)
+	return span
+}
+
+type testSpan struct {
+	opentracing.Span
+	mu       sync.Mutex
+	tags     map[string]interface{}
+	finished int
+}
+
+func (s *testSpan) SetTag(key string, value interface{}) opentracing.Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tags[key] = value
+	return s
+}
+
+func (s *testSpan) Finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finished++
+}
+
+func (s *testSpan) result() (map[string]interface{}, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tags, s.finished
+}
+
+// testClientStream answers RecvMsg with the results in recv, followed by io.EOF
+type testClientStream struct {
+	grpc.ClientStream
+	ctx  context.Context
+	recv []error
+}
+
+func (s *testClientStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testClientStream) SendMsg(m interface{}) error {
+	return nil
+}
+
+func (s *testClientStream) CloseSend() error {
+	return nil
+}
+
+func (s *testClientStream) RecvMsg(m interface{}) error {
+	if len(s.recv) == 0 {
+		return io.EOF
+	}
+	err := s.recv[0]
+	s.recv = s.recv[1:]
+	return err
+}
+
+func testStreamer(recv ...error) grpc.Streamer {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &testClientStream{ctx: ctx, recv: recv}, nil
+	}
+}
+
+func failingStreamer(err error) grpc.Streamer {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return nil, err
+	}
+}
+
+// drain sends messages and receives until the stream ends
+func drain(stream grpc.ClientStream, messages int) error {
+	for i := 0; i < messages; i++ {
+		stream.SendMsg(nil)
+	}
+	stream.CloseSend()
+	for {
+		if err := stream.RecvMsg(nil); err != nil {
+			return err
+		}
+	}
+}
+
+// testNotifyError records whether it has been sent to the notifier
+type testNotifyError struct {
+	code     codes.Code
+	mu       sync.Mutex
+	notified bool
+}
+
+func (e *testNotifyError) Error() string {
+	return e.code.String()
+}
+
+func (e *testNotifyError) GRPCStatus() *status.Status {
+	return status.New(e.code, e.code.String())
+}
+
+func (e *testNotifyError) ShouldNotify() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !e.notified
+}
+
+func (e *testNotifyError) Notified(notified bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.notified = notified
+}
+
+func (e *testNotifyError) wasNotified() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.notified
+}
+
+func TestGRPCStreamClientInterceptor(t *testing.T) {
+	tracer := &testTracer{}
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	failed := status.Error(codes.Internal, "failed")
+	tests := []struct {
+		name     string
+		desc     *grpc.StreamDesc
+		streamer grpc.Streamer
+		sent     int
+		tags     map[string]interface{}
+	}{
+		{"server stream", &grpc.StreamDesc{ServerStreams: true}, testStreamer(nil, nil, nil), 1,
+			map[string]interface{}{"messages.sent": int64(1), "messages.received": int64(3)}},
+		{"client stream", &grpc.StreamDesc{ClientStreams: true}, testStreamer(nil), 4,
+			map[string]interface{}{"messages.sent": int64(4), "messages.received": int64(1)}},
+		{"failed stream", &grpc.StreamDesc{ServerStreams: true}, testStreamer(nil, failed), 1,
+			map[string]interface{}{"messages.sent": int64(1), "messages.received": int64(1), "error": true, "error.message": failed.Error()}},
+		{"failed to start", &grpc.StreamDesc{ServerStreams: true}, failingStreamer(failed), 0,
+			map[string]interface{}{"messages.sent": int64(0), "messages.received": int64(0), "error": true, "error.message": failed.Error()}},
+	}
+	for i, test := range tests {
+		stream, err := GRPCStreamClientInterceptor()(context.Background(), test.desc, nil, "/test.Service/Stream", test.streamer)
+		if err == nil {
+			drain(stream, test.sent)
+		}
+		if !assert.Len(t, tracer.spans, i+1, test.name) {
+			return
+		}
+		tags, finished := tracer.spans[i].result()
+		assert.Equal(t, 1, finished, "%s: the span should be finished once", test.name)
+		for key, value := range test.tags {
+			assert.Equal(t, value, tags[key], "%s: %s", test.name, key)
+		}
+		assert.Equal(t, test.tags["error"], tags["error"], test.name)
+	}
+}
+
+func TestStreamClientErrorInterceptor(t *testing.T) {
+	desc := &grpc.StreamDesc{ServerStreams: true}
+	tests := []struct {
+		code     codes.Code
+		notified bool
+	}{
+		{codes.Internal, true},
+		{codes.Unavailable, true},
+		// streams cancelled by the caller are not errors
+		{codes.Canceled, false},
+	}
+	for _, test := range tests {
+		err := &testNotifyError{code: test.code}
+		stream, _ := StreamClientErrorInterceptor()(context.Background(), desc, nil, "/test.Service/Stream", testStreamer(nil, err))
+		assert.Equal(t, err, drain(stream, 1))
+		assert.Equal(t, test.notified, err.wasNotified(), "%s stream", test.code)
+
+		err = &testNotifyError{code: test.code}
+		_, started := StreamClientErrorInterceptor()(context.Background(), desc, nil, "/test.Service/Stream", failingStreamer(err))
+		assert.Equal(t, err, started)
+		assert.Equal(t, test.notified, err.wasNotified(), "%s stream that could not be started", test.code)
+	}
+
+	// streams cancelled by the caller end with the status of the context
+	tracer := &testTracer{}
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := GRPCStreamClientInterceptor()(ctx, desc, nil, "/test.Service/Stream", testStreamer())
+	assert.NoError(t, err)
+	cancel()
+	for i := 0; i < 100; i++ {
+		if _, finished := tracer.spans[0].result(); finished > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	tags, finished := tracer.spans[0].result()
+	assert.Equal(t, 1, finished, "cancelled streams should be finished")
+	assert.Equal(t, status.Error(codes.Canceled, context.Canceled.Error()).Error(), tags["error.message"])
+}
+
+func TestHystrixStreamClientInterceptorTimeout(t *testing.T) {
+	method := "/test.Service/SlowStream"
+	hystrix.ConfigureCommand(method, hystrix.CommandConfig{Timeout: 10})
+	release := make(chan struct{})
+	streamCtx := make(chan context.Context, 1)
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		<-release
+		streamCtx <- ctx
+		return &testClientStream{ctx: ctx}, nil
+	}
+	stream, err := HystrixStreamClientInterceptor()(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, method, streamer)
+	assert.Nil(t, stream)
+	assert.Equal(t, hystrix.ErrTimeout, err)
+
+	// the stream established after hystrix gave up is cancelled, nobody would ever close it
+	close(release)
+	select {
+	case <-(<-streamCtx).Done():
+	case <-time.After(time.Second):
+		t.Error("the abandoned stream should be cancelled")
+	}
+}
+
+func TestStreamClientInterceptorsWrapOnce(t *testing.T) {
+	tracer := &testTracer{}
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	var streamCtx context.Context
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		streamCtx = ctx
+		return &testClientStream{ctx: ctx, recv: []error{nil, nil}}, nil
+	}
+	chain := grpc_middleware.ChainStreamClient(GRPCStreamClientInterceptor(), StreamClientErrorInterceptor(), HystrixStreamClientInterceptor())
+	stream, err := chain(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, "/test.Service/Stream", streamer)
+	if !assert.NoError(t, err) {
+		return
+	}
+	observed, ok := stream.(*observedClientStream)
+	if assert.True(t, ok, "the stream should be wrapped by the outermost interceptor") {
+		_, ok = observed.ClientStream.(*testClientStream)
+		assert.True(t, ok, "the stream should be wrapped once for all interceptors")
+		assert.Len(t, observed.finishes, 3)
+	}
+
+	assert.Equal(t, io.EOF, drain(stream, 1))
+	tags, finished := tracer.spans[0].result()
+	assert.Equal(t, 1, finished)
+	assert.Equal(t, int64(2), tags["messages.received"])
+	assert.Error(t, streamCtx.Err(), "the stream context of hystrix should be cancelled when the stream ends")
+
+	// streams started with the context of another stream are observed on their own
+	stream, err = chain(streamCtx, &grpc.StreamDesc{ServerStreams: true}, nil, "/test.Service/Stream", testStreamer())
+	if assert.NoError(t, err) {
+		_, ok := stream.(*observedClientStream)
+		assert.True(t, ok)
+	}
+}
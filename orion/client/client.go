@@ -0,0 +1,275 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-orion/Orion/interceptors"
+	"github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer/roundrobin"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+//Dial creates a client connection to the named service, opts are applied after the options built from config
+func Dial(name string, config Config, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	target, err := buildTarget(config)
+	if err != nil {
+		return nil, fmt.Errorf("client %s: %s", name, err)
+	}
+	options, err := dialOptions(name, config)
+	if err != nil {
+		return nil, fmt.Errorf("client %s: %s", name, err)
+	}
+	options = append(options, opts...)
+	ctx := context.Background()
+	if config.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.DialTimeout)
+		defer cancel()
+		options = append(options, grpc.WithBlock())
+	}
+	conn, err := grpc.DialContext(ctx, target, options...)
+	if err != nil {
+		return nil, fmt.Errorf("client %s: %s", name, err)
+	}
+	return conn, nil
+}
+
+// buildTarget returns the target dialed for config, it selects the resolver used by the connection
+func buildTarget(config Config) (string, error) {
+	switch strings.ToLower(config.Resolver) {
+	case "", ResolverStatic:
+		if len(config.Addresses) == 0 {
+			return "", errors.New("no addresses for static resolver")
+		}
+		return staticScheme + ":///" + strings.Join(config.Addresses, ","), nil
+	case ResolverDNS:
+		if config.Target == "" {
+			return "", errors.New("no target for dns resolver")
+		}
+		return "dns:///" + config.Target, nil
+	case ResolverFile:
+		if config.Target == "" {
+			return "", errors.New("no target for file resolver")
+		}
+		interval := config.RefreshInterval
+		if interval <= 0 {
+			interval = defaultRefreshInterval
+		}
+		return fileScheme + "://" + interval.String() + "/" + config.Target, nil
+	}
+	return "", fmt.Errorf("unknown resolver %s", config.Resolver)
+}
+
+func dialOptions(name string, config Config) ([]grpc.DialOption, error) {
+	opts := make([]grpc.DialOption, 0)
+	if config.TLS {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	balancer := config.Balancer
+	if balancer == "" {
+		balancer = roundrobin.Name
+	}
+	opts = append(opts, grpc.WithBalancerName(balancer))
+	if config.KeepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                config.KeepaliveTime,
+			Timeout:             config.KeepaliveTimeout,
+			PermitWithoutStream: config.KeepalivePermitWithoutStream,
+		}))
+	}
+
+	unary, stream, err := clientInterceptors(name, config)
+	if err != nil {
+		return nil, err
+	}
+	if len(unary) > 0 {
+		opts = append(opts, grpc.WithUnaryInterceptor(grpc_middleware.ChainUnaryClient(unary...)))
+	}
+	if len(stream) > 0 {
+		opts = append(opts, grpc.WithStreamInterceptor(grpc_middleware.ChainStreamClient(stream...)))
+	}
+	return append(opts, config.DialOptions...), nil
+}
+
+// clientInterceptors returns the interceptors applied to the calls of both transports
+func clientInterceptors(name string, config Config) ([]grpc.UnaryClientInterceptor, []grpc.StreamClientInterceptor, error) {
+	unary := make([]grpc.UnaryClientInterceptor, 0)
+	stream := make([]grpc.StreamClientInterceptor, 0)
+	if config.Retries > 0 {
+		retryCodes, err := parseCodes(config.RetryCodes)
+		if err != nil {
+			return nil, nil, err
+		}
+		// retries are outermost so that each attempt goes through hystrix and is traced
+		unary = append(unary, RetryInterceptor(config.Retries, config.RetryBackoff, retryCodes...))
+	}
+	if !config.NoDefaultInterceptors {
+		unary = append(unary, interceptors.DefaultClientInterceptors(name)...)
+		stream = append(stream, interceptors.DefaultStreamClientInterceptors(name)...)
+	}
+	unary = append(unary, config.UnaryInterceptors...)
+	stream = append(stream, config.StreamInterceptors...)
+	return unary, stream, nil
+}
+
+//Manager creates and caches client connections to configured services
+type Manager struct {
+	mu       sync.Mutex
+	configs  map[string]Config
+	conns    map[string]*grpc.ClientConn
+	invokers map[string]*HTTPInvoker
+	// dials are the connections being dialed, callers asking for the same service wait for a single dial
+	dials  map[string]*pendingDial
+	closed bool
+}
+
+// pendingDial is a connection being dialed outside of the manager lock, done is closed once conn and err are set
+type pendingDial struct {
+	done chan struct{}
+	conn *grpc.ClientConn
+	err  error
+}
+
+//NewManager creates a new connection manager for the services in configs
+func NewManager(configs map[string]Config) *Manager {
+	m := &Manager{
+		configs:  make(map[string]Config),
+		conns:    make(map[string]*grpc.ClientConn),
+		invokers: make(map[string]*HTTPInvoker),
+		dials:    make(map[string]*pendingDial),
+	}
+	for name, config := range configs {
+		// viper keys are case insensitive, services are looked up in lower case
+		m.configs[strings.ToLower(name)] = config
+	}
+	return m
+}
+
+//Conn returns the gRPC connection to the named service, it is created on first use
+func (m *Manager) Conn(name string) (*grpc.ClientConn, error) {
+	m.mu.Lock()
+	config, err := m.config(name)
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if isHTTP(config) {
+		return nil, fmt.Errorf("client %s: no gRPC connection for the http transport", name)
+	}
+	return m.conn(name, config)
+}
+
+//Invoker returns the invoker for the named service using the transport in its configuration
+func (m *Manager) Invoker(name string) (Invoker, error) {
+	m.mu.Lock()
+	config, err := m.config(name)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	if err := checkTransport(config); err != nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("client %s: %s", name, err)
+	}
+	if !isHTTP(config) {
+		m.mu.Unlock()
+		conn, err := m.conn(name, config)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	}
+	defer m.mu.Unlock()
+	key := strings.ToLower(name)
+	if inv, ok := m.invokers[key]; ok {
+		return inv, nil
+	}
+	inv, err := NewHTTPInvoker(name, config)
+	if err != nil {
+		return nil, err
+	}
+	m.invokers[key] = inv
+	return inv, nil
+}
+
+func (m *Manager) config(name string) (Config, error) {
+	if m.closed {
+		return Config{}, fmt.Errorf("client %s: connection manager is closed", name)
+	}
+	config, ok := m.configs[strings.ToLower(name)]
+	if !ok {
+		return Config{}, fmt.Errorf("client %s: no configuration found", name)
+	}
+	return config, nil
+}
+
+// conn returns the cached connection of name or dials it, the dial happens outside of the manager lock so that a slow
+// service does not block the others, concurrent callers for the same service share a single dial
+func (m *Manager) conn(name string, config Config) (*grpc.ClientConn, error) {
+	key := strings.ToLower(name)
+	m.mu.Lock()
+	if conn, ok := m.conns[key]; ok {
+		m.mu.Unlock()
+		return conn, nil
+	}
+	d, ok := m.dials[key]
+	if !ok {
+		d = &pendingDial{done: make(chan struct{})}
+		m.dials[key] = d
+		m.mu.Unlock()
+		conn, err := Dial(name, config)
+		m.mu.Lock()
+		delete(m.dials, key)
+		if err == nil && m.closed {
+			// the manager was closed while dialing
+			conn.Close()
+			conn, err = nil, fmt.Errorf("client %s: connection manager is closed", name)
+		}
+		if err == nil {
+			m.conns[key] = conn
+		}
+		d.conn, d.err = conn, err
+		close(d.done)
+	}
+	m.mu.Unlock()
+	<-d.done
+	return d.conn, d.err
+}
+
+//SetConfig sets the configuration of the named service, it is used for connections created after the call
+func (m *Manager) SetConfig(name string, config Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.configs[strings.ToLower(name)] = config
+}
+
+//Close closes all connections, connections cannot be created after Close
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	var errs []string
+	for name, conn := range m.conns {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, name+": "+err.Error())
+		}
+	}
+	for _, inv := range m.invokers {
+		inv.Close()
+	}
+	m.conns = make(map[string]*grpc.ClientConn)
+	m.invokers = make(map[string]*HTTPInvoker)
+	if len(errs) > 0 {
+		return errors.New("could not close connections " + strings.Join(errs, ", "))
+	}
+	return nil
+}
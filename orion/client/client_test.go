@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBuildTarget(t *testing.T) {
+	tests := []struct {
+		config Config
+		target string
+		err    bool
+	}{
+		{Config{Addresses: []string{"a:1", "b:2"}}, "orion-static:///a:1,b:2", false},
+		{Config{Resolver: "static"}, "", true},
+		{Config{Resolver: "DNS", Target: "echo:9281"}, "dns:///echo:9281", false},
+		{Config{Resolver: "file", Target: "/etc/echo"}, "orion-file://30s//etc/echo", false},
+		{Config{Resolver: "file", Target: "echo", RefreshInterval: time.Second}, "orion-file://1s/echo", false},
+		{Config{Resolver: "consul", Target: "echo"}, "", true},
+	}
+	for _, test := range tests {
+		target, err := buildTarget(test.config)
+		assert.Equal(t, test.target, target, "targets should match")
+		assert.Equal(t, test.err, err != nil, "errors should match")
+	}
+}
+
+func TestRetryInterceptor(t *testing.T) {
+	tests := []struct {
+		failures int
+		code     codes.Code
+		calls    int
+		result   codes.Code
+	}{
+		{0, codes.Unavailable, 1, codes.OK},
+		{2, codes.Unavailable, 3, codes.OK},
+		{5, codes.Unavailable, 3, codes.Unavailable},
+		{1, codes.InvalidArgument, 1, codes.InvalidArgument},
+	}
+	for _, test := range tests {
+		calls := 0
+		invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			calls++
+			if calls <= test.failures {
+				return status.Error(test.code, "failed")
+			}
+			return nil
+		}
+		err := RetryInterceptor(2, time.Millisecond)(context.Background(), "/pkg.Svc/Method", nil, nil, nil, invoker)
+		assert.Equal(t, test.calls, calls, "calls should match")
+		assert.Equal(t, test.result, status.Code(err), "codes should match")
+	}
+}
+
+func TestParseCodes(t *testing.T) {
+	c, err := parseCodes([]string{"unavailable", "DEADLINE_EXCEEDED"})
+	assert.NoError(t, err)
+	assert.Equal(t, []codes.Code{codes.Unavailable, codes.DeadlineExceeded}, c)
+	_, err = parseCodes([]string{"bogus"})
+	assert.Error(t, err)
+}
+
+func TestManagerConn(t *testing.T) {
+	// nothing listens on the address of the slow service, its blocking dial only ends with the timeout
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+	m := NewManager(map[string]Config{
+		"Slow": {Addresses: []string{addr}, DialTimeout: 500 * time.Millisecond, NoDefaultInterceptors: true},
+		"Fast": {Addresses: []string{addr}, NoDefaultInterceptors: true},
+	})
+
+	slow := make(chan error, 1)
+	go func() {
+		_, err := m.Conn("slow")
+		slow <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// a slow dial does not block other services, concurrent callers share a connection
+	start := time.Now()
+	conns := make(chan *grpc.ClientConn, 5)
+	for i := 0; i < cap(conns); i++ {
+		go func() {
+			conn, err := m.Conn("FAST")
+			assert.NoError(t, err)
+			conns <- conn
+		}()
+	}
+	first := <-conns
+	for i := 1; i < cap(conns); i++ {
+		assert.True(t, first == <-conns, "a single connection should be dialed")
+	}
+	assert.True(t, time.Since(start) < 250*time.Millisecond, "dialing should not wait for other services")
+	assert.Error(t, <-slow, "the slow dial should time out")
+
+	assert.NoError(t, m.Close())
+	_, err = m.Conn("fast")
+	assert.Error(t, err, "connections cannot be created after Close")
+}
+
+func TestInvokerErrors(t *testing.T) {
+	configs := map[string]Config{
+		"grpc": {Resolver: ResolverStatic},
+		"http": {Transport: TransportHTTP},
+	}
+	m := NewManager(configs)
+	defer m.Close()
+	for name, config := range configs {
+		// a failed invoker is a nil interface, not a nil connection or client in an interface
+		inv, err := NewInvoker(name, config)
+		assert.Error(t, err, name)
+		assert.True(t, inv == nil, "%s: NewInvoker should return a nil invoker", name)
+		inv, err = m.Invoker(name)
+		assert.Error(t, err, name)
+		assert.True(t, inv == nil, "%s: Manager.Invoker should return a nil invoker", name)
+	}
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+const (
+	//ResolverStatic resolves the addresses listed in config
+	ResolverStatic = "static"
+	//ResolverDNS resolves the target through DNS
+	ResolverDNS = "dns"
+	//ResolverFile resolves the addresses listed in a file, it can stand in for a service discovery agent
+	ResolverFile = "file"
+
+	//TransportGRPC calls services over gRPC
+	TransportGRPC = "grpc"
+	//TransportHTTP calls services over the HTTP mapping of Orion
+	TransportHTTP = "http"
+	//HTTPCodecJSON serializes HTTP requests and responses as json
+	HTTPCodecJSON = "json"
+	//HTTPCodecProto serializes HTTP requests and responses as protobuf
+	HTTPCodecProto = "proto"
+)
+
+//Config is the configuration of connections to a service
+type Config struct {
+	//Transport is used to call the service, 'grpc' (default) or 'http'
+	Transport string
+	//Resolver is used to find the addresses of the service, 'static' (default), 'dns' or 'file'
+	Resolver string
+	//Addresses are the addresses of the service used by the static resolver
+	Addresses []string
+	//Target is the host:port resolved by the dns resolver or the path of the file read by the file resolver
+	Target string
+	//RefreshInterval is the interval at which the file resolver reads the file again
+	RefreshInterval time.Duration
+	//Balancer is the load balancing policy, 'round_robin' (default) or 'pick_first'
+	Balancer string
+	//TLS uses TLS transport credentials, connections are insecure otherwise
+	TLS bool
+	//DialTimeout blocks Dial until the connection is ready or the timeout expires, Dial does not block when zero
+	DialTimeout time.Duration
+	//KeepaliveTime is the idle time after which the client pings the server
+	KeepaliveTime time.Duration
+	//KeepaliveTimeout is the time the client waits for a ping ack before closing the connection
+	KeepaliveTimeout time.Duration
+	//KeepalivePermitWithoutStream sends pings when there are no active calls
+	KeepalivePermitWithoutStream bool
+	//Retries is the number of times failed unary calls are retried
+	Retries int
+	//RetryBackoff is the wait before the first retry, it doubles with every retry
+	RetryBackoff time.Duration
+	//RetryCodes are the status codes that are retried, e.g. 'UNAVAILABLE' (default)
+	RetryCodes []string
+	//BaseURL is the scheme, host and port of the HTTP server of the service used by the http transport,
+	//e.g. 'http://echo:9282'
+	BaseURL string
+	//HTTPCodec is the serialization used by the http transport, 'json' (default) or 'proto'
+	HTTPCodec string
+	//HTTPTimeout is the timeout of HTTP requests, streams are not limited by it
+	HTTPTimeout time.Duration
+	//HTTPVersionPrefix calls the generated urls with the version prefix, it should match 'orion.HTTPVersionPrefix' of the service
+	HTTPVersionPrefix bool
+	//NoDefaultInterceptors does not apply the default client interceptors
+	NoDefaultInterceptors bool
+	//UnaryInterceptors are applied to unary calls after the default interceptors
+	UnaryInterceptors []grpc.UnaryClientInterceptor `json:"-" mapstructure:"-"`
+	//StreamInterceptors are applied to streaming calls after the default interceptors
+	StreamInterceptors []grpc.StreamClientInterceptor `json:"-" mapstructure:"-"`
+	//HTTPTransport is the base round tripper of the http transport, http.DefaultTransport is used when nil
+	HTTPTransport http.RoundTripper `json:"-" mapstructure:"-"`
+	//DialOptions are passed as is to grpc.Dial after the options built from the settings above
+	DialOptions []grpc.DialOption `json:"-" mapstructure:"-"`
+}
@@ -0,0 +1,36 @@
+/*
+Package client builds gRPC client connections to other services with Orion defaults.
+
+Connections are configured per target service name, they apply the default client interceptors
+(tracing, newrelic and hystrix), retries, keepalive and round robin balancing
+
+	conn, err := client.Dial("echo", client.Config{
+		Addresses: []string{"10.0.0.1:9281", "10.0.0.2:9281"},
+		Retries:   2,
+	})
+
+Addresses are resolved by one of the following resolvers
+
+	static: the list of Addresses
+	dns:    the host:port in Target, resolved periodically through DNS
+	file:   the file at Target, with one address per line, read again every RefreshInterval
+
+Orion servers manage connections for the services configured in 'orion.Clients', they are closed when the server stops
+
+	conn, err := orion.GetClientConn(svr, "echo")
+
+Services can also be called over the HTTP mapping of Orion by setting Transport to 'http'. Generated Orion clients
+call a service through an Invoker, which picks the transport from the configuration
+
+	inv, err := orion.GetClientInvoker(svr, "echo")
+	echo := echo_proto.NewEchoServiceOrionClient(inv)
+	resp, err := echo.Upper(ctx, &echo_proto.UpperRequest{Msg: "hello"})
+
+Both transports apply the same interceptors and return status errors with the code of the service error.
+The http transport sends outgoing metadata as 'Grpc-Metadata-' headers, returns response headers along with the
+header and trailer metadata set by the service as metadata and supports unary calls and server streams, the
+routes of 'ORION:URL' annotations are used by generated clients. GET, HEAD and DELETE routes send the fields
+that are not part of the path as query parameters. Errors that are not status errors keep their message over
+gRPC but are returned as 'Internal Server Error!' over HTTP
+*/
+package client
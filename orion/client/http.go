@@ -0,0 +1,697 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	httphandler "github.com/go-orion/Orion/orion/handlers/http"
+	"github.com/go-orion/Orion/utils/httptripper"
+	"github.com/go-orion/Orion/utils/httptripper/retry"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/grpc-ecosystem/go-grpc-middleware"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// frame flags of length prefixed protobuf streams
+const (
+	frameTrailer byte = 0x80
+)
+
+//HTTPInvoker calls a service over the HTTP mapping of Orion. Calls go through the same interceptors as gRPC calls,
+//metadata is sent as 'Grpc-Metadata-' headers, response headers and the metadata set by the service are returned
+//as header and trailer metadata and failed calls return status errors with the code of the service error.
+//Errors that are not status errors are returned with the message 'Internal Server Error!' by the HTTP handler
+//instead of the message of the error
+type HTTPInvoker struct {
+	baseURL   string
+	versioned bool
+	timeout   time.Duration
+	codec     httpCodec
+	client    *http.Client
+	unary     grpc.UnaryClientInterceptor
+	stream    grpc.StreamClientInterceptor
+}
+
+//NewHTTPInvoker creates an invoker calling the named service over HTTP
+func NewHTTPInvoker(name string, config Config) (*HTTPInvoker, error) {
+	if config.BaseURL == "" {
+		return nil, fmt.Errorf("client %s: no base url for http transport", name)
+	}
+	if _, err := url.Parse(config.BaseURL); err != nil {
+		return nil, fmt.Errorf("client %s: %s", name, err)
+	}
+	codec, err := newHTTPCodec(config.HTTPCodec)
+	if err != nil {
+		return nil, fmt.Errorf("client %s: %s", name, err)
+	}
+	unary, stream, err := clientInterceptors(name, config)
+	if err != nil {
+		return nil, fmt.Errorf("client %s: %s", name, err)
+	}
+	base := config.HTTPTransport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	inv := &HTTPInvoker{
+		baseURL:   strings.TrimSuffix(config.BaseURL, "/"),
+		versioned: config.HTTPVersionPrefix,
+		timeout:   config.HTTPTimeout,
+		codec:     codec,
+		client: &http.Client{
+			// hystrix and retries are applied by the client interceptors
+			Transport: httptripper.NewTripper(
+				httptripper.WithBaseTripper(base),
+				httptripper.WithHystrix(false),
+				httptripper.WithRetrier(retry.NewRetry(retry.WithMaxRetry(0))),
+			),
+		},
+	}
+	if len(unary) > 0 {
+		inv.unary = grpc_middleware.ChainUnaryClient(unary...)
+	}
+	if len(stream) > 0 {
+		inv.stream = grpc_middleware.ChainStreamClient(stream...)
+	}
+	return inv, nil
+}
+
+//Invoke makes a unary call over HTTP, method is the full gRPC method name, e.g. '/echo.EchoService/Upper'
+func (inv *HTTPInvoker) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	if inv.unary == nil {
+		return inv.invoke(ctx, method, args, reply, nil, opts...)
+	}
+	return inv.unary(ctx, method, args, reply, nil, inv.invoke, opts...)
+}
+
+//NewStream starts a server stream over chunked HTTP, client and bidirectional streams are not supported
+func (inv *HTTPInvoker) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if inv.stream == nil {
+		return inv.newStream(ctx, desc, nil, method, opts...)
+	}
+	return inv.stream(ctx, desc, nil, method, inv.newStream, opts...)
+}
+
+//Close closes the idle connections of the invoker
+func (inv *HTTPInvoker) Close() {
+	if tr, ok := inv.client.Transport.(interface{ CloseIdleConnections() }); ok {
+		tr.CloseIdleConnections()
+	}
+}
+
+func (inv *HTTPInvoker) invoke(ctx context.Context, method string, args, reply interface{}, _ *grpc.ClientConn, opts ...grpc.CallOption) error {
+	if inv.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, inv.timeout)
+		defer cancel()
+	}
+	resp, err := inv.do(ctx, method, args, false, opts)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return transportError(ctx, err)
+	}
+	setCallMetadata(opts, responseMetadata(resp.Header), responseMetadata(resp.Trailer))
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp, data)
+	}
+	if err := inv.codec.ForResponse(resp.Header.Get("Content-Type")).Unmarshal(data, reply); err != nil {
+		return status.Errorf(codes.Internal, "could not decode response: %s", err)
+	}
+	return nil
+}
+
+func (inv *HTTPInvoker) newStream(ctx context.Context, desc *grpc.StreamDesc, _ *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if desc.ClientStreams {
+		return nil, status.Errorf(codes.Unimplemented, "%s: client streams are not supported by the http transport", method)
+	}
+	return &httpClientStream{
+		ctx:    ctx,
+		inv:    inv,
+		method: method,
+		opts:   opts,
+	}, nil
+}
+
+// do sends the request of a call, the route of the call is picked from opts or generated from the method name
+func (inv *HTTPInvoker) do(ctx context.Context, method string, args interface{}, stream bool, opts []grpc.CallOption) (*http.Response, error) {
+	httpMethod, path := inv.route(method, opts)
+	path, pathFields, err := fillPath(path, args)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%s: %s", method, err)
+	}
+	var body io.Reader
+	switch httpMethod {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		// fields that are not part of the path are sent as query parameters, the HTTP handler decodes them
+		// for requests without a body
+		query, err := queryValues(args, pathFields)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%s: %s", method, err)
+		}
+		if len(query) > 0 {
+			path += "?" + query.Encode()
+		}
+	default:
+		data, err := inv.codec.Marshal(args)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "could not encode request: %s", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := httptripper.NewRequest(ctx, method, httpMethod, inv.baseURL+path, body)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not create request: %s", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", inv.codec.ContentType())
+	}
+	if stream {
+		req.Header.Set("Accept", inv.codec.StreamContentType())
+	} else {
+		req.Header.Set("Accept", inv.codec.Accept())
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for key, values := range md {
+		for _, value := range values {
+			if strings.HasSuffix(key, "-bin") {
+				value = base64.StdEncoding.EncodeToString([]byte(value))
+			}
+			req.Header.Add(httphandler.GRPCMetadataHeaderPrefix+key, value)
+		}
+	}
+	resp, err := inv.client.Do(req)
+	if err != nil {
+		return nil, transportError(ctx, err)
+	}
+	return resp, nil
+}
+
+// route finds the HTTP method and path of a call, calls use POST on the generated url unless HTTPRoute is provided
+func (inv *HTTPInvoker) route(method string, opts []grpc.CallOption) (string, string) {
+	httpMethod, path := http.MethodPost, ""
+	for _, opt := range opts {
+		if r, ok := opt.(*httpRoute); ok {
+			if r.method != "" {
+				httpMethod = r.method
+			}
+			path = r.path
+		}
+	}
+	if strings.TrimSpace(path) == "" {
+		parts := strings.Split(strings.TrimPrefix(method, "/"), "/")
+		path = httphandler.RouteURL(parts[0], parts[len(parts)-1], inv.versioned)
+	}
+	return httpMethod, path
+}
+
+// fillPath replaces the variables of a route path, e.g. '/upper/{msg}' or '/users/{id:[0-9]+}', with the
+// matching fields of the request, it also returns the json names of the fields used in the path
+func fillPath(path string, args interface{}) (string, []string, error) {
+	var buf bytes.Buffer
+	var fields []string
+	for {
+		start := strings.Index(path, "{")
+		if start < 0 {
+			buf.WriteString(path)
+			return buf.String(), fields, nil
+		}
+		buf.WriteString(path[:start])
+		end, depth := -1, 0
+		for i := start; i < len(path) && end < 0; i++ {
+			switch path[i] {
+			case '{':
+				depth++
+			case '}':
+				if depth--; depth == 0 {
+					end = i
+				}
+			}
+		}
+		if end < 0 {
+			return "", nil, fmt.Errorf("unbalanced braces in path %s", path)
+		}
+		name := strings.TrimSpace(strings.SplitN(path[start+1:end], ":", 2)[0])
+		value, field, ok := fieldValue(args, name)
+		if !ok {
+			return "", nil, fmt.Errorf("no field for path variable %s", name)
+		}
+		buf.WriteString(url.PathEscape(value))
+		fields = append(fields, field)
+		path = path[end+1:]
+	}
+}
+
+// fieldValue finds the field of a struct for a path variable, fields are matched on their name or json name
+// ignoring case like the HTTP handler does, it returns the value and the json name of the field
+func fieldValue(args interface{}, name string) (string, string, bool) {
+	v := reflect.ValueOf(args)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", "", false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return "", "", false
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+		jsonName := strings.Split(f.Tag.Get("json"), ",")[0]
+		if strings.EqualFold(f.Name, name) || strings.EqualFold(jsonName, name) {
+			if jsonName == "" {
+				jsonName = f.Name
+			}
+			return fmt.Sprint(v.Field(i).Interface()), jsonName, true
+		}
+	}
+	return "", "", false
+}
+
+// queryValues converts the fields of a request into query parameters, skipping the fields sent in the path,
+// repeated fields are sent as repeated parameters and message fields can not be sent
+func queryValues(args interface{}, skip []string) (url.Values, error) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{})
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("%T can not be sent as query parameters", args)
+	}
+	query := url.Values{}
+	for _, field := range skip {
+		delete(fields, field)
+	}
+	for key, value := range fields {
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		for _, v := range values {
+			switch v.(type) {
+			case nil:
+			case map[string]interface{}, []interface{}:
+				return nil, fmt.Errorf("field %s can not be sent as a query parameter", key)
+			default:
+				query.Add(key, fmt.Sprint(v))
+			}
+		}
+	}
+	return query, nil
+}
+
+// transportError converts errors of HTTP requests into status errors
+func transportError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+// responseError converts a failed HTTP response into a status error, the code is read from the GRPCStatusHeader
+// and derived from the HTTP status code when the header is missing
+func responseError(resp *http.Response, body []byte) error {
+	code := httpStatusToCode(resp.StatusCode)
+	if value := resp.Header.Get(httphandler.GRPCStatusHeader); value != "" {
+		if c, err := strconv.Atoi(value); err == nil {
+			code = codes.Code(c)
+		}
+	}
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		msg = http.StatusText(resp.StatusCode)
+	}
+	return status.Error(code, msg)
+}
+
+// httpStatusToCode is the reverse of the mapping used by the HTTP handler
+func httpStatusToCode(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusOK:
+		return codes.OK
+	case http.StatusBadRequest, http.StatusUnsupportedMediaType:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusRequestTimeout:
+		return codes.Canceled
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Unknown
+}
+
+// responseMetadata converts HTTP headers into metadata with lower case keys, the metadata set by the service is
+// sent with the GRPCMetadataHeaderPrefix, which is removed
+func responseMetadata(hdr http.Header) metadata.MD {
+	md := metadata.MD{}
+	prefix := strings.ToLower(httphandler.GRPCMetadataHeaderPrefix)
+	for key, values := range hdr {
+		key = strings.ToLower(key)
+		if !strings.HasPrefix(key, prefix) || len(key) == len(prefix) {
+			md[key] = append(md[key], values...)
+			continue
+		}
+		key = key[len(prefix):]
+		for _, value := range values {
+			if strings.HasSuffix(key, "-bin") {
+				// binary values are base64 encoded in headers
+				if data, err := base64.StdEncoding.DecodeString(value); err == nil {
+					value = string(data)
+				}
+			}
+			md[key] = append(md[key], value)
+		}
+	}
+	return md
+}
+
+// setCallMetadata populates the grpc.Header and grpc.Trailer call options
+func setCallMetadata(opts []grpc.CallOption, header, trailer metadata.MD) {
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case grpc.HeaderCallOption:
+			*o.HeaderAddr = header
+		case grpc.TrailerCallOption:
+			*o.TrailerAddr = trailer
+		}
+	}
+}
+
+// httpClientStream implements grpc.ClientStream over a chunked HTTP response, the request is sent on the first
+// call to CloseSend, Header or RecvMsg
+type httpClientStream struct {
+	ctx      context.Context
+	inv      *HTTPInvoker
+	method   string
+	opts     []grpc.CallOption
+	args     interface{}
+	sent     bool
+	start    sync.Once
+	startErr error
+	resp     *http.Response
+	reader   *bufio.Reader
+	header   metadata.MD
+	end      sync.Once
+	done     chan struct{}
+	err      error
+}
+
+func (s *httpClientStream) begin() error {
+	s.start.Do(func() {
+		if !s.sent {
+			s.startErr = status.Error(codes.Internal, "no request sent on stream")
+			return
+		}
+		resp, err := s.inv.do(s.ctx, s.method, s.args, true, s.opts)
+		if err != nil {
+			s.startErr = err
+			return
+		}
+		s.header = responseMetadata(resp.Header)
+		if resp.StatusCode != http.StatusOK {
+			data, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			s.startErr = responseError(resp, data)
+			return
+		}
+		s.resp = resp
+		s.reader = bufio.NewReader(resp.Body)
+		s.done = make(chan struct{})
+		// a blocked read is interrupted when the stream context ends
+		go func() {
+			select {
+			case <-s.ctx.Done():
+				resp.Body.Close()
+			case <-s.done:
+			}
+		}()
+	})
+	return s.startErr
+}
+
+func (s *httpClientStream) finish() {
+	s.end.Do(func() {
+		close(s.done)
+		s.resp.Body.Close()
+	})
+}
+
+func (s *httpClientStream) Header() (metadata.MD, error) {
+	if err := s.begin(); err != nil {
+		return nil, err
+	}
+	return s.header, nil
+}
+
+func (s *httpClientStream) Trailer() metadata.MD {
+	if s.resp == nil {
+		return nil
+	}
+	return responseMetadata(s.resp.Trailer)
+}
+
+func (s *httpClientStream) CloseSend() error {
+	// errors are returned by RecvMsg, like for gRPC streams
+	s.begin()
+	return nil
+}
+
+func (s *httpClientStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *httpClientStream) SendMsg(m interface{}) error {
+	if s.sent {
+		return status.Error(codes.Internal, "server streams only send a single request")
+	}
+	s.sent = true
+	s.args = m
+	return nil
+}
+
+func (s *httpClientStream) RecvMsg(m interface{}) error {
+	if err := s.begin(); err != nil {
+		return err
+	}
+	if s.err != nil {
+		return s.err
+	}
+	if err := s.recv(m); err != nil {
+		if err != io.EOF && s.ctx.Err() != nil {
+			err = status.FromContextError(s.ctx.Err()).Err()
+		}
+		s.err = err
+		s.finish()
+		return err
+	}
+	return nil
+}
+
+func (s *httpClientStream) recv(m interface{}) error {
+	if s.inv.codec.Framed() {
+		prefix := make([]byte, 5)
+		if _, err := io.ReadFull(s.reader, prefix); err != nil {
+			return streamReadError(err)
+		}
+		data := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+		if _, err := io.ReadFull(s.reader, data); err != nil {
+			return streamReadError(err)
+		}
+		if prefix[0]&frameTrailer != 0 {
+			st := new(spb.Status)
+			if err := proto.Unmarshal(data, st); err != nil {
+				return status.Errorf(codes.Internal, "could not decode stream status: %s", err)
+			}
+			return status.FromProto(st).Err()
+		}
+		return s.inv.codec.Unmarshal(data, m)
+	}
+	line, err := s.reader.ReadBytes('\n')
+	if len(bytes.TrimSpace(line)) == 0 && err != nil {
+		return streamReadError(err)
+	}
+	if err := streamErrorLine(line); err != nil {
+		return err
+	}
+	if err := s.inv.codec.Unmarshal(line, m); err != nil {
+		return status.Errorf(codes.Internal, "could not decode response: %s", err)
+	}
+	return nil
+}
+
+// streamReadError converts errors reading a stream into status errors, streams end cleanly at a message boundary
+func streamReadError(err error) error {
+	switch err {
+	case io.EOF:
+		return io.EOF
+	case io.ErrUnexpectedEOF:
+		return status.Error(codes.Internal, "stream ended in the middle of a message")
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+// streamErrorLine returns the error carried by the trailing message of a newline delimited json stream
+func streamErrorLine(line []byte) error {
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(line, &msg); err != nil || len(msg) != 1 || msg["error"] == nil {
+		return nil
+	}
+	var st struct {
+		Code    *uint32 `json:"code"`
+		Status  string  `json:"status"`
+		Message string  `json:"message"`
+	}
+	if err := json.Unmarshal(msg["error"], &st); err != nil || st.Code == nil || st.Status == "" {
+		return nil
+	}
+	return status.Error(codes.Code(*st.Code), st.Message)
+}
+
+// httpCodec serializes requests and responses of the http transport
+type httpCodec interface {
+	Marshal(msg interface{}) ([]byte, error)
+	Unmarshal(data []byte, msg interface{}) error
+	ContentType() string
+	// Accept is the Accept header of unary calls
+	Accept() string
+	StreamContentType() string
+	// Framed is true for streams using length prefixed messages
+	Framed() bool
+	// ForResponse returns the codec decoding a unary response with the content type
+	ForResponse(contentType string) httpCodec
+}
+
+func newHTTPCodec(name string) (httpCodec, error) {
+	switch strings.ToLower(name) {
+	case "", HTTPCodecJSON:
+		return jsonCodec{}, nil
+	case HTTPCodecProto:
+		return protoCodec{}, nil
+	}
+	return nil, fmt.Errorf("unknown http codec %s", name)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(msg interface{}) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (jsonCodec) Unmarshal(data []byte, msg interface{}) error {
+	return json.Unmarshal(data, msg)
+}
+
+func (jsonCodec) ContentType() string {
+	return httphandler.ContentTypeJSON
+}
+
+// Accept asks for jsonpb explicitly, as json and jsonpb responses share a content type otherwise
+func (jsonCodec) Accept() string {
+	return httphandler.ContentTypeJSONPB + ", " + httphandler.ContentTypeJSON + ";q=0.9"
+}
+
+func (c jsonCodec) ForResponse(contentType string) httpCodec {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == httphandler.ContentTypeJSONPB {
+		return jsonpbCodec{c}
+	}
+	return c
+}
+
+func (jsonCodec) StreamContentType() string {
+	return httphandler.ContentTypeNDJSON
+}
+
+func (jsonCodec) Framed() bool {
+	return false
+}
+
+// jsonpbCodec decodes the responses of methods using the jsonpb codec of the HTTP handler
+type jsonpbCodec struct {
+	jsonCodec
+}
+
+func (jsonpbCodec) Unmarshal(data []byte, msg interface{}) error {
+	if protoMsg, ok := msg.(proto.Message); ok {
+		return (&jsonpb.Unmarshaler{AllowUnknownFields: true}).Unmarshal(bytes.NewReader(data), protoMsg)
+	}
+	return json.Unmarshal(data, msg)
+}
+
+type protoCodec struct{}
+
+func (protoCodec) Marshal(msg interface{}) ([]byte, error) {
+	if protoMsg, ok := msg.(proto.Message); ok {
+		return proto.Marshal(protoMsg)
+	}
+	return nil, fmt.Errorf("%T is not a proto message", msg)
+}
+
+func (protoCodec) Unmarshal(data []byte, msg interface{}) error {
+	if protoMsg, ok := msg.(proto.Message); ok {
+		return proto.Unmarshal(data, protoMsg)
+	}
+	return fmt.Errorf("%T is not a proto message", msg)
+}
+
+func (protoCodec) ContentType() string {
+	return httphandler.ContentTypeProtobuf
+}
+
+func (protoCodec) Accept() string {
+	return httphandler.ContentTypeProtobuf
+}
+
+func (c protoCodec) ForResponse(string) httpCodec {
+	return c
+}
+
+func (protoCodec) StreamContentType() string {
+	return httphandler.ContentTypeProtoStream
+}
+
+func (protoCodec) Framed() bool {
+	return true
+}
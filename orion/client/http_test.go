@@ -0,0 +1,154 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type pathRequest struct {
+	Msg    string `json:"msg,omitempty"`
+	UserId int64  `json:"user_id,omitempty"`
+}
+
+type queryRequest struct {
+	Msg    string        `json:"msg,omitempty"`
+	Tags   []string      `json:"tags,omitempty"`
+	Big    int64         `json:"big,omitempty"`
+	Nested *pathRequest  `json:"nested,omitempty"`
+	Items  []pathRequest `json:"items,omitempty"`
+}
+
+func TestFillPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		result string
+		fields []string
+		err    bool
+	}{
+		{"/echoservice/upper", "/echoservice/upper", nil, false},
+		{"/api/1.0/upper/{msg}", "/api/1.0/upper/hello%20world", []string{"msg"}, false},
+		{"/users/{user_id:[0-9]{1,3}}/{MSG}", "/users/42/hello%20world", []string{"user_id", "msg"}, false},
+		{"/users/{missing}", "", nil, true},
+		{"/users/{msg", "", nil, true},
+	}
+	for _, test := range tests {
+		path, fields, err := fillPath(test.path, &pathRequest{Msg: "hello world", UserId: 42})
+		assert.Equal(t, test.result, path, "paths should match")
+		assert.Equal(t, test.fields, fields, "path fields should match")
+		assert.Equal(t, test.err, err != nil, "errors should match")
+	}
+}
+
+func TestQueryValues(t *testing.T) {
+	tests := []struct {
+		args  interface{}
+		skip  []string
+		query string
+		err   bool
+	}{
+		{&queryRequest{}, nil, "", false},
+		{&queryRequest{Msg: "hello world", Big: 1 << 60}, nil, "big=1152921504606846976&msg=hello+world", false},
+		{&queryRequest{Msg: "hello", Tags: []string{"a", "b"}}, []string{"msg"}, "tags=a&tags=b", false},
+		{&queryRequest{Nested: &pathRequest{Msg: "hello"}}, nil, "", true},
+		{&queryRequest{Items: []pathRequest{{Msg: "hello"}}}, nil, "", true},
+		{"not a message", nil, "", true},
+	}
+	for _, test := range tests {
+		query, err := queryValues(test.args, test.skip)
+		assert.Equal(t, test.err, err != nil, "%+v", test.args)
+		if err == nil {
+			assert.Equal(t, test.query, query.Encode(), "%+v", test.args)
+		}
+	}
+}
+
+func TestResponseMetadata(t *testing.T) {
+	hdr := http.Header{}
+	hdr.Add("Content-Type", "application/json")
+	hdr.Add("Grpc-Metadata-X-Version", "1")
+	hdr.Add("Grpc-Metadata-X-Version", "2")
+	hdr.Add("Grpc-Metadata-Trace-Bin", base64.StdEncoding.EncodeToString([]byte{0, 1}))
+	md := responseMetadata(hdr)
+	assert.Equal(t, []string{"application/json"}, md["content-type"])
+	assert.Equal(t, []string{"1", "2"}, md["x-version"], "service metadata should be returned without the prefix")
+	assert.Equal(t, []string{string([]byte{0, 1})}, md["trace-bin"], "binary metadata should be decoded")
+	assert.Nil(t, md["grpc-metadata-x-version"])
+}
+
+func TestJSONCodecResponses(t *testing.T) {
+	codec := jsonCodec{}
+	assert.Equal(t, "application/jsonpb, application/json;q=0.9", codec.Accept())
+	assert.Equal(t, codec, codec.ForResponse("application/json; charset=utf-8"))
+
+	// jsonpb uses camel case names and strings for 64 bit integers
+	msg := new(wrappers.Int64Value)
+	assert.NoError(t, codec.ForResponse("application/jsonpb").Unmarshal([]byte(`"42"`), msg))
+	assert.Equal(t, int64(42), msg.Value)
+	assert.Error(t, codec.Unmarshal([]byte(`"42"`), new(wrappers.Int64Value)), "json can not decode jsonpb")
+}
+
+func TestHTTPInvoker(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Request", r.Method+" "+r.URL.RequestURI()+" "+string(body)+" "+r.Header.Get("Grpc-Metadata-User"))
+		switch r.URL.Path {
+		case "/echoservice/fail":
+			w.Header().Set("Grpc-Status", "9")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("precondition failed"))
+		case "/echoservice/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Write([]byte(`{"msg":"HELLO"}`))
+		}
+	}))
+	defer svr.Close()
+	inv, err := NewHTTPInvoker("echo", Config{BaseURL: svr.URL, NoDefaultInterceptors: true})
+	assert.NoError(t, err)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "user", "bob")
+
+	reply := new(pathRequest)
+	var header metadata.MD
+	err = inv.Invoke(ctx, "/echo.EchoService/Upper", &pathRequest{Msg: "hello"}, reply, grpc.Header(&header))
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO", reply.Msg)
+	assert.Equal(t, []string{`POST /echoservice/upper {"msg":"hello"} bob`}, header["x-request"])
+
+	err = inv.Invoke(ctx, "/echo.EchoService/Upper", &pathRequest{Msg: "hello"}, reply, grpc.Header(&header), HTTPRoute("put", "/api/{msg}"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`PUT /api/hello {"msg":"hello"} bob`}, header["x-request"])
+
+	// fields of GET and DELETE requests are sent as query parameters
+	err = inv.Invoke(ctx, "/echo.EchoService/Upper", &pathRequest{Msg: "hello", UserId: 42}, reply, grpc.Header(&header), HTTPRoute("get", "/api/{msg}"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`GET /api/hello?user_id=42  bob`}, header["x-request"])
+	err = inv.Invoke(ctx, "/echo.EchoService/Upper", &pathRequest{Msg: "hello"}, reply, grpc.Header(&header), HTTPRoute("delete", "/api"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`DELETE /api?msg=hello  bob`}, header["x-request"])
+
+	err = inv.Invoke(ctx, "/echo.EchoService/Fail", &pathRequest{}, reply)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), "code should be read from the status header")
+	assert.Equal(t, "precondition failed", status.Convert(err).Message())
+
+	err = inv.Invoke(ctx, "/echo.EchoService/Missing", &pathRequest{}, reply)
+	assert.Equal(t, codes.NotFound, status.Code(err), "code should be derived from the HTTP status")
+
+	_, err = inv.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true}, "/echo.EchoService/Upload")
+	assert.Equal(t, codes.Unimplemented, status.Code(err), "client streams are not supported")
+
+	_, err = NewHTTPInvoker("echo", Config{})
+	assert.Error(t, err, "base url is required")
+	_, err = NewInvoker("echo", Config{Transport: "smtp"})
+	assert.Error(t, err, "transport should be validated")
+}
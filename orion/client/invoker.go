@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+//Invoker makes unary and streaming calls to a service, it is implemented by *grpc.ClientConn for the gRPC transport
+//and by HTTPInvoker for the http transport
+type Invoker interface {
+	Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error
+	NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error)
+}
+
+//NewInvoker creates an invoker for the named service using the transport selected in config
+func NewInvoker(name string, config Config) (Invoker, error) {
+	if err := checkTransport(config); err != nil {
+		return nil, fmt.Errorf("client %s: %s", name, err)
+	}
+	// the typed results are checked before conversion, a nil pointer in an Invoker is not a nil Invoker
+	if isHTTP(config) {
+		inv, err := NewHTTPInvoker(name, config)
+		if err != nil {
+			return nil, err
+		}
+		return inv, nil
+	}
+	conn, err := Dial(name, config)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func isHTTP(config Config) bool {
+	return strings.ToLower(config.Transport) == TransportHTTP
+}
+
+func checkTransport(config Config) error {
+	switch strings.ToLower(config.Transport) {
+	case "", TransportGRPC, TransportHTTP:
+		return nil
+	}
+	return fmt.Errorf("unknown transport %s", config.Transport)
+}
+
+//HTTPRoute sets the HTTP method and path used by the http transport for a call, the path can contain route
+//variables that are filled from the request, e.g. '/api/1.0/upper/{msg}'. Generated clients set it for methods
+//with an 'ORION:URL' annotation, it is ignored by the gRPC transport
+func HTTPRoute(method, path string) grpc.CallOption {
+	return &httpRoute{
+		method: strings.ToUpper(method),
+		path:   path,
+	}
+}
+
+type httpRoute struct {
+	grpc.EmptyCallOption
+	method string
+	path   string
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-orion/Orion/utils/log"
+	"google.golang.org/grpc/resolver"
+)
+
+const (
+	staticScheme = "orion-static"
+	fileScheme   = "orion-file"
+
+	defaultRefreshInterval = 30 * time.Second
+)
+
+func init() {
+	resolver.Register(&staticBuilder{})
+	resolver.Register(&fileBuilder{})
+}
+
+// staticBuilder resolves the comma separated addresses in the endpoint of the target
+type staticBuilder struct{}
+
+func (*staticBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOption) (resolver.Resolver, error) {
+	cc.NewAddress(toAddresses(strings.Split(target.Endpoint, ",")))
+	return nopResolver{}, nil
+}
+
+func (*staticBuilder) Scheme() string {
+	return staticScheme
+}
+
+type nopResolver struct{}
+
+func (nopResolver) ResolveNow(resolver.ResolveNowOption) {}
+
+func (nopResolver) Close() {}
+
+// fileBuilder resolves the addresses listed in the file at the endpoint of the target,
+// the authority of the target is the refresh interval
+type fileBuilder struct{}
+
+func (*fileBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOption) (resolver.Resolver, error) {
+	interval, err := time.ParseDuration(target.Authority)
+	if err != nil || interval <= 0 {
+		interval = defaultRefreshInterval
+	}
+	r := &fileResolver{
+		path:    target.Endpoint,
+		cc:      cc,
+		refresh: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	r.resolve()
+	go r.watch(interval)
+	return r, nil
+}
+
+func (*fileBuilder) Scheme() string {
+	return fileScheme
+}
+
+type fileResolver struct {
+	path    string
+	cc      resolver.ClientConn
+	refresh chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	last    string
+}
+
+// resolve reads the file and updates the addresses when they changed, the previous addresses are kept
+// when the file cannot be read
+func (r *fileResolver) resolve() {
+	f, err := os.Open(r.path)
+	if err != nil {
+		log.Warn(context.Background(), "file", r.path, "error", err, "msg", "could not read client addresses")
+		return
+	}
+	defer f.Close()
+	addrs := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			addrs = append(addrs, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Warn(context.Background(), "file", r.path, "error", err, "msg", "could not read client addresses")
+		return
+	}
+	if key := strings.Join(addrs, ","); key != r.last {
+		r.last = key
+		r.cc.NewAddress(toAddresses(addrs))
+	}
+}
+
+func (r *fileResolver) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		case <-r.refresh:
+		}
+		r.resolve()
+	}
+}
+
+func (r *fileResolver) ResolveNow(resolver.ResolveNowOption) {
+	select {
+	case r.refresh <- struct{}{}:
+	default:
+	}
+}
+
+func (r *fileResolver) Close() {
+	r.once.Do(func() {
+		close(r.done)
+	})
+}
+
+func toAddresses(addrs []string) []resolver.Address {
+	addresses := make([]resolver.Address, 0, len(addrs))
+	for _, addr := range addrs {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addresses = append(addresses, resolver.Address{Addr: addr})
+		}
+	}
+	return addresses
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultRetryBackoff = 50 * time.Millisecond
+
+//RetryInterceptor retries unary calls that fail with one of the given codes, the wait between attempts
+//starts at backoff and doubles with every retry
+func RetryInterceptor(retries int, backoff time.Duration, retryCodes ...codes.Code) grpc.UnaryClientInterceptor {
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	if len(retryCodes) == 0 {
+		retryCodes = []codes.Code{codes.Unavailable}
+	}
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		wait := backoff
+		for attempt := 0; attempt < retries && err != nil && isRetriable(err, retryCodes); attempt++ {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+			wait *= 2
+			err = invoker(ctx, method, req, reply, cc, opts...)
+		}
+		return err
+	}
+}
+
+func isRetriable(err error, retryCodes []codes.Code) bool {
+	code := status.Code(err)
+	for _, c := range retryCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCodes converts status code names, e.g. 'UNAVAILABLE', to codes
+func parseCodes(names []string) ([]codes.Code, error) {
+	result := make([]codes.Code, 0, len(names))
+	for _, name := range names {
+		var c codes.Code
+		if err := c.UnmarshalJSON([]byte(strconv.Quote(strings.ToUpper(strings.TrimSpace(name))))); err != nil {
+			return nil, fmt.Errorf("unknown retry code %s", name)
+		}
+		result = append(result, c)
+	}
+	return result, nil
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/afex/hystrix-go/hystrix"
+	"github.com/go-orion/Orion/utils/accesslog"
 	"github.com/go-orion/Orion/utils/log"
 	"github.com/spf13/viper"
 )
@@ -32,6 +34,62 @@ type Config struct {
 	HotReload bool
 	//EnableProtoURL adds gRPC generated urls in HTTP handler
 	EnableProtoURL bool
+	//EnableGRPCWeb serves gRPC-Web requests in HTTP handler
+	EnableGRPCWeb bool
+	//GRPCWebAllowedOrigins are the origins allowed to call gRPC-Web methods from browsers in addition to the server's own
+	GRPCWebAllowedOrigins []string
+	//EnableH2C serves HTTP/2 over cleartext connections with prior knowledge in HTTP handler
+	EnableH2C bool
+	//HTTPRedirectTrailingSlash redirects HTTP requests with a trailing slash instead of serving both urls
+	HTTPRedirectTrailingSlash bool
+	//HTTPCaseInsensitiveRoutes matches HTTP routes regardless of case
+	HTTPCaseInsensitiveRoutes bool
+	//HTTPVersionPrefix prefixes generated urls with the version of the proto package, e.g. '/v1/'
+	HTTPVersionPrefix bool
+	//HTTPReadTimeout is the time allowed to read HTTP requests, a negative value disables the timeout
+	HTTPReadTimeout time.Duration
+	//HTTPReadHeaderTimeout is the time allowed to read HTTP request headers, a negative value disables the timeout
+	HTTPReadHeaderTimeout time.Duration
+	//HTTPWriteTimeout is the time allowed to write HTTP responses, a negative value disables the timeout
+	HTTPWriteTimeout time.Duration
+	//HTTPIdleTimeout is the time keep-alive connections are kept open between requests
+	HTTPIdleTimeout time.Duration
+	//HTTPMaxHeaderBytes is the maximum size in bytes of HTTP request headers
+	HTTPMaxHeaderBytes int
+	//HTTPCompression compresses HTTP responses with the encoding negotiated from Accept-Encoding, zstd is only available in cgo builds
+	HTTPCompression bool
+	//HTTPCompressionMinSize is the minimum size in bytes of HTTP responses that are compressed, streams are compressed
+	//regardless of their size as every frame is flushed
+	HTTPCompressionMinSize int
+	//HTTPMaxDecompressedSize is the maximum size in bytes a compressed HTTP request body can expand to
+	HTTPMaxDecompressedSize int64
+	//HTTPMaxBodySize is the maximum size in bytes of HTTP request bodies, zero means no limit
+	HTTPMaxBodySize int64
+	//HTTPETags computes entity tags for all HTTP responses and answers conditional GET requests
+	HTTPETags bool
+	//WebSocketConfig is the default configuration for websocket connections
+	WebSocketConfig WSConfig
+	//StrictRoutes makes Start fail when the HTTP route table has conflicts or registrations for unknown methods,
+	//by default the problems are logged and the server serves anyway
+	StrictRoutes bool
+	//CodecOptions are the default options used by the JSONPB codec when serializing HTTP responses
+	CodecOptions CodecOptions
+	//CacheSize is the number of responses held by the in-memory cache used by methods with the CACHE option
+	CacheSize int
+	//CacheStore is the store used for cached responses instead of the in-memory cache
+	CacheStore CacheStore
+	//GRPCReflection registers the gRPC server reflection service so that tools like grpcurl can discover services
+	GRPCReflection bool
+	//GRPCChannelz registers the channelz service on the gRPC server
+	GRPCChannelz bool
+	//GRPCAdminPort is the port reflection and channelz are served on, they are served on the gRPC port when empty
+	GRPCAdminPort string
+	//GRPCAdminAuth authorizes calls to reflection and channelz
+	GRPCAdminAuth AdminAuthFunc
+	//GRPCServerConfig is the configuration of the gRPC server, it is read again from config files on reload
+	GRPCServerConfig GRPCServerConfig
+	//Clients are the configurations of client connections to other services keyed by service name
+	Clients map[string]ClientConfig
 	//EnablePrometheus enables prometheus metric for services on path '/metrics' on pprof port
 	EnablePrometheus bool
 	//EnablePrometheusHistograms enables request histograms for services
@@ -43,6 +101,8 @@ type Config struct {
 	ZipkinConfig ZipkinConfig
 	//NewRelicConfig is the configuration options for new relic
 	NewRelicConfig NewRelicConfig
+	//AccessLogConfig is the configuration for access logs
+	AccessLogConfig AccessLogConfig
 	//RollbarToken is the token to be used in rollbar
 	RollbarToken string
 	//SentryDSN is the token used by sentry for error reporting
@@ -75,6 +135,49 @@ type NewRelicConfig struct {
 	ExcludeAttributes []string
 }
 
+//AccessLogConfig is the configuration for access logs of HTTP and gRPC requests
+type AccessLogConfig struct {
+	//Enabled writes an access log entry for requests
+	Enabled bool
+	//Fields are the fields written for each entry, all fields are written when empty
+	Fields []string
+	//SampleRate is the ratio of successful requests that are logged, failed requests are always logged
+	SampleRate float64
+	//Output is 'stdout', 'stderr' or the path of the file entries are appended to
+	Output string
+	//TrustedProxies are the addresses or CIDR networks of the proxies whose X-Forwarded-For and X-Real-IP headers
+	//are used to find the client address, the headers are ignored when empty
+	TrustedProxies []string
+	//Sink is used to write entries instead of Output when set
+	Sink accesslog.Sink
+}
+
+//GRPCServerConfig is the configuration of the gRPC server, zero values use the grpc defaults
+type GRPCServerConfig struct {
+	//MaxRecvMsgSize is the maximum size in bytes of received messages
+	MaxRecvMsgSize int
+	//MaxSendMsgSize is the maximum size in bytes of sent messages
+	MaxSendMsgSize int
+	//MaxConcurrentStreams is the maximum number of concurrent streams on each connection
+	MaxConcurrentStreams uint32
+	//KeepaliveTime is the idle time after which the server pings the client
+	KeepaliveTime time.Duration
+	//KeepaliveTimeout is the time the server waits for a ping ack before closing the connection
+	KeepaliveTimeout time.Duration
+	//MaxConnectionIdle is the time after which idle connections are closed
+	MaxConnectionIdle time.Duration
+	//MaxConnectionAge is the time after which connections are closed, so that clients reconnect and rebalance
+	MaxConnectionAge time.Duration
+	//MaxConnectionAgeGrace is the time pending calls are given to complete after MaxConnectionAge
+	MaxConnectionAgeGrace time.Duration
+	//KeepaliveMinTime is the minimum time clients should wait between pings, faster clients are disconnected
+	KeepaliveMinTime time.Duration
+	//KeepalivePermitWithoutStream allows client pings when there are no active streams
+	KeepalivePermitWithoutStream bool
+	//ServerOptions are passed as is to grpc.NewServer, they are kept on reload
+	ServerOptions []GRPCServerOption
+}
+
 //BuildDefaultConfig builds a default config object for Orion
 func BuildDefaultConfig(name string) Config {
 	setup(name)
@@ -87,6 +190,27 @@ func BuildDefaultConfig(name string) Config {
 		PProfport:                 viper.GetString("orion.PprofPort"),
 		HotReload:                 viper.GetBool("orion.HotReload"),
 		EnableProtoURL:            viper.GetBool("orion.EnableProtoURL"),
+		EnableGRPCWeb:             viper.GetBool("orion.EnableGRPCWeb"),
+		GRPCWebAllowedOrigins:     viper.GetStringSlice("orion.GRPCWebAllowedOrigins"),
+		EnableH2C:                 viper.GetBool("orion.EnableH2C"),
+		HTTPRedirectTrailingSlash: viper.GetBool("orion.HTTPRedirectTrailingSlash"),
+		HTTPCaseInsensitiveRoutes: viper.GetBool("orion.HTTPCaseInsensitiveRoutes"),
+		HTTPVersionPrefix:         viper.GetBool("orion.HTTPVersionPrefix"),
+		HTTPReadTimeout:           viper.GetDuration("orion.HTTPReadTimeout"),
+		HTTPReadHeaderTimeout:     viper.GetDuration("orion.HTTPReadHeaderTimeout"),
+		HTTPWriteTimeout:          viper.GetDuration("orion.HTTPWriteTimeout"),
+		HTTPIdleTimeout:           viper.GetDuration("orion.HTTPIdleTimeout"),
+		HTTPMaxHeaderBytes:        viper.GetInt("orion.HTTPMaxHeaderBytes"),
+		HTTPCompression:           viper.GetBool("orion.HTTPCompression"),
+		HTTPCompressionMinSize:    viper.GetInt("orion.HTTPCompressionMinSize"),
+		HTTPMaxDecompressedSize:   viper.GetInt64("orion.HTTPMaxDecompressedSize"),
+		HTTPMaxBodySize:           viper.GetInt64("orion.HTTPMaxBodySize"),
+		HTTPETags:                 viper.GetBool("orion.HTTPETags"),
+		CacheSize:                 viper.GetInt("orion.CacheSize"),
+		StrictRoutes:              viper.GetBool("orion.StrictRoutes"),
+		GRPCReflection:            viper.GetBool("orion.GRPCReflection"),
+		GRPCChannelz:              viper.GetBool("orion.GRPCChannelz"),
+		GRPCAdminPort:             viper.GetString("orion.GRPCAdminPort"),
 		EnablePrometheus:          viper.GetBool("orion.EnablePrometheus"),
 		EnablePrometheusHistogram: viper.GetBool("orion.EnablePrometheusHistogram"),
 		RollbarToken:              viper.GetString("orion.rollbar-token"),
@@ -96,6 +220,36 @@ func BuildDefaultConfig(name string) Config {
 		HystrixConfig:             BuildDefaultHystrixConfig(),
 		ZipkinConfig:              BuildDefaultZipkinConfig(),
 		NewRelicConfig:            BuildDefaultNewRelicConfig(),
+		AccessLogConfig:           BuildDefaultAccessLogConfig(),
+		GRPCServerConfig:          BuildDefaultGRPCServerConfig(),
+		Clients:                   BuildDefaultClientConfigs(),
+		WebSocketConfig:           BuildDefaultWebSocketConfig(),
+		CodecOptions:              BuildDefaultCodecOptions(),
+	}
+}
+
+//BuildDefaultWebSocketConfig builds a default config for websocket connections
+func BuildDefaultWebSocketConfig() WSConfig {
+	return WSConfig{
+		AllowedOrigins:    viper.GetStringSlice("orion.WSAllowedOrigins"),
+		Subprotocols:      viper.GetStringSlice("orion.WSSubprotocols"),
+		EnableCompression: viper.GetBool("orion.WSEnableCompression"),
+		HandshakeTimeout:  viper.GetDuration("orion.WSHandshakeTimeout"),
+		ReadBufferSize:    viper.GetInt("orion.WSReadBufferSize"),
+		WriteBufferSize:   viper.GetInt("orion.WSWriteBufferSize"),
+		ReadLimit:         viper.GetInt64("orion.WSReadLimit"),
+		PingInterval:      viper.GetDuration("orion.WSPingInterval"),
+		PongWait:          viper.GetDuration("orion.WSPongWait"),
+		MetadataFrames:    viper.GetBool("orion.WSMetadataFrames"),
+	}
+}
+
+//BuildDefaultCodecOptions builds the default options used by codecs
+func BuildDefaultCodecOptions() CodecOptions {
+	return CodecOptions{
+		EmitDefaults: viper.GetBool("orion.CodecEmitDefaults"),
+		OrigName:     viper.GetBool("orion.CodecOrigName"),
+		EnumsAsInts:  viper.GetBool("orion.CodecEnumsAsInts"),
 	}
 }
 
@@ -125,6 +279,42 @@ func BuildDefaultNewRelicConfig() NewRelicConfig {
 	}
 }
 
+//BuildDefaultAccessLogConfig builds a default config for access logs
+func BuildDefaultAccessLogConfig() AccessLogConfig {
+	return AccessLogConfig{
+		Enabled:        viper.GetBool("orion.AccessLogEnabled"),
+		Fields:         viper.GetStringSlice("orion.AccessLogFields"),
+		SampleRate:     viper.GetFloat64("orion.AccessLogSampleRate"),
+		Output:         viper.GetString("orion.AccessLogOutput"),
+		TrustedProxies: viper.GetStringSlice("orion.AccessLogTrustedProxies"),
+	}
+}
+
+//BuildDefaultGRPCServerConfig builds a default config for the gRPC server
+func BuildDefaultGRPCServerConfig() GRPCServerConfig {
+	return GRPCServerConfig{
+		MaxRecvMsgSize:               viper.GetInt("orion.GRPCMaxRecvMsgSize"),
+		MaxSendMsgSize:               viper.GetInt("orion.GRPCMaxSendMsgSize"),
+		MaxConcurrentStreams:         uint32(viper.GetInt("orion.GRPCMaxConcurrentStreams")),
+		KeepaliveTime:                viper.GetDuration("orion.GRPCKeepaliveTime"),
+		KeepaliveTimeout:             viper.GetDuration("orion.GRPCKeepaliveTimeout"),
+		MaxConnectionIdle:            viper.GetDuration("orion.GRPCMaxConnectionIdle"),
+		MaxConnectionAge:             viper.GetDuration("orion.GRPCMaxConnectionAge"),
+		MaxConnectionAgeGrace:        viper.GetDuration("orion.GRPCMaxConnectionAgeGrace"),
+		KeepaliveMinTime:             viper.GetDuration("orion.GRPCKeepaliveMinTime"),
+		KeepalivePermitWithoutStream: viper.GetBool("orion.GRPCKeepalivePermitWithoutStream"),
+	}
+}
+
+//BuildDefaultClientConfigs builds the configs of client connections from 'orion.Clients'
+func BuildDefaultClientConfigs() map[string]ClientConfig {
+	clients := make(map[string]ClientConfig)
+	if err := viper.UnmarshalKey("orion.Clients", &clients); err != nil {
+		log.Warn(context.Background(), "config", "client configs could not be read "+err.Error())
+	}
+	return clients
+}
+
 func setConfigDefaults() {
 	viper.SetDefault("orion.GRPCPort", "9281")
 	viper.SetDefault("orion.HttpPort", "9282")
@@ -133,6 +323,40 @@ func setConfigDefaults() {
 	viper.SetDefault("orion.GRPCOnly", false)
 	viper.SetDefault("orion.HTTPOnly", false)
 	viper.SetDefault("orion.EnableProtoURL", false)
+	viper.SetDefault("orion.EnableGRPCWeb", false)
+	viper.SetDefault("orion.EnableH2C", false)
+	viper.SetDefault("orion.HTTPRedirectTrailingSlash", false)
+	viper.SetDefault("orion.HTTPCaseInsensitiveRoutes", false)
+	viper.SetDefault("orion.HTTPVersionPrefix", false)
+	viper.SetDefault("orion.HTTPReadTimeout", "5s")
+	viper.SetDefault("orion.HTTPReadHeaderTimeout", "5s")
+	viper.SetDefault("orion.HTTPWriteTimeout", "10s")
+	viper.SetDefault("orion.HTTPIdleTimeout", "120s")
+	viper.SetDefault("orion.HTTPMaxHeaderBytes", 1048576)
+	viper.SetDefault("orion.HTTPCompression", false)
+	viper.SetDefault("orion.HTTPCompressionMinSize", 1024)
+	viper.SetDefault("orion.HTTPMaxDecompressedSize", 10485760)
+	// no limit by default, services can set 4194304 to match the default maximum message size of gRPC
+	viper.SetDefault("orion.HTTPMaxBodySize", 0)
+	viper.SetDefault("orion.HTTPETags", false)
+	viper.SetDefault("orion.CacheSize", 10000)
+	viper.SetDefault("orion.StrictRoutes", false)
+	viper.SetDefault("orion.GRPCReflection", false)
+	viper.SetDefault("orion.GRPCChannelz", false)
+	viper.SetDefault("orion.GRPCAdminPort", "")
+	viper.SetDefault("orion.GRPCMaxRecvMsgSize", 0)
+	viper.SetDefault("orion.GRPCMaxSendMsgSize", 0)
+	viper.SetDefault("orion.GRPCMaxConcurrentStreams", 0)
+	viper.SetDefault("orion.GRPCKeepaliveTime", 0)
+	viper.SetDefault("orion.GRPCKeepaliveTimeout", 0)
+	viper.SetDefault("orion.GRPCMaxConnectionIdle", 0)
+	viper.SetDefault("orion.GRPCMaxConnectionAge", 0)
+	viper.SetDefault("orion.GRPCMaxConnectionAgeGrace", 0)
+	viper.SetDefault("orion.GRPCKeepaliveMinTime", 0)
+	viper.SetDefault("orion.GRPCKeepalivePermitWithoutStream", false)
+	viper.SetDefault("orion.AccessLogEnabled", false)
+	viper.SetDefault("orion.AccessLogSampleRate", 1.0)
+	viper.SetDefault("orion.AccessLogOutput", "stdout")
 	viper.SetDefault("orion.ZipkinAddr", "")
 	viper.SetDefault("orion.env", "dev")
 	viper.SetDefault("orion.rollbar-token", "")
@@ -140,6 +364,15 @@ func setConfigDefaults() {
 	viper.SetDefault("orion.EnablePrometheus", true)
 	viper.SetDefault("orion.EnablePrometheusHistogram", false)
 	viper.SetDefault("orion.Env", "development")
+	viper.SetDefault("orion.WSHandshakeTimeout", "2s")
+	viper.SetDefault("orion.WSReadBufferSize", 1024)
+	viper.SetDefault("orion.WSWriteBufferSize", 1024)
+	viper.SetDefault("orion.WSEnableCompression", false)
+	viper.SetDefault("orion.WSPingInterval", "0s")
+	viper.SetDefault("orion.WSMetadataFrames", false)
+	viper.SetDefault("orion.CodecEmitDefaults", false)
+	viper.SetDefault("orion.CodecOrigName", false)
+	viper.SetDefault("orion.CodecEnumsAsInts", false)
 }
 
 // sets up the config parser
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	nethttp "net/http"
 	"os"
 	"os/signal"
 	"reflect"
@@ -11,14 +12,17 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/go-orion/Orion/orion/client"
 	"github.com/go-orion/Orion/orion/handlers"
 	grpcHandler "github.com/go-orion/Orion/orion/handlers/grpc"
 	"github.com/go-orion/Orion/orion/handlers/http"
+	"github.com/go-orion/Orion/utils/cache"
 	"github.com/go-orion/Orion/utils/errors/notifier"
 	"github.com/go-orion/Orion/utils/listenerutils"
 	"github.com/go-orion/Orion/utils/log"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
 )
 
 var (
@@ -35,8 +39,9 @@ type svcInfo struct {
 }
 
 type handlerInfo struct {
-	handler  handlers.Handler
-	listener listenerutils.CustomListener
+	handler       handlers.Handler
+	listener      listenerutils.CustomListener
+	adminListener listenerutils.CustomListener
 }
 
 type encoderInfo struct {
@@ -66,6 +71,24 @@ type middlewareInfo struct {
 	middlewares []string
 }
 
+type wsInfo struct {
+	serviceName string
+	method      string
+	config      *WSConfig
+	upgrader    WSUpgrader
+}
+
+type mountInfo struct {
+	prefix  string
+	handler nethttp.Handler
+}
+
+type fileSinkInfo struct {
+	serviceName string
+	method      string
+	sink        FileSink
+}
+
 //DefaultServerImpl provides a default implementation of orion.Server this can be embedded in custom orion.Server implementations
 type DefaultServerImpl struct {
 	config Config
@@ -80,9 +103,17 @@ type DefaultServerImpl struct {
 	defEncoders  map[string]handlers.Encoder
 	options      map[string]*optionInfo
 	middlewares  map[string]*middlewareInfo
+	wsInfos      map[string]*wsInfo
+	defWSInfos   map[string]*wsInfo
+	fileSinks    map[string]*fileSinkInfo
+	mounts       []*mountInfo
+	cacheStore   cache.Store
+	cacheConfigs handlers.CacheConfigs
 	handlers     []*handlerInfo
 	initializers []Initializer
 	version      uint64
+	clients      *client.Manager
+	clientsOnce  sync.Once
 }
 
 //AddMiddleware adds middlewares for particular service/method
@@ -182,13 +213,78 @@ func (d *DefaultServerImpl) AddOption(serviceName, method, option string) {
 	if d.options == nil {
 		d.options = make(map[string]*optionInfo)
 	}
-	d.options[serviceName+":"+method] = &optionInfo{
+	d.options[serviceName+":"+method+":"+option] = &optionInfo{
 		serviceName: serviceName,
 		method:      method,
 		option:      option,
 	}
 }
 
+func getWSInfo(infos map[string]*wsInfo, serviceName, method string) *wsInfo {
+	key := getSvcKey(serviceName, method)
+	info, ok := infos[key]
+	if !ok {
+		info = &wsInfo{
+			serviceName: serviceName,
+			method:      method,
+		}
+		infos[key] = info
+	}
+	return info
+}
+
+//AddWSConfig is the implementation of handlers.WSConfigurable
+func (d *DefaultServerImpl) AddWSConfig(serviceName, method string, config WSConfig) {
+	if d.wsInfos == nil {
+		d.wsInfos = make(map[string]*wsInfo)
+	}
+	getWSInfo(d.wsInfos, serviceName, method).config = &config
+}
+
+//AddDefaultWSConfig is the implementation of handlers.WSConfigurable
+func (d *DefaultServerImpl) AddDefaultWSConfig(serviceName string, config WSConfig) {
+	if d.defWSInfos == nil {
+		d.defWSInfos = make(map[string]*wsInfo)
+	}
+	getWSInfo(d.defWSInfos, serviceName, "").config = &config
+}
+
+//AddWSUpgrader is the implementation of handlers.WSConfigurable
+func (d *DefaultServerImpl) AddWSUpgrader(serviceName, method string, upgrader WSUpgrader) {
+	if d.wsInfos == nil {
+		d.wsInfos = make(map[string]*wsInfo)
+	}
+	getWSInfo(d.wsInfos, serviceName, method).upgrader = upgrader
+}
+
+//AddDefaultWSUpgrader is the implementation of handlers.WSConfigurable
+func (d *DefaultServerImpl) AddDefaultWSUpgrader(serviceName string, upgrader WSUpgrader) {
+	if d.defWSInfos == nil {
+		d.defWSInfos = make(map[string]*wsInfo)
+	}
+	getWSInfo(d.defWSInfos, serviceName, "").upgrader = upgrader
+}
+
+//AddFileSink is the implementation of handlers.FileSinkable
+func (d *DefaultServerImpl) AddFileSink(serviceName, method string, sink FileSink) {
+	if d.fileSinks == nil {
+		d.fileSinks = make(map[string]*fileSinkInfo)
+	}
+	d.fileSinks[getSvcKey(serviceName, method)] = &fileSinkInfo{
+		serviceName: serviceName,
+		method:      method,
+		sink:        sink,
+	}
+}
+
+//AddMount is the implementation of handlers.Mountable
+func (d *DefaultServerImpl) AddMount(prefix string, handler nethttp.Handler) {
+	d.mounts = append(d.mounts, &mountInfo{
+		prefix:  prefix,
+		handler: handler,
+	})
+}
+
 //GetOrionConfig returns current orion config
 //NOTE: this config can not be modifies
 func (d *DefaultServerImpl) GetOrionConfig() Config {
@@ -228,8 +324,23 @@ func (d *DefaultServerImpl) processInitializers(reload bool) {
 	}
 }
 
+// getCacheStore returns the configured cache store, the in-memory cache is kept across reloads
+func (d *DefaultServerImpl) getCacheStore() cache.Store {
+	if d.config.CacheStore != nil {
+		return d.config.CacheStore
+	}
+	if d.cacheStore == nil {
+		d.cacheStore = cache.NewLRUStore(d.config.CacheSize)
+	}
+	return d.cacheStore
+}
+
 func (d *DefaultServerImpl) buildHandlers() []*handlerInfo {
 	hlrs := []*handlerInfo{}
+	commonConfig := handlers.CommonConfig{
+		CacheStore:   d.getCacheStore(),
+		CacheConfigs: &d.cacheConfigs,
+	}
 	if !d.config.GRPCOnly {
 		httpPort := d.config.HTTPPort
 		httpListener, err := listenerutils.NewListener("tcp", ":"+httpPort)
@@ -238,7 +349,26 @@ func (d *DefaultServerImpl) buildHandlers() []*handlerInfo {
 		}
 		log.Info(context.Background(), "HTTPListnerPort", httpPort)
 		config := http.Config{
-			EnableProtoURL: d.config.EnableProtoURL,
+			CommonConfig:          commonConfig,
+			EnableProtoURL:        d.config.EnableProtoURL,
+			EnableGRPCWeb:         d.config.EnableGRPCWeb,
+			GRPCWebAllowedOrigins: d.config.GRPCWebAllowedOrigins,
+			EnableH2C:             d.config.EnableH2C,
+			RedirectTrailingSlash: d.config.HTTPRedirectTrailingSlash,
+			CaseInsensitiveRoutes: d.config.HTTPCaseInsensitiveRoutes,
+			VersionPrefix:         d.config.HTTPVersionPrefix,
+			ReadTimeout:           d.config.HTTPReadTimeout,
+			ReadHeaderTimeout:     d.config.HTTPReadHeaderTimeout,
+			WriteTimeout:          d.config.HTTPWriteTimeout,
+			IdleTimeout:           d.config.HTTPIdleTimeout,
+			MaxHeaderBytes:        d.config.HTTPMaxHeaderBytes,
+			EnableCompression:     d.config.HTTPCompression,
+			CompressionMinSize:    d.config.HTTPCompressionMinSize,
+			MaxDecompressedSize:   d.config.HTTPMaxDecompressedSize,
+			MaxBodySize:           d.config.HTTPMaxBodySize,
+			EnableETags:           d.config.HTTPETags,
+			WebSocket:             d.config.WebSocketConfig,
+			Codec:                 d.config.CodecOptions,
 		}
 		handler := http.NewHTTPHandler(config)
 		hlrs = append(hlrs, &handlerInfo{
@@ -253,15 +383,64 @@ func (d *DefaultServerImpl) buildHandlers() []*handlerInfo {
 			log.Info(context.Background(), "grpcListener", "could not create listener", "error", err)
 		}
 		log.Info(context.Background(), "gRPCListnerPort", grpcPort)
-		handler := grpcHandler.NewGRPCHandler(grpcHandler.Config{})
+		var adminListener listenerutils.CustomListener
+		if adminPort := d.config.GRPCAdminPort; adminPort != "" {
+			adminListener, err = listenerutils.NewListener("tcp", ":"+adminPort)
+			if err != nil {
+				log.Error(context.Background(), "grpcAdminListener", "could not create listener", "error", err)
+			}
+			log.Info(context.Background(), "gRPCAdminListnerPort", adminPort)
+		}
+		handler := grpcHandler.NewGRPCHandler(grpcHandler.Config{
+			CommonConfig:     commonConfig,
+			EnableReflection: d.config.GRPCReflection,
+			EnableChannelz:   d.config.GRPCChannelz,
+			AdminOnly:        d.config.GRPCAdminPort != "",
+			AdminAuth:        d.config.GRPCAdminAuth,
+			Server:           d.grpcServerConfig,
+		})
 		hlrs = append(hlrs, &handlerInfo{
-			handler:  handler,
-			listener: grpcListener,
+			handler:       handler,
+			listener:      grpcListener,
+			adminListener: adminListener,
 		})
 	}
 	return hlrs
 }
 
+// grpcServerConfig returns the current settings of the gRPC server
+func (d *DefaultServerImpl) grpcServerConfig() grpcHandler.ServerConfig {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cfg := d.config.GRPCServerConfig
+	return grpcHandler.ServerConfig{
+		MaxRecvMsgSize:       cfg.MaxRecvMsgSize,
+		MaxSendMsgSize:       cfg.MaxSendMsgSize,
+		MaxConcurrentStreams: cfg.MaxConcurrentStreams,
+		Keepalive: keepalive.ServerParameters{
+			Time:                  cfg.KeepaliveTime,
+			Timeout:               cfg.KeepaliveTimeout,
+			MaxConnectionIdle:     cfg.MaxConnectionIdle,
+			MaxConnectionAge:      cfg.MaxConnectionAge,
+			MaxConnectionAgeGrace: cfg.MaxConnectionAgeGrace,
+		},
+		KeepaliveEnforcement: keepalive.EnforcementPolicy{
+			MinTime:             cfg.KeepaliveMinTime,
+			PermitWithoutStream: cfg.KeepalivePermitWithoutStream,
+		},
+		ServerOptions: cfg.ServerOptions,
+	}
+}
+
+// reloadGRPCServerConfig reads the gRPC server settings again from config files, server options set in code are kept
+func (d *DefaultServerImpl) reloadGRPCServerConfig() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	opts := d.config.GRPCServerConfig.ServerOptions
+	d.config.GRPCServerConfig = BuildDefaultGRPCServerConfig()
+	d.config.GRPCServerConfig.ServerOptions = opts
+}
+
 func (d *DefaultServerImpl) initHandlers() {
 	d.handlers = d.buildHandlers()
 }
@@ -282,6 +461,9 @@ func (d *DefaultServerImpl) signalWatcher() {
 				continue
 			}
 
+			// gRPC server settings are applied when the server is created again
+			d.reloadGRPCServerConfig()
+
 			// reload initializers
 			d.processInitializers(true)
 
@@ -291,6 +473,7 @@ func (d *DefaultServerImpl) signalWatcher() {
 				d.registerService(info.sd, info.sf, true)
 				oldServices = append(oldServices, info)
 			}
+			d.registerCacheOptions()
 
 			// reload handlers
 			for _, h := range d.handlers {
@@ -317,26 +500,80 @@ func (d *DefaultServerImpl) signalWatcher() {
 	}
 }
 
-//Start starts the orion server
-func (d *DefaultServerImpl) Start() {
+//Start starts the orion server, with StrictRoutes it does not serve and returns the problems of the HTTP route table
+func (d *DefaultServerImpl) Start() error {
 	fmt.Println(BANNER)
 	if d.config.HTTPOnly && d.config.GRPCOnly {
 		panic("Error: at least one GRPC or HTTP server needs to be initialized")
 	}
 
+	d.registerCacheOptions()
+	// all handlers are validated before any of them serves, so that a server with an invalid route table is not
+	// partially started
 	for _, h := range d.handlers {
-		d.startHandler(h, false)
+		if err := d.prepareHandler(h, false); err != nil {
+			if !d.config.StrictRoutes {
+				log.Warn(context.Background(), "error", err.Error())
+				continue
+			}
+			log.Error(context.Background(), "error", err.Error(), "msg", "not starting server with StrictRoutes")
+			notifier.NotifyWithLevel(err, "critical")
+			for _, h := range d.handlers {
+				h.listener.CanClose(true)
+				h.listener.Close()
+				if h.adminListener != nil {
+					h.adminListener.CanClose(true)
+					h.adminListener.Close()
+				}
+			}
+			return err
+		}
+	}
+	for _, h := range d.handlers {
+		d.runHandler(h)
 	}
 	if d.config.HotReload {
 		go d.signalWatcher()
 	}
+	return nil
 }
 
+// registerCacheOptions registers the cache configs of all methods with the CACHE option before the interceptors are built,
+// the configs registered before, e.g. by the services replaced on reload, are removed
+func (d *DefaultServerImpl) registerCacheOptions() {
+	d.cacheConfigs.Reset()
+	for _, oi := range d.options {
+		info, ok := d.services[oi.serviceName]
+		if !ok {
+			continue
+		}
+		if err := d.cacheConfigs.Register(info.ss, oi.serviceName, oi.method, oi.option); err != nil {
+			log.Error(context.Background(), "cache", "invalid cache option", "option", oi.option, "error", err)
+			notifier.NotifyWithLevel(err, "critical")
+		}
+	}
+}
+
+// startHandler restarts a handler on reload, problems of the route table are logged as the server is already running
 func (d *DefaultServerImpl) startHandler(h *handlerInfo, reload bool) {
+	if err := d.prepareHandler(h, reload); err != nil {
+		log.Warn(context.Background(), "error", err.Error())
+	}
+	d.runHandler(h)
+}
+
+// prepareHandler adds all registrations to a handler and validates them
+func (d *DefaultServerImpl) prepareHandler(h *handlerInfo, reload bool) error {
 	if reload {
 		h.listener.StopAccept()
+		if h.adminListener != nil {
+			h.adminListener.StopAccept()
+		}
 		h.handler.Stop(time.Second * 1)
 		h.listener = h.listener.GetListener()
+		if h.adminListener != nil {
+			h.adminListener = h.adminListener.GetListener()
+		}
 	}
 
 	//Add all services first
@@ -391,11 +628,61 @@ func (d *DefaultServerImpl) startHandler(h *handlerInfo, reload bool) {
 		}
 	}
 
+	// Add all websocket configs and upgraders
+	if e, ok := h.handler.(handlers.WSConfigurable); ok {
+		for _, wi := range d.wsInfos {
+			if wi.config != nil {
+				e.AddWSConfig(wi.serviceName, wi.method, *wi.config)
+			}
+			if wi.upgrader != nil {
+				e.AddWSUpgrader(wi.serviceName, wi.method, wi.upgrader)
+			}
+		}
+		for _, wi := range d.defWSInfos {
+			if wi.config != nil {
+				e.AddDefaultWSConfig(wi.serviceName, *wi.config)
+			}
+			if wi.upgrader != nil {
+				e.AddDefaultWSUpgrader(wi.serviceName, wi.upgrader)
+			}
+		}
+	}
+
+	// Add all file sinks
+	if e, ok := h.handler.(handlers.FileSinkable); ok {
+		for _, fi := range d.fileSinks {
+			e.AddFileSink(fi.serviceName, fi.method, fi.sink)
+		}
+	}
+
+	// Add all mounts
+	if e, ok := h.handler.(handlers.Mountable); ok {
+		for _, mi := range d.mounts {
+			e.AddMount(mi.prefix, mi.handler)
+		}
+	}
+
+	// validate all registrations before serving
+	if v, ok := h.handler.(handlers.Validatable); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// runHandler serves a prepared handler
+func (d *DefaultServerImpl) runHandler(h *handlerInfo) {
 	d.wg.Add(1)
 	go func(d *DefaultServerImpl, h *handlerInfo) {
 		defer d.wg.Done()
 		h.handler.Run(h.listener)
 	}(d, h)
+	if a, ok := h.handler.(handlers.AdminServable); ok && h.adminListener != nil {
+		d.wg.Add(1)
+		go func(d *DefaultServerImpl, h *handlerInfo) {
+			defer d.wg.Done()
+			a.RunAdmin(h.adminListener)
+		}(d, h)
+	}
 }
 
 // Wait waits for all the serving servers to quit
@@ -465,6 +752,23 @@ func (d *DefaultServerImpl) GetConfig() map[string]interface{} {
 	return viper.AllSettings()
 }
 
+//GetClientConn returns the connection to a service configured in Clients, connections are closed on Stop
+func (d *DefaultServerImpl) GetClientConn(name string) (*grpc.ClientConn, error) {
+	return d.clientManager().Conn(name)
+}
+
+//GetClientInvoker returns the invoker for a service configured in Clients using the transport of its configuration
+func (d *DefaultServerImpl) GetClientInvoker(name string) (ClientInvoker, error) {
+	return d.clientManager().Invoker(name)
+}
+
+func (d *DefaultServerImpl) clientManager() *client.Manager {
+	d.clientsOnce.Do(func() {
+		d.clients = client.NewManager(d.config.Clients)
+	})
+	return d.clients
+}
+
 //Stop stops the server
 func (d *DefaultServerImpl) Stop(timeout time.Duration) error {
 	var wg sync.WaitGroup
@@ -476,6 +780,11 @@ func (d *DefaultServerImpl) Stop(timeout time.Duration) error {
 		}(h, timeout)
 	}
 	wg.Wait()
+	d.cacheConfigs.Reset()
+	// client connections are closed after the handlers so that pending calls can complete
+	if err := d.clientManager().Close(); err != nil {
+		log.Warn(context.Background(), "error", err.Error())
+	}
 	return nil
 }
 
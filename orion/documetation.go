@@ -75,6 +75,17 @@ This contains:
 	An interface type (or stub) for clients to call with the methods defined in the SimpleService (simple.pb.go)
 	An interface type for servers to implement, also with the methods defined in the SimpleService (simple.pb.go)
 	Registration function for Orion (simple.proto.orion.pb.go)
+	A client calling the SimpleService over gRPC or HTTP through an Orion invoker (simple.proto.orion.pb.go)
+Passing 'openapi=true' to the plugin, e.g. '--orion_out=openapi=true:.', also generates an OpenAPI v3 document for
+every service (simple.simpleservice.openapi.json), it can be served by the HTTP handler with RegisterSimpleServiceOpenAPI.
+Add 'openapi_version_prefix=true' when the server is run with the VersionPrefix config.
+HTTP routes, options and middlewares are set with 'ORION:URL:', 'ORION:OPTION:' and 'ORION:MIDDLEWARE:' comments
+or with the (orion.method) and (orion.service) options of orion/options/options.proto, e.g.
+	option (orion.method) = { http: { methods: ["GET"] path: "/api/1.0/upper/{msg}" } };
+Unknown or malformed annotations fail the generation.
+The plugin places the generated files like protoc-gen-go and supports its 'paths=source_relative', 'import_path'
+and 'M<file>=<import path>' parameters, pass the same parameters to both plugins, e.g.
+	protoc -I . simple.proto --go_out=plugins=grpc,paths=source_relative:. --orion_out=paths=source_relative:.
 
 Whats Incuded
 
@@ -1,7 +1,12 @@
 package orion
 
 import (
+	"fmt"
+	"net/http"
+
 	"github.com/go-orion/Orion/orion/handlers"
+	httphandler "github.com/go-orion/Orion/orion/handlers/http"
+	"google.golang.org/grpc"
 )
 
 //RegisterEncoder allows for registering an HTTP request encoder to arbitrary urls
@@ -67,3 +72,79 @@ func RegisterMiddleware(svr Server, serviceName, method string, middleware ...st
 		e.AddMiddleware(serviceName, method, middleware...)
 	}
 }
+
+//RegisterWSUpgrader allows for registering a websocket upgrader to a particular stream method
+func RegisterWSUpgrader(svr Server, serviceName, method string, upgrader WSUpgrader) {
+	if e, ok := svr.(handlers.WSConfigurable); ok {
+		e.AddWSUpgrader(serviceName, method, upgrader)
+	}
+}
+
+//RegisterDefaultWSUpgrader allows for registering a websocket upgrader for all stream methods in a service
+func RegisterDefaultWSUpgrader(svr Server, serviceName string, upgrader WSUpgrader) {
+	if e, ok := svr.(handlers.WSConfigurable); ok {
+		e.AddDefaultWSUpgrader(serviceName, upgrader)
+	}
+}
+
+//RegisterWSConfig allows for registering websocket configuration to a particular stream method
+func RegisterWSConfig(svr Server, serviceName, method string, config WSConfig) {
+	if e, ok := svr.(handlers.WSConfigurable); ok {
+		e.AddWSConfig(serviceName, method, config)
+	}
+}
+
+//RegisterDefaultWSConfig allows for registering websocket configuration for all stream methods in a service
+func RegisterDefaultWSConfig(svr Server, serviceName string, config WSConfig) {
+	if e, ok := svr.(handlers.WSConfigurable); ok {
+		e.AddDefaultWSConfig(serviceName, config)
+	}
+}
+
+//RegisterFileSink allows for registering a sink that receives the files uploaded to a particular method
+func RegisterFileSink(svr Server, serviceName, method string, sink FileSink) {
+	if e, ok := svr.(handlers.FileSinkable); ok {
+		e.AddFileSink(serviceName, method, sink)
+	}
+}
+
+//RegisterMount allows for serving an http handler for all paths under a prefix alongside the service routes,
+//the handler receives the full request path. Prefixes match whole path segments, '/admin' serves '/admin' and
+//'/admin/users' but not '/administrator'
+func RegisterMount(svr Server, prefix string, handler http.Handler) {
+	if e, ok := svr.(handlers.Mountable); ok {
+		e.AddMount(prefix, handler)
+	}
+}
+
+//RegisterFileServer allows for serving static files under a prefix, use http.Dir for directories and http.FS for embedded assets
+func RegisterFileServer(svr Server, prefix string, fs http.FileSystem) {
+	RegisterMount(svr, prefix, httphandler.FileServer(prefix, fs))
+}
+
+//RegisterSPA allows for serving a single page application under a prefix, paths that do not match a file serve index.html
+func RegisterSPA(svr Server, prefix string, fs http.FileSystem) {
+	RegisterMount(svr, prefix, httphandler.SPAServer(prefix, fs))
+}
+
+//RegisterOpenAPI allows for serving an OpenAPI document at path, see the generated Register<Service>OpenAPI functions
+func RegisterOpenAPI(svr Server, path string, spec []byte) {
+	RegisterMount(svr, path, httphandler.OpenAPIServer(path, spec))
+}
+
+//GetClientConn returns the connection to a service configured in 'orion.Clients'
+func GetClientConn(svr Server, name string) (*grpc.ClientConn, error) {
+	if p, ok := svr.(ClientProvider); ok {
+		return p.GetClientConn(name)
+	}
+	return nil, fmt.Errorf("server does not manage client connections, could not connect to %s", name)
+}
+
+//GetClientInvoker returns the invoker for a service configured in 'orion.Clients', it can be passed to generated
+//Orion clients to call the service over gRPC or HTTP
+func GetClientInvoker(svr Server, name string) (ClientInvoker, error) {
+	if p, ok := svr.(ClientProvider); ok {
+		return p.GetClientInvoker(name)
+	}
+	return nil, fmt.Errorf("server does not manage client connections, could not connect to %s", name)
+}
@@ -0,0 +1,480 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-orion/Orion/orion/modifiers"
+	"github.com/go-orion/Orion/utils/cache"
+	"github.com/go-orion/Orion/utils/errors"
+	"github.com/go-orion/Orion/utils/headers"
+	"github.com/go-orion/Orion/utils/log"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	//CacheOption is the option used to cache responses of idempotent methods, e.g. 'CACHE TTL=30S HEADERS=X-USER-ID,ACCEPT-LANGUAGE'
+	CacheOption = "CACHE"
+	//DefaultCacheTTL is the time responses are cached for when the CACHE option has no ttl
+	DefaultCacheTTL = time.Minute
+)
+
+var (
+	// inflight coalesces concurrent identical requests
+	inflight = &callGroup{calls: make(map[string]*call)}
+	// cachedHeaders are the response headers recorded with every cached response, next to the response headers
+	// whitelisted by the service, they are the headers whitelisted by default by the HTTP handler
+	cachedHeaders = []string{"Content-Type", "Cache-Control", "Vary", "ETag", "Last-Modified", "Expires"}
+	// uncachedHeaders are never recorded, as headers or as header metadata, as they belong to a single client
+	uncachedHeaders = map[string]bool{
+		"Set-Cookie":          true,
+		"Set-Cookie2":         true,
+		"Authorization":       true,
+		"Proxy-Authorization": true,
+		"Www-Authenticate":    true,
+		"Proxy-Authenticate":  true,
+	}
+
+	errInvalidResponseType = errors.New("could not find the response type of the method")
+)
+
+//CacheConfig is the cache configuration of a method
+type CacheConfig struct {
+	//TTL is the time responses are cached for
+	TTL time.Duration
+	//Headers are the request headers that are part of the cache key
+	Headers []string
+}
+
+//ParseCacheOption parses a CACHE option, ok is false if the option is not a CACHE option
+func ParseCacheOption(option string) (CacheConfig, bool) {
+	fields := strings.Fields(option)
+	if len(fields) == 0 || strings.ToUpper(fields[0]) != CacheOption {
+		return CacheConfig{}, false
+	}
+	config := CacheConfig{TTL: DefaultCacheTTL}
+	for _, field := range fields[1:] {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch strings.ToUpper(parts[0]) {
+		case "TTL":
+			ttl, err := time.ParseDuration(strings.ToLower(parts[1]))
+			if err != nil || ttl <= 0 {
+				log.Error(context.Background(), "cache", "invalid ttl", "option", option, "error", err)
+				continue
+			}
+			config.TTL = ttl
+		case "HEADERS":
+			for _, hdr := range strings.Split(parts[1], ",") {
+				if hdr = strings.TrimSpace(hdr); hdr != "" {
+					config.Headers = append(config.Headers, http.CanonicalHeaderKey(hdr))
+				}
+			}
+		}
+	}
+	return config, true
+}
+
+func getCacheConfig(options []string) (CacheConfig, bool) {
+	for _, opt := range options {
+		if config, ok := ParseCacheOption(opt); ok {
+			return config, true
+		}
+	}
+	return CacheConfig{}, false
+}
+
+//CacheConfigs are the cache configs of the methods of a server, they are used to build the keys of other methods
+//when invalidating cached responses
+type CacheConfigs struct {
+	mu      sync.RWMutex
+	configs map[string]CacheConfig
+}
+
+//Register registers the cache config of a method when option is a CACHE option, configs are registered
+//before serving so that modifiers.InvalidateCache can build the keys of methods that have not been called yet.
+//The headers of the cache key must be whitelisted by the service, see WhitelistedHeaders
+func (c *CacheConfigs) Register(svc interface{}, serviceName, method, option string) error {
+	config, ok := ParseCacheOption(option)
+	if !ok {
+		return nil
+	}
+	if err := validateCacheHeaders(svc, config); err != nil {
+		return errors.Wrap(err, "caching disabled for "+serviceName+"/"+method)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.configs == nil {
+		c.configs = make(map[string]CacheConfig)
+	}
+	c.configs["/"+serviceName+"/"+method] = config
+	return nil
+}
+
+//Reset removes all registered configs, e.g. before the options are registered again on reload
+func (c *CacheConfigs) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.configs = nil
+}
+
+// get returns the config of a full method name, e.g. '/pkg.Service/Method'
+func (c *CacheConfigs) get(fullMethod string) (CacheConfig, bool) {
+	if c == nil {
+		return CacheConfig{}, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	config, ok := c.configs[fullMethod]
+	return config, ok
+}
+
+// empty returns true when no method of this server is cached
+func (c *CacheConfigs) empty() bool {
+	if c == nil {
+		return true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.configs) == 0
+}
+
+// validateCacheHeaders rejects cache key headers that are not whitelisted request headers of the service
+func validateCacheHeaders(svc interface{}, config CacheConfig) error {
+	whitelisted := make(map[string]bool)
+	if wh, ok := svc.(WhitelistedHeaders); ok {
+		for _, hdr := range wh.GetRequestHeaders() {
+			whitelisted[http.CanonicalHeaderKey(hdr)] = true
+		}
+	}
+	missing := make([]string, 0)
+	for _, hdr := range config.Headers {
+		if !whitelisted[hdr] {
+			missing = append(missing, hdr)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.New("cache key headers are not whitelisted request headers: " + strings.Join(missing, ","))
+	}
+	return nil
+}
+
+// cacheInterceptor caches successful responses of a method with the CACHE option and applies
+// invalidations requested through modifiers.InvalidateCache
+func cacheInterceptor(store cache.Store, configs *CacheConfigs, config CacheConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var resp interface{}
+		var err error
+		if modifiers.IsCacheBypassed(ctx) {
+			resp, err = handler(ctx, req)
+		} else {
+			resp, err = cachedCall(ctx, store, config, req, info, handler)
+		}
+		if err == nil {
+			invalidate(ctx, store, configs)
+		}
+		return resp, err
+	}
+}
+
+// invalidationInterceptor applies the invalidations requested through modifiers.InvalidateCache by methods that are not cached
+func invalidationInterceptor(store cache.Store, configs *CacheConfigs) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err == nil {
+			invalidate(ctx, store, configs)
+		}
+		return resp, err
+	}
+}
+
+// cacheEntry is a cached response with the response headers and the header metadata set by the service
+type cacheEntry struct {
+	Response []byte      `json:"response"`
+	Headers  http.Header `json:"headers,omitempty"`
+	Metadata metadata.MD `json:"metadata,omitempty"`
+}
+
+// replay sets the cached response headers and header metadata on a request served from the cache
+func (e *cacheEntry) replay(ctx context.Context) {
+	if hdrs := headers.ResponseHeadersFromContext(ctx); hdrs != nil {
+		for key, values := range e.Headers {
+			hdrs[key] = append([]string{}, values...)
+		}
+	}
+	if len(e.Metadata) > 0 {
+		if err := grpc.SetHeader(ctx, e.Metadata.Copy()); err != nil {
+			log.Warn(ctx, "cache", "could not set cached header metadata", "error", err)
+		}
+	}
+}
+
+func cachedCall(ctx context.Context, store cache.Store, config CacheConfig, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	key, ok := cacheKey(ctx, info.FullMethod, req, config.Headers)
+	if !ok {
+		return handler(ctx, req)
+	}
+	data, found, err := store.Get(ctx, key)
+	if err != nil {
+		log.Warn(ctx, "cache", "could not fetch response", "error", err)
+	}
+	if found {
+		if resp, err := cachedResponse(ctx, info, data); err == nil {
+			return resp, nil
+		}
+		log.Warn(ctx, "cache", "could not decode cached response", "error", err)
+	}
+	var resp interface{}
+	data, leader, err := inflight.do(ctx, key, func() ([]byte, error) {
+		var entry *cacheEntry
+		resp, entry, err = recordCall(ctx, req, info, handler)
+		if err != nil || entry == nil {
+			return nil, err
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			log.Warn(ctx, "cache", "could not encode response", "error", err)
+			return nil, nil
+		}
+		if !modifiers.IsCacheBypassed(ctx) {
+			if err := store.Set(ctx, key, data, config.TTL); err != nil {
+				log.Warn(ctx, "cache", "could not store response", "error", err)
+			}
+		}
+		return data, nil
+	})
+	if leader || err != nil {
+		return resp, err
+	}
+	if data == nil {
+		// the response can not be shared, call the handler for this request
+		return handler(ctx, req)
+	}
+	return cachedResponse(ctx, info, data)
+}
+
+// recordCall calls the handler and records the response with the response headers and header metadata it set,
+// only the response headers whitelisted by the service or by default are recorded. Entry is nil when the response
+// can not be cached
+func recordCall(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, *cacheEntry, error) {
+	before := cloneHeader(headers.ResponseHeadersFromContext(ctx))
+	stream := &headerRecorder{stream: grpc.ServerTransportStreamFromContext(ctx)}
+	resp, err := handler(grpc.NewContextWithServerTransportStream(ctx, stream), req)
+	if err != nil {
+		return resp, nil, err
+	}
+	protoMsg, ok := resp.(proto.Message)
+	if !ok {
+		return resp, nil, nil
+	}
+	data, err := proto.Marshal(protoMsg)
+	if err != nil {
+		log.Warn(ctx, "cache", "could not encode response", "error", err)
+		return resp, nil, nil
+	}
+	entry := &cacheEntry{
+		Response: data,
+		Headers:  make(http.Header),
+		Metadata: make(metadata.MD),
+	}
+	whitelisted := cachedResponseHeaders(info.Server)
+	for key, values := range headers.ResponseHeadersFromContext(ctx) {
+		if whitelisted[http.CanonicalHeaderKey(key)] && !reflect.DeepEqual(before[key], values) {
+			entry.Headers[key] = append([]string{}, values...)
+		}
+	}
+	for key, values := range stream.md {
+		if !uncachedHeaders[http.CanonicalHeaderKey(key)] {
+			entry.Metadata[key] = values
+		}
+	}
+	return resp, entry, nil
+}
+
+// cachedResponseHeaders returns the canonical names of the response headers recorded for the responses of svc
+func cachedResponseHeaders(svc interface{}) map[string]bool {
+	names := cachedHeaders
+	if wh, ok := svc.(WhitelistedHeaders); ok {
+		names = append(wh.GetResponseHeaders(), names...)
+	}
+	whitelisted := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name = http.CanonicalHeaderKey(name); !uncachedHeaders[name] {
+			whitelisted[name] = true
+		}
+	}
+	return whitelisted
+}
+
+// cachedResponse decodes a cached entry and replays its headers on the request
+func cachedResponse(ctx context.Context, info *grpc.UnaryServerInfo, data []byte) (interface{}, error) {
+	entry := new(cacheEntry)
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, err
+	}
+	resp, err := newResponse(info, entry.Response)
+	if err != nil {
+		return nil, err
+	}
+	entry.replay(ctx)
+	return resp, nil
+}
+
+func cloneHeader(h http.Header) http.Header {
+	clone := make(http.Header, len(h))
+	for key, values := range h {
+		clone[key] = append([]string{}, values...)
+	}
+	return clone
+}
+
+// headerRecorder records the header metadata set by a handler and passes it to the transport stream of the call
+type headerRecorder struct {
+	stream grpc.ServerTransportStream
+	mu     sync.Mutex
+	md     metadata.MD
+}
+
+func (r *headerRecorder) Method() string {
+	if r.stream == nil {
+		return ""
+	}
+	return r.stream.Method()
+}
+
+func (r *headerRecorder) record(md metadata.MD) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.md = metadata.Join(r.md, md)
+}
+
+func (r *headerRecorder) SetHeader(md metadata.MD) error {
+	r.record(md)
+	if r.stream == nil {
+		return nil
+	}
+	return r.stream.SetHeader(md)
+}
+
+func (r *headerRecorder) SendHeader(md metadata.MD) error {
+	r.record(md)
+	if r.stream == nil {
+		return nil
+	}
+	return r.stream.SendHeader(md)
+}
+
+func (r *headerRecorder) SetTrailer(md metadata.MD) error {
+	if r.stream == nil {
+		return nil
+	}
+	return r.stream.SetTrailer(md)
+}
+
+// cacheKey builds the key from the method, the serialized request and the selected request headers
+func cacheKey(ctx context.Context, method string, req interface{}, hdrs []string) (string, bool) {
+	protoMsg, ok := req.(proto.Message)
+	if !ok {
+		return "", false
+	}
+	buf := proto.NewBuffer(nil)
+	buf.SetDeterministic(true)
+	if err := buf.Marshal(protoMsg); err != nil {
+		return "", false
+	}
+	h := sha256.New()
+	h.Write(buf.Bytes())
+	reqHeaders := headers.RequestHeadersFromContext(ctx)
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, hdr := range hdrs {
+		values := reqHeaders[hdr]
+		if len(values) == 0 {
+			values = md.Get(hdr)
+		}
+		h.Write([]byte("\x00" + hdr + "\x00" + strings.Join(values, ",")))
+	}
+	return "orion:cache:" + method + ":" + hex.EncodeToString(h.Sum(nil)), true
+}
+
+// newResponse decodes a cached response into a new instance of the method's response type
+func newResponse(info *grpc.UnaryServerInfo, data []byte) (interface{}, error) {
+	name := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]
+	method := reflect.ValueOf(info.Server).MethodByName(name)
+	if !method.IsValid() || method.Type().NumOut() == 0 || method.Type().Out(0).Kind() != reflect.Ptr {
+		return nil, errInvalidResponseType
+	}
+	protoMsg, ok := reflect.New(method.Type().Out(0).Elem()).Interface().(proto.Message)
+	if !ok {
+		return nil, errInvalidResponseType
+	}
+	if err := proto.Unmarshal(data, protoMsg); err != nil {
+		return nil, err
+	}
+	return protoMsg, nil
+}
+
+// invalidate deletes the cached responses requested through modifiers.InvalidateCache
+func invalidate(ctx context.Context, store cache.Store, configs *CacheConfigs) {
+	for _, inv := range modifiers.GetCacheInvalidations(ctx) {
+		var hdrs []string
+		if config, ok := configs.get(inv.Method); ok {
+			hdrs = config.Headers
+		}
+		if key, ok := cacheKey(ctx, inv.Method, inv.Request, hdrs); ok {
+			if err := store.Delete(ctx, key); err != nil {
+				log.Warn(ctx, "cache", "could not invalidate response", "method", inv.Method, "error", err)
+			}
+		}
+	}
+}
+
+// callGroup runs a function once for concurrent calls with the same key
+type callGroup struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// do runs fn for the first caller of key, other callers wait for it and receive its result, leader is true for the caller that ran fn.
+// Waiters stop waiting when their context is done
+func (g *callGroup) do(ctx context.Context, key string, fn func() ([]byte, error)) ([]byte, bool, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.data, false, c.err
+		case <-ctx.Done():
+			return nil, false, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.data, c.err = fn()
+	return c.data, true, c.err
+}
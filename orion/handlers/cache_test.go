@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-orion/Orion/orion/modifiers"
+	"github.com/go-orion/Orion/utils/cache"
+	"github.com/go-orion/Orion/utils/headers"
+	"github.com/go-orion/Orion/utils/options"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type cacheService struct{}
+
+func (cacheService) Get(ctx context.Context, req *wrappers.StringValue) (*wrappers.StringValue, error) {
+	return nil, nil
+}
+
+func (cacheService) GetRequestHeaders() []string {
+	return []string{"x-user-id"}
+}
+
+func (cacheService) GetResponseHeaders() []string {
+	// cookies are never cached, even when whitelisted
+	return []string{"x-served-by", "set-cookie"}
+}
+
+func TestParseCacheOption(t *testing.T) {
+	tests := []struct {
+		option string
+		ok     bool
+		config CacheConfig
+	}{
+		{"CACHE", true, CacheConfig{TTL: DefaultCacheTTL}},
+		{"CACHE TTL=30S HEADERS=X-USER-ID,accept-language", true, CacheConfig{TTL: 30 * time.Second, Headers: []string{"X-User-Id", "Accept-Language"}}},
+		{"CACHE TTL=BAD", true, CacheConfig{TTL: DefaultCacheTTL}},
+		{"CACHED", false, CacheConfig{}},
+		{"ETAG", false, CacheConfig{}},
+	}
+	for _, test := range tests {
+		config, ok := ParseCacheOption(test.option)
+		assert.Equal(t, test.ok, ok, test.option)
+		assert.Equal(t, test.config, config, test.option)
+	}
+}
+
+func TestCacheConfigs(t *testing.T) {
+	configs := new(CacheConfigs)
+	assert.True(t, configs.empty())
+	assert.NoError(t, configs.Register(cacheService{}, "test.CacheService", "Registered", "CACHE HEADERS=X-USER-ID"))
+	config, ok := configs.get("/test.CacheService/Registered")
+	assert.True(t, ok, "config should be registered before the method is called")
+	assert.Equal(t, []string{"X-User-Id"}, config.Headers)
+	assert.False(t, configs.empty())
+
+	assert.Error(t, configs.Register(cacheService{}, "test.CacheService", "Rejected", "CACHE HEADERS=AUTHORIZATION"))
+	_, ok = configs.get("/test.CacheService/Rejected")
+	assert.False(t, ok, "config with headers that are not whitelisted should NOT be registered")
+
+	assert.NoError(t, configs.Register(cacheService{}, "test.CacheService", "Other", "ETAG"))
+	_, ok = configs.get("/test.CacheService/Other")
+	assert.False(t, ok, "other options should NOT be registered")
+
+	configs.Reset()
+	_, ok = configs.get("/test.CacheService/Registered")
+	assert.False(t, ok, "configs should be removed on reset")
+	assert.True(t, configs.empty())
+	assert.True(t, new(CacheConfigs).empty(), "configs of other servers should NOT be shared")
+
+	var none *CacheConfigs
+	assert.True(t, none.empty(), "handlers without configs have no cached methods")
+}
+
+func TestCacheKey(t *testing.T) {
+	req := &wrappers.StringValue{Value: "hello"}
+	withHeader := func(value string) context.Context {
+		return headers.AddToRequestHeaders(context.Background(), "X-User-Id", value)
+	}
+	key := func(ctx context.Context, method string, req interface{}, hdrs []string) string {
+		k, ok := cacheKey(ctx, method, req, hdrs)
+		assert.True(t, ok)
+		return k
+	}
+
+	base := key(withHeader("1"), "/svc/Get", req, []string{"X-User-Id"})
+	assert.Equal(t, base, key(withHeader("1"), "/svc/Get", &wrappers.StringValue{Value: "hello"}, []string{"X-User-Id"}), "equal requests should have the same key")
+	assert.NotEqual(t, base, key(withHeader("2"), "/svc/Get", req, []string{"X-User-Id"}), "key headers should be part of the key")
+	assert.NotEqual(t, base, key(withHeader("1"), "/svc/List", req, []string{"X-User-Id"}), "method should be part of the key")
+	assert.NotEqual(t, base, key(withHeader("1"), "/svc/Get", &wrappers.StringValue{Value: "world"}, []string{"X-User-Id"}), "request should be part of the key")
+	assert.Equal(t, key(withHeader("1"), "/svc/Get", req, nil), key(withHeader("2"), "/svc/Get", req, nil), "headers that are not key headers should be ignored")
+
+	// gRPC requests carry the headers as metadata
+	md := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user-id", "1"))
+	assert.Equal(t, base, key(md, "/svc/Get", req, []string{"X-User-Id"}))
+
+	_, ok := cacheKey(context.Background(), "/svc/Get", "not a proto", nil)
+	assert.False(t, ok, "requests that are not proto messages can not be cached")
+}
+
+func TestCallGroup(t *testing.T) {
+	g := &callGroup{calls: make(map[string]*call)}
+	release := make(chan struct{})
+	var calls int32
+	var leaders int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, leader, err := g.do(context.Background(), "key", func() ([]byte, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return []byte("data"), nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("data"), data)
+			if leader {
+				atomic.AddInt32(&leaders, 1)
+			}
+		}()
+	}
+	// wait for the callers to queue up behind the leader
+	for {
+		g.mu.Lock()
+		c, ok := g.calls["key"]
+		g.mu.Unlock()
+		if ok && c != nil && atomic.LoadInt32(&calls) == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "concurrent calls should be coalesced")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&leaders))
+
+	_, leader, _ := g.do(context.Background(), "key", func() ([]byte, error) { return nil, nil })
+	assert.True(t, leader, "finished calls should NOT be shared")
+}
+
+func TestCallGroupCanceledWaiter(t *testing.T) {
+	g := &callGroup{calls: make(map[string]*call)}
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := g.do(context.Background(), "key", func() ([]byte, error) {
+			close(started)
+			<-release
+			return []byte("data"), nil
+		})
+		done <- err
+	}()
+	<-started
+
+	// waiters give up when their context is done, the leader keeps running
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	data, leader, err := g.do(ctx, "key", func() ([]byte, error) {
+		t.Error("waiters should NOT run the function")
+		return nil, nil
+	})
+	assert.Nil(t, data)
+	assert.False(t, leader)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+
+	close(release)
+	assert.NoError(t, <-done)
+}
+
+// cacheCall runs a call through the options interceptor and the cache interceptor
+func cacheCall(interceptor grpc.UnaryServerInterceptor, ctx context.Context, method string, req interface{}, handler grpc.UnaryHandler) (interface{}, error) {
+	info := &grpc.UnaryServerInfo{Server: cacheService{}, FullMethod: method}
+	ctx = headers.AddToResponseHeaders(options.AddToOptions(ctx, "", ""), "", "")
+	return interceptor(ctx, req, info, handler)
+}
+
+func TestCacheInterceptor(t *testing.T) {
+	store := cache.NewLRUStore(10)
+	configs := new(CacheConfigs)
+	assert.NoError(t, configs.Register(cacheService{}, "test.CacheService", "Get", "CACHE TTL=1M"))
+	config, _ := getCacheConfig([]string{"CACHE TTL=1M"})
+	interceptor := cacheInterceptor(store, configs, config)
+
+	var calls int32
+	get := func(ctx context.Context, req interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		hdrs := headers.ResponseHeadersFromContext(ctx)
+		hdrs.Set("X-Served-By", "service")
+		hdrs.Set("Cache-Control", "max-age=60")
+		hdrs.Set("X-Request-Id", "1")
+		hdrs.Set("Set-Cookie", "session=secret")
+		grpc.SetHeader(ctx, metadata.Pairs("x-version", "1", "set-cookie", "session=secret"))
+		return &wrappers.StringValue{Value: req.(*wrappers.StringValue).Value + "!"}, nil
+	}
+
+	req := &wrappers.StringValue{Value: "hello"}
+	resp, err := cacheCall(interceptor, context.Background(), "/test.CacheService/Get", req, get)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello!", resp.(*wrappers.StringValue).Value)
+
+	// the cached response replays the headers and metadata set by the service
+	stream := &headerRecorder{}
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+	ctx = headers.AddToResponseHeaders(ctx, "", "")
+	info := &grpc.UnaryServerInfo{Server: cacheService{}, FullMethod: "/test.CacheService/Get"}
+	resp, err = interceptor(options.AddToOptions(ctx, "", ""), req, info, get)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello!", resp.(*wrappers.StringValue).Value)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "second call should be served from the cache")
+	replayed := headers.ResponseHeadersFromContext(ctx)
+	assert.Equal(t, "service", replayed.Get("X-Served-By"))
+	assert.Equal(t, "max-age=60", replayed.Get("Cache-Control"))
+	assert.Empty(t, replayed.Get("X-Request-Id"), "headers that are not whitelisted should NOT be replayed")
+	assert.Empty(t, replayed.Get("Set-Cookie"), "cookies should NOT be replayed")
+	assert.Equal(t, []string{"1"}, stream.md.Get("x-version"))
+	assert.Empty(t, stream.md.Get("set-cookie"), "cookies should NOT be replayed as metadata")
+
+	// a method without the CACHE option invalidates the cached response
+	update := func(ctx context.Context, req interface{}) (interface{}, error) {
+		modifiers.InvalidateCache(ctx, "/test.CacheService/Get", &wrappers.StringValue{Value: "hello"})
+		return &wrappers.StringValue{}, nil
+	}
+	_, err = cacheCall(invalidationInterceptor(store, configs), context.Background(), "/test.CacheService/Update", req, update)
+	assert.NoError(t, err)
+	_, err = cacheCall(interceptor, context.Background(), "/test.CacheService/Get", req, get)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "invalidated response should NOT be served from the cache")
+
+	// bypassed requests are not served from the cache
+	ctx = options.AddToOptions(context.Background(), "", "")
+	modifiers.BypassCache(ctx)
+	_, err = interceptor(headers.AddToResponseHeaders(ctx, "", ""), req, info, get)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls), "bypassed request should NOT be served from the cache")
+}
+
+func TestCacheInterceptorInstalled(t *testing.T) {
+	config := CommonConfig{NoDefaultInterceptors: true, CacheStore: cache.NewLRUStore(10), CacheConfigs: new(CacheConfigs)}
+	uncached, err := getInterceptors(cacheService{}, config, nil, []string{"ETAG"})
+	assert.NoError(t, err)
+	assert.NoError(t, config.CacheConfigs.Register(cacheService{}, "test.CacheService", "Installed", "CACHE"))
+
+	cached, err := getInterceptors(cacheService{}, config, nil, []string{"CACHE"})
+	assert.NoError(t, err)
+	rejected, err := getInterceptors(cacheService{}, config, nil, []string{"CACHE HEADERS=AUTHORIZATION"})
+	assert.NoError(t, err)
+	none, err := getInterceptors(cacheService{}, CommonConfig{NoDefaultInterceptors: true}, nil, []string{"CACHE"})
+	assert.NoError(t, err)
+	// options, method options and cache or invalidation interceptors
+	assert.Len(t, uncached, 2, "methods are not invalidated when no method of the server is cached")
+	assert.Len(t, cached, 3)
+	assert.Len(t, rejected, 3)
+	assert.Len(t, none, 2, "caching is disabled without a store")
+}
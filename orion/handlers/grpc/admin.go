@@ -0,0 +1,117 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/go-orion/Orion/utils/log"
+	"google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// adminServices are the prefixes of the full method names of admin services
+var adminServices = []string{
+	"/grpc.reflection.",
+	"/grpc.channelz.",
+}
+
+func isAdminMethod(fullMethod string) bool {
+	for _, prefix := range adminServices {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// registerAdminServices registers the enabled admin services, it is called every time the server is created
+// so that they are available after a hot reload
+func (g *grpcHandler) registerAdminServices() {
+	if g.config.EnableReflection {
+		reflection.Register(g.grpcServer)
+	}
+	if g.config.EnableChannelz {
+		service.RegisterChannelzServiceToServer(g.grpcServer)
+	}
+}
+
+// checkAdmin allows admin services only on admin connections, when AdminOnly is set, and only admin services on
+// admin connections, unknown services are reported for calls that are not allowed so they cannot be discovered
+func (g *grpcHandler) checkAdmin(ctx context.Context, fullMethod string) error {
+	admin := isAdminMethod(fullMethod)
+	if g.config.AdminOnly && admin != isAdminConn(ctx) {
+		return status.Errorf(codes.Unimplemented, "unknown service %s", strings.SplitN(strings.TrimPrefix(fullMethod, "/"), "/", 2)[0])
+	}
+	if admin && g.config.AdminAuth != nil {
+		if err := g.config.AdminAuth(ctx, fullMethod); err != nil {
+			log.Warn(ctx, "method", fullMethod, "error", err, "msg", "admin call rejected")
+			if _, ok := status.FromError(err); ok {
+				return err
+			}
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+	return nil
+}
+
+//RunAdmin is the implementation of handlers.AdminServable
+func (g *grpcHandler) RunAdmin(adminListener net.Listener) error {
+	log.Info(context.Background(), "GRPC", "admin server starting", "address", adminListener.Addr().String())
+	return g.grpcServer.Serve(&adminListenerWrapper{Listener: adminListener})
+}
+
+type adminConnKey struct{}
+
+func isAdminConn(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminConnKey{}).(bool)
+	return admin
+}
+
+// adminListenerWrapper marks accepted connections so that they can be recognised by adminTagger
+type adminListenerWrapper struct {
+	net.Listener
+}
+
+func (l *adminListenerWrapper) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return conn, err
+	}
+	return &adminConn{Conn: conn}, nil
+}
+
+type adminConn struct {
+	net.Conn
+}
+
+func (c *adminConn) LocalAddr() net.Addr {
+	return adminAddr{Addr: c.Conn.LocalAddr()}
+}
+
+type adminAddr struct {
+	net.Addr
+}
+
+// adminTagger tags the context of connections accepted on the admin listener
+type adminTagger struct{}
+
+func (adminTagger) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (adminTagger) HandleRPC(context.Context, stats.RPCStats) {}
+
+func (adminTagger) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	if _, ok := info.LocalAddr.(adminAddr); ok {
+		return context.WithValue(ctx, adminConnKey{}, true)
+	}
+	return ctx
+}
+
+func (adminTagger) HandleConn(context.Context, stats.ConnStats) {}
+
+var _ stats.Handler = adminTagger{}
@@ -0,0 +1,120 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsAdminMethod(t *testing.T) {
+	tests := []struct {
+		method string
+		admin  bool
+	}{
+		{"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo", true},
+		{"/grpc.channelz.v1.Channelz/GetTopChannels", true},
+		{"/test.TestService/Upper", false},
+		{"/grpc.health.v1.Health/Check", false},
+		{"/grpc.reflectionservice/Info", false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.admin, isAdminMethod(test.method), test.method)
+	}
+}
+
+func TestCheckAdmin(t *testing.T) {
+	const reflectionMethod = "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"
+	deny := func(context.Context, string) error { return errors.New("no token") }
+	denyStatus := func(context.Context, string) error { return status.Error(codes.Unauthenticated, "login") }
+	adminCtx := context.WithValue(context.Background(), adminConnKey{}, true)
+	tests := []struct {
+		name   string
+		config Config
+		ctx    context.Context
+		method string
+		code   codes.Code
+	}{
+		{"service", Config{}, context.Background(), "/test.TestService/Upper", codes.OK},
+		{"admin service on any listener", Config{}, context.Background(), reflectionMethod, codes.OK},
+		{"admin only service", Config{AdminOnly: true}, context.Background(), "/test.TestService/Upper", codes.OK},
+		{"admin only admin service", Config{AdminOnly: true}, context.Background(), reflectionMethod, codes.Unimplemented},
+		{"admin only admin listener", Config{AdminOnly: true}, adminCtx, reflectionMethod, codes.OK},
+		{"admin only service on admin listener", Config{AdminOnly: true}, adminCtx, "/test.TestService/Upper", codes.Unimplemented},
+		{"auth", Config{AdminAuth: deny}, context.Background(), reflectionMethod, codes.PermissionDenied},
+		{"auth status", Config{AdminAuth: denyStatus}, context.Background(), reflectionMethod, codes.Unauthenticated},
+		{"auth skips services", Config{AdminAuth: deny}, context.Background(), "/test.TestService/Upper", codes.OK},
+	}
+	for _, test := range tests {
+		h := &grpcHandler{config: test.config}
+		assert.Equal(t, test.code, status.Code(h.checkAdmin(test.ctx, test.method)), test.name)
+	}
+	// unknown services are reported so that admin services cannot be discovered
+	h := &grpcHandler{config: Config{AdminOnly: true}}
+	assert.Equal(t, "unknown service grpc.reflection.v1alpha.ServerReflection", status.Convert(h.checkAdmin(context.Background(), reflectionMethod)).Message())
+}
+
+// listServices lists the services of the server using reflection
+func listServices(ctx context.Context, conn *grpc.ClientConn) ([]string, error) {
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+	if err := stream.Send(&rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_ListServices{}}); err != nil {
+		return nil, err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	services := make([]string, 0)
+	for _, svc := range resp.GetListServicesResponse().GetService() {
+		services = append(services, svc.GetName())
+	}
+	return services, nil
+}
+
+func TestAdminListener(t *testing.T) {
+	h := NewGRPCHandler(Config{EnableReflection: true, AdminOnly: true}).(*grpcHandler)
+	if err := h.Add(&testServiceDesc, testService{}); err != nil {
+		t.Fatal(err)
+	}
+	dial := func(serve func(net.Listener) error) *grpc.ClientConn {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go serve(lis)
+		conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	conn := dial(h.Run)
+	admin := dial(h.RunAdmin)
+	t.Cleanup(func() { h.Stop(0) })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := listServices(ctx, conn)
+	assert.Equal(t, codes.Unimplemented, status.Code(err), "reflection should only be served on the admin listener")
+	services, err := listServices(ctx, admin)
+	assert.NoError(t, err)
+	assert.Contains(t, services, "test.TestService")
+
+	value, err := upper(ctx, conn, "hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO", value)
+	_, err = upper(ctx, admin, "hello")
+	assert.Equal(t, codes.Unimplemented, status.Code(err), "services should not be served on the admin listener")
+}
@@ -0,0 +1,99 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-orion/Orion/orion/handlers"
+	"github.com/go-orion/Orion/utils/log"
+	"google.golang.org/grpc"
+)
+
+// methodChain is the composed interceptor chain of a method, it is built once and reused for all calls
+type methodChain struct {
+	unary  grpc.UnaryServerInterceptor
+	stream grpc.StreamServerInterceptor
+	err    error
+}
+
+// chain returns the cached interceptor chain of fullMethod, building it on first use
+func (g *grpcHandler) chain(fullMethod string, svc interface{}, stream bool) *methodChain {
+	g.chainMu.RLock()
+	c, ok := g.chains[fullMethod]
+	g.chainMu.RUnlock()
+	if ok {
+		return c
+	}
+	c = g.buildChain(fullMethod, svc, stream)
+	if c.err != nil {
+		// methods registered after Validate are only reported here, once
+		log.Error(context.Background(), "method", fullMethod, "error", c.err, "middleware", "could not fetch middleware")
+	}
+	return g.storeChain(fullMethod, c)
+}
+
+// storeChain caches c unless a chain was stored concurrently, the cached chain is returned
+func (g *grpcHandler) storeChain(fullMethod string, c *methodChain) *methodChain {
+	g.chainMu.Lock()
+	defer g.chainMu.Unlock()
+	if g.chains == nil {
+		g.chains = make(map[string]*methodChain)
+	}
+	if cached, ok := g.chains[fullMethod]; ok {
+		return cached
+	}
+	g.chains[fullMethod] = c
+	return c
+}
+
+func (g *grpcHandler) resetChains() {
+	g.chainMu.Lock()
+	defer g.chainMu.Unlock()
+	g.chains = nil
+}
+
+func (g *grpcHandler) buildChain(fullMethod string, svc interface{}, stream bool) *methodChain {
+	// fetch method middlewares and options
+	middlewares := make([]string, 0)
+	if g.middlewares != nil {
+		middlewares = append(middlewares, g.middlewares.GetMiddlewaresFromURL(fullMethod)...)
+	}
+	options := make([]string, 0)
+	if g.options != nil {
+		options = append(options, g.options.GetMiddlewaresFromURL(fullMethod)...)
+	}
+	c := &methodChain{}
+	if stream {
+		c.stream, c.err = handlers.BuildStreamInterceptors(svc, g.config.CommonConfig, middlewares, options)
+	} else {
+		c.unary, c.err = handlers.BuildInterceptors(svc, g.config.CommonConfig, middlewares, options)
+	}
+	return c
+}
+
+//Validate is the implementation of handlers.Validatable, it builds the interceptor chains of all registered methods
+//so that middlewares that cannot be resolved are reported before serving
+func (g *grpcHandler) Validate() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	problems := make([]string, 0)
+	for _, s := range g.services {
+		for _, m := range s.sd.Methods {
+			fullMethod := "/" + s.sd.ServiceName + "/" + m.MethodName
+			if c := g.storeChain(fullMethod, g.buildChain(fullMethod, s.ss, false)); c.err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s", fullMethod, c.err))
+			}
+		}
+		for _, st := range s.sd.Streams {
+			fullMethod := "/" + s.sd.ServiceName + "/" + st.StreamName
+			if c := g.storeChain(fullMethod, g.buildChain(fullMethod, s.ss, true)); c.err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s", fullMethod, c.err))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid middlewares: %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package grpc
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-orion/Orion/orion/handlers"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// countingService counts how often its interceptors are fetched and called
+type countingService struct {
+	testService
+	fetched *int32
+	called  *int32
+}
+
+func (s countingService) GetInterceptors() []grpc.UnaryServerInterceptor {
+	atomic.AddInt32(s.fetched, 1)
+	return []grpc.UnaryServerInterceptor{
+		func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			atomic.AddInt32(s.called, 1)
+			return handler(ctx, req)
+		},
+	}
+}
+
+func TestChainCache(t *testing.T) {
+	svc := countingService{fetched: new(int32), called: new(int32)}
+	conn := startTestHandler(t, Config{CommonConfig: handlers.CommonConfig{NoDefaultInterceptors: true}}, svc, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		_, err := upper(ctx, conn, "hello")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(svc.fetched), "the chain should be built once per method")
+	assert.Equal(t, int32(3), atomic.LoadInt32(svc.called), "the cached chain should run on every call")
+}
+
+func TestStoreChain(t *testing.T) {
+	h := &grpcHandler{}
+	first, second := &methodChain{}, &methodChain{}
+	assert.True(t, first == h.storeChain("/test.TestService/Upper", first))
+	assert.True(t, first == h.storeChain("/test.TestService/Upper", second), "concurrently built chains should not replace the cached one")
+	assert.True(t, second == h.storeChain("/test.TestService/Split", second))
+
+	h.resetChains()
+	assert.True(t, second == h.storeChain("/test.TestService/Upper", second), "chains should be built again after a reset")
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		middlewares map[string]string
+		err         string
+	}{
+		{"valid", map[string]string{"Upper": "Audit", "Split": "AuditStream"}, ""},
+		{"missing unary middleware", map[string]string{"Upper": "Missing"}, "invalid middlewares: /test.TestService/Upper: could not find middleware Missing"},
+		{"stream middleware for unary method", map[string]string{"Upper": "AuditStream"}, "/test.TestService/Upper: middleware should be defined as"},
+		{"unary middleware for stream", map[string]string{"Split": "Audit"}, "/test.TestService/Split: stream middleware should be defined as"},
+	}
+	for _, test := range tests {
+		svc := middlewareService{seen: make(chan []string, 1)}
+		h := NewGRPCHandler(Config{CommonConfig: handlers.CommonConfig{NoDefaultInterceptors: true}}).(*grpcHandler)
+		assert.NoError(t, h.Add(&testServiceDesc, svc))
+		for method, middleware := range test.middlewares {
+			h.AddMiddleware("test.TestService", method, middleware)
+		}
+		err := h.Validate()
+		if test.err == "" {
+			assert.NoError(t, err, test.name)
+		} else if assert.Error(t, err, test.name) {
+			assert.Contains(t, err.Error(), test.err, test.name)
+		}
+		// validated chains are cached for both kinds of methods
+		assert.Len(t, h.chains, 2, test.name)
+	}
+}
@@ -3,7 +3,6 @@ package grpc
 import (
 	"context"
 	"net"
-	"reflect"
 	"sync"
 	"time"
 
@@ -11,11 +10,40 @@ import (
 	"github.com/go-orion/Orion/utils/log"
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"google.golang.org/grpc"
+	// registers the gzip compressor, so that compressed requests are accepted and answered compressed
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
 )
 
 // Config is the configuration for GRPC Handler
 type Config struct {
 	handlers.CommonConfig
+	// EnableReflection registers the gRPC server reflection service, used by tools like grpcurl to discover services
+	EnableReflection bool
+	// EnableChannelz registers the channelz service
+	EnableChannelz bool
+	// AdminOnly serves reflection and channelz only on the admin listener
+	AdminOnly bool
+	// AdminAuth authorizes calls to reflection and channelz
+	AdminAuth handlers.AdminAuthFunc
+	// Server returns the settings of the gRPC server, it is called every time the server is created
+	// so that settings changed on reload are applied
+	Server func() ServerConfig
+}
+
+// ServerConfig are the settings of the gRPC server, zero values use the grpc defaults
+type ServerConfig struct {
+	// MaxRecvMsgSize and MaxSendMsgSize are the maximum message sizes in bytes
+	MaxRecvMsgSize int
+	MaxSendMsgSize int
+	// MaxConcurrentStreams is the maximum number of concurrent streams on each connection
+	MaxConcurrentStreams uint32
+	// Keepalive sets the keepalive pings and connection age limits of the server
+	Keepalive keepalive.ServerParameters
+	// KeepaliveEnforcement is the keepalive policy enforced on clients
+	KeepaliveEnforcement keepalive.EnforcementPolicy
+	// ServerOptions are passed as is to grpc.NewServer after the options built from the settings above
+	ServerOptions []grpc.ServerOption
 }
 
 //NewGRPCHandler creates a new GRPC handler
@@ -28,18 +56,59 @@ type grpcHandler struct {
 	mu          sync.Mutex
 	config      Config
 	middlewares *handlers.MiddlewareMapping
+	options     *handlers.MiddlewareMapping
+	services    []registeredService
+	chainMu     sync.RWMutex
+	chains      map[string]*methodChain
+}
+
+type registeredService struct {
+	sd *grpc.ServiceDesc
+	ss interface{}
 }
 
 func (g *grpcHandler) init() {
 	if g.grpcServer == nil {
-		g.grpcServer = grpc.NewServer(
-			grpc.UnaryInterceptor(g.grpcInterceptor()),
-			grpc.StreamInterceptor(g.grpcStreamInterceptor()),
-		)
+		g.grpcServer = grpc.NewServer(g.serverOptions()...)
+		g.registerAdminServices()
 	}
 	if g.middlewares == nil {
 		g.middlewares = handlers.NewMiddlewareMapping()
 	}
+	if g.options == nil {
+		g.options = handlers.NewMiddlewareMapping()
+	}
+}
+
+// serverOptions returns the options used to create the grpc server
+func (g *grpcHandler) serverOptions() []grpc.ServerOption {
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(g.grpcInterceptor()),
+		grpc.StreamInterceptor(g.grpcStreamInterceptor()),
+	}
+	if g.config.AdminOnly {
+		opts = append(opts, grpc.StatsHandler(adminTagger{}))
+	}
+	if g.config.Server == nil {
+		return opts
+	}
+	cfg := g.config.Server()
+	if cfg.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(cfg.MaxRecvMsgSize))
+	}
+	if cfg.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(cfg.MaxSendMsgSize))
+	}
+	if cfg.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(cfg.MaxConcurrentStreams))
+	}
+	if cfg.Keepalive != (keepalive.ServerParameters{}) {
+		opts = append(opts, grpc.KeepaliveParams(cfg.Keepalive))
+	}
+	if cfg.KeepaliveEnforcement != (keepalive.EnforcementPolicy{}) {
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(cfg.KeepaliveEnforcement))
+	}
+	return append(opts, cfg.ServerOptions...)
 }
 
 func (g *grpcHandler) Add(sd *grpc.ServiceDesc, ss interface{}) error {
@@ -47,6 +116,7 @@ func (g *grpcHandler) Add(sd *grpc.ServiceDesc, ss interface{}) error {
 	defer g.mu.Unlock()
 	g.init()
 	g.grpcServer.RegisterService(sd, ss)
+	g.services = append(g.services, registeredService{sd: sd, ss: ss})
 	return nil
 }
 
@@ -57,6 +127,13 @@ func (g *grpcHandler) AddMiddleware(serviceName string, method string, middlewar
 	g.middlewares.AddMiddleware(serviceName, method, middlewares...)
 }
 
+func (g *grpcHandler) AddOption(serviceName, method, option string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.init()
+	g.options.AddMiddleware(serviceName, method, option)
+}
+
 func (g *grpcHandler) Run(grpcListener net.Listener) error {
 	log.Info(context.Background(), "GRPC", "server starting")
 	grpc_prometheus.Register(g.grpcServer)
@@ -72,6 +149,9 @@ func (g *grpcHandler) Stop(timeout time.Duration) error {
 	g.grpcServer.Stop()
 	g.grpcServer = nil
 	g.middlewares = nil
+	g.options = nil
+	g.services = nil
+	g.resetChains()
 	log.Info(context.Background(), "GRPC", "stopped server")
 	return nil
 }
@@ -79,13 +159,11 @@ func (g *grpcHandler) Stop(timeout time.Duration) error {
 // grpcInterceptor acts as default interceptor for gprc and applies service specific interceptors based on implementation
 func (g *grpcHandler) grpcInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		// fetch method middlewares for this call
-		middlewares := make([]string, 0)
-		if g.middlewares != nil {
-			middlewares = append(middlewares, g.middlewares.GetMiddlewaresFromURL(info.FullMethod)...)
+		if err := g.checkAdmin(ctx, info.FullMethod); err != nil {
+			return nil, err
 		}
-		// fetch interceptors from the service implementation and apply
-		interceptor := handlers.GetInterceptorsWithMethodMiddlewares(info.Server, g.config.CommonConfig, middlewares)
+		// fetch the interceptors of this method, they are composed on first use
+		interceptor := g.chain(info.FullMethod, info.Server, false).unary
 		return interceptor(ctx, req, info, handler)
 	}
 }
@@ -93,8 +171,10 @@ func (g *grpcHandler) grpcInterceptor() grpc.UnaryServerInterceptor {
 // grpcStreamInterceptor acts as default interceptor for gprc streams and applies service specific interceptors based on implementation
 func (g *grpcHandler) grpcStreamInterceptor() grpc.StreamServerInterceptor {
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		interceptor := handlers.GetStreamInterceptors(srv, g.config.CommonConfig)
-		log.Info(context.Background(), "svr", srv, "type", reflect.TypeOf(srv))
+		if err := g.checkAdmin(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		interceptor := g.chain(info.FullMethod, srv, true).stream
 		return interceptor(srv, ss, info, handler)
 	}
 }
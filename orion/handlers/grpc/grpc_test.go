@@ -0,0 +1,225 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-orion/Orion/orion/handlers"
+	"github.com/go-orion/Orion/orion/modifiers"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+)
+
+// testService is the service served by the handlers under test
+type testService struct{}
+
+func (testService) Upper(ctx context.Context, req *wrappers.StringValue) (*wrappers.StringValue, error) {
+	if req.GetValue() == "fail" {
+		return nil, status.Error(codes.InvalidArgument, "cannot upper fail")
+	}
+	return &wrappers.StringValue{Value: strings.ToUpper(req.GetValue())}, nil
+}
+
+func (testService) Split(req *wrappers.StringValue, stream grpc.ServerStream) error {
+	for _, word := range strings.Fields(req.GetValue()) {
+		if err := stream.SendMsg(&wrappers.StringValue{Value: word}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var testServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.TestService",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Upper",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(wrappers.StringValue)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(upperService).Upper(ctx, req.(*wrappers.StringValue))
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/test.TestService/Upper"}, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Split",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				in := new(wrappers.StringValue)
+				if err := stream.RecvMsg(in); err != nil {
+					return err
+				}
+				return srv.(splitService).Split(in, stream)
+			},
+			ServerStreams: true,
+		},
+	},
+}
+
+// upperService and splitService allow tests to serve testService embedded in services with middlewares
+type upperService interface {
+	Upper(ctx context.Context, req *wrappers.StringValue) (*wrappers.StringValue, error)
+}
+
+type splitService interface {
+	Split(req *wrappers.StringValue, stream grpc.ServerStream) error
+}
+
+// startTestHandler serves svc with a gRPC handler, setup can register middlewares and options before serving,
+// it returns a connection to the server
+func startTestHandler(t *testing.T, config Config, svc interface{}, setup func(h *grpcHandler)) *grpc.ClientConn {
+	h := NewGRPCHandler(config).(*grpcHandler)
+	if err := h.Add(&testServiceDesc, svc); err != nil {
+		t.Fatal(err)
+	}
+	if setup != nil {
+		setup(h)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Run(lis)
+	}()
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		h.Stop(0)
+		<-done
+	})
+	return conn
+}
+
+func upper(ctx context.Context, conn *grpc.ClientConn, value string, opts ...grpc.CallOption) (string, error) {
+	resp := new(wrappers.StringValue)
+	err := conn.Invoke(ctx, "/test.TestService/Upper", &wrappers.StringValue{Value: value}, resp, opts...)
+	return resp.GetValue(), err
+}
+
+func TestServerOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		count  int
+	}{
+		{"interceptors", Config{}, 2},
+		{"admin only", Config{AdminOnly: true}, 3},
+		{"empty server config", Config{Server: func() ServerConfig { return ServerConfig{} }}, 2},
+		{"server config", Config{Server: func() ServerConfig {
+			return ServerConfig{
+				MaxRecvMsgSize:       1 << 20,
+				MaxSendMsgSize:       1 << 20,
+				MaxConcurrentStreams: 10,
+				Keepalive:            keepalive.ServerParameters{Time: time.Minute},
+				KeepaliveEnforcement: keepalive.EnforcementPolicy{MinTime: time.Second},
+				ServerOptions:        []grpc.ServerOption{grpc.ConnectionTimeout(time.Second)},
+			}
+		}}, 8},
+	}
+	for _, test := range tests {
+		h := &grpcHandler{config: test.config}
+		assert.Len(t, h.serverOptions(), test.count, test.name)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	// compressors are negotiated with the client, registered compressors are accepted without any configuration
+	conn := startTestHandler(t, Config{}, testService{}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	value, err := upper(ctx, conn, "hello", grpc.UseCompressor("gzip"))
+	assert.NoError(t, err, "gzip compressed requests should be accepted")
+	assert.Equal(t, "HELLO", value)
+
+	value, err = upper(ctx, conn, "hello")
+	assert.NoError(t, err, "uncompressed requests should be accepted")
+	assert.Equal(t, "HELLO", value)
+}
+
+// middlewareService serves testService with method middlewares, seen receives the options of each call
+type middlewareService struct {
+	testService
+	seen chan []string
+}
+
+func (s middlewareService) Audit() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		s.seen <- append([]string{"unary " + info.FullMethod}, modifiers.GetMethodOptions(ctx)...)
+		return handler(ctx, req)
+	}
+}
+
+func (s middlewareService) AuditStream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		s.seen <- append([]string{"stream " + info.FullMethod}, modifiers.GetMethodOptions(ss.Context())...)
+		return handler(srv, ss)
+	}
+}
+
+func split(ctx context.Context, conn *grpc.ClientConn, value string) ([]string, error) {
+	stream, err := conn.NewStream(ctx, &testServiceDesc.Streams[0], "/test.TestService/Split")
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(&wrappers.StringValue{Value: value}); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	words := make([]string, 0)
+	for {
+		msg := new(wrappers.StringValue)
+		if err := stream.RecvMsg(msg); err == io.EOF {
+			return words, nil
+		} else if err != nil {
+			return words, err
+		}
+		words = append(words, msg.GetValue())
+	}
+}
+
+func TestStreamMiddlewares(t *testing.T) {
+	svc := middlewareService{seen: make(chan []string, 2)}
+	conn := startTestHandler(t, Config{CommonConfig: handlers.CommonConfig{NoDefaultInterceptors: true}}, svc, func(h *grpcHandler) {
+		h.AddMiddleware("test.TestService", "Upper", "Audit")
+		h.AddOption("test.TestService", "Upper", "ETAG")
+		h.AddMiddleware("test.TestService", "Split", "AuditStream")
+		h.AddOption("test.TestService", "Split", "CODEC=PROTO")
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := upper(ctx, conn, "hello")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"unary /test.TestService/Upper", "ETAG"}, <-svc.seen)
+
+	words, err := split(ctx, conn, "a b")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, words)
+	assert.Equal(t, []string{"stream /test.TestService/Split", "CODEC=PROTO"}, <-svc.seen, "stream middlewares should see the method options")
+}
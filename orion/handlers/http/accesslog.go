@@ -0,0 +1,116 @@
+package http
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-orion/Orion/utils/accesslog"
+	"github.com/go-orion/Orion/utils/errors"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// accessLogWriter records the status and size of responses
+type accessLogWriter struct {
+	http.ResponseWriter
+	status   int
+	bytes    int64
+	hijacked bool
+}
+
+func (w *accessLogWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *accessLogWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *accessLogWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *accessLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	w.hijacked = true
+	return hijacker.Hijack()
+}
+
+// Unwrap allows http.ResponseController to reach the underlying response
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// countingBody counts the bytes read from the request body
+type countingBody struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytes += int64(n)
+	return n, err
+}
+
+// accessLogMiddleware writes an access log entry for every routed request when access logging is enabled
+func accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		logger := accesslog.Default()
+		if logger == nil {
+			next.ServeHTTP(resp, req)
+			return
+		}
+		entry := &accesslog.Entry{
+			Time:      time.Now(),
+			Transport: "http",
+			Method:    req.Method,
+			Path:      req.URL.Path,
+			ClientIP:  accesslog.ClientIP(req.Header, req.RemoteAddr, logger.TrustedProxies),
+			UserAgent: req.UserAgent(),
+		}
+		switch {
+		case isGRPCWebRequest(req, nil):
+			entry.Transport = "grpc-web"
+		case websocket.IsWebSocketUpgrade(req):
+			entry.Transport = "ws"
+		}
+		if route := mux.CurrentRoute(req); route != nil {
+			if tpl, err := route.GetPathTemplate(); err == nil {
+				entry.Route = tpl
+			}
+		}
+		w := &accessLogWriter{ResponseWriter: resp}
+		body := &countingBody{ReadCloser: req.Body}
+		req.Body = body
+		defer func() {
+			entry.Duration = time.Since(entry.Time)
+			entry.Status = w.status
+			if w.hijacked {
+				entry.Status = http.StatusSwitchingProtocols
+			}
+			entry.BytesIn, entry.BytesOut = body.bytes, w.bytes
+			if entry.TraceID == "" {
+				entry.TraceID = req.Header.Get("X-B3-TraceId")
+			}
+			logger.Log(entry)
+		}()
+		next.ServeHTTP(w, req.WithContext(accesslog.NewContext(req.Context(), entry)))
+	})
+}
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-orion/Orion/interceptors"
+	"github.com/go-orion/Orion/orion/modifiers"
+	"github.com/go-orion/Orion/utils/accesslog"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// recordingSink sends the entries it writes to the channel
+type recordingSink chan *accesslog.Entry
+
+func (s recordingSink) Write(e *accesslog.Entry) error {
+	s <- e
+	return nil
+}
+
+// next returns the next entry, entries are written after the response is sent
+func (s recordingSink) next(t *testing.T) *accesslog.Entry {
+	select {
+	case e := <-s:
+		return e
+	case <-time.After(time.Second):
+		t.Error("no access log entry was written")
+		return &accesslog.Entry{}
+	}
+}
+
+func principalInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	modifiers.SetPrincipal(ctx, "tester")
+	return handler(ctx, req)
+}
+
+func principalStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	modifiers.SetPrincipal(ss.Context(), "tester")
+	return handler(srv, ss)
+}
+
+func TestAccessLog(t *testing.T) {
+	sink := make(recordingSink, 10)
+	_, proxy, _ := net.ParseCIDR("127.0.0.1/32")
+	accesslog.SetDefault(&accesslog.Logger{Sink: sink, TrustedProxies: []*net.IPNet{proxy}})
+	defer accesslog.SetDefault(nil)
+
+	svc := testService{
+		interceptors:       []grpc.UnaryServerInterceptor{interceptors.AccessLogInterceptor(), principalInterceptor},
+		streamInterceptors: []grpc.StreamServerInterceptor{interceptors.AccessLogStreamInterceptor(), principalStreamInterceptor},
+	}
+	base := startTestServiceHandler(t, Config{}, svc, func(h *httpHandler) {
+		h.AddEncoder("test.TestService", "Upper", []string{http.MethodPost}, "/api/upper/{value}", nil)
+	})
+	tests := []struct {
+		name      string
+		path      string
+		body      string
+		accept    string
+		route     string
+		status    int
+		code      string
+		principal string
+	}{
+		{"route template", "/api/upper/hello", `{"value":"hello"}`, "", "/api/upper/{value}", http.StatusOK, "OK", "tester"},
+		{"failed call", "/api/upper/fail", `{"value":"fail"}`, "", "/api/upper/{value}", http.StatusBadRequest, "InvalidArgument", "tester"},
+		{"stream", "/testservice/split", `{"value":"a b c"}`, ContentTypeNDJSON, "/testservice/split", http.StatusOK, "OK", "tester"},
+		{"failed stream", "/testservice/split", `{"value":"fail"}`, ContentTypeNDJSON, "/testservice/split", http.StatusConflict, "Aborted", "tester"},
+		{"not found", "/api/missing", "", "", "", http.StatusNotFound, "", ""},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodPost, base+test.path, strings.NewReader(test.body))
+		req.Header.Set("Content-Type", ContentTypeJSON)
+		req.Header.Set("X-Forwarded-For", "203.0.113.1")
+		req.Header.Set("User-Agent", "access-log-test")
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err, test.name) {
+			continue
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		entry := sink.next(t)
+		assert.Equal(t, "http", entry.Transport, test.name)
+		assert.Equal(t, http.MethodPost, entry.Method, test.name)
+		assert.Equal(t, test.route, entry.Route, test.name)
+		assert.Equal(t, test.path, entry.Path, test.name)
+		assert.Equal(t, test.status, entry.Status, test.name)
+		assert.Equal(t, test.code, entry.Code, test.name)
+		assert.Equal(t, int64(len(test.body)), entry.BytesIn, test.name)
+		assert.Equal(t, int64(len(body)), entry.BytesOut, test.name)
+		assert.Equal(t, test.principal, entry.Principal, test.name)
+		assert.Equal(t, "203.0.113.1", entry.ClientIP, test.name)
+		assert.Equal(t, "access-log-test", entry.UserAgent, test.name)
+		assert.Equal(t, test.status >= http.StatusBadRequest, entry.IsError(), test.name)
+	}
+}
+
+func TestAccessLogSampler(t *testing.T) {
+	sink := make(recordingSink, 10)
+	accesslog.SetDefault(&accesslog.Logger{Sink: sink, Sampler: accesslog.ErrorsAndRate(0)})
+	defer accesslog.SetDefault(nil)
+
+	svc := testService{interceptors: []grpc.UnaryServerInterceptor{interceptors.AccessLogInterceptor()}}
+	base := startTestServiceHandler(t, Config{}, svc, nil)
+	for _, value := range []string{"hello", "fail"} {
+		resp, err := http.Post(base+"/testservice/upper", ContentTypeJSON, strings.NewReader(`{"value":"`+value+`"}`))
+		if assert.NoError(t, err, value) {
+			resp.Body.Close()
+		}
+	}
+	// entries are written in order, the successful call is not sampled
+	entry := sink.next(t)
+	assert.Equal(t, "InvalidArgument", entry.Code)
+	assert.Equal(t, http.StatusBadRequest, entry.Status)
+	assert.Equal(t, "127.0.0.1", entry.ClientIP, "forwarding headers should be ignored without trusted proxies")
+	assert.Len(t, sink, 0)
+}
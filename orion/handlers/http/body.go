@@ -0,0 +1,228 @@
+package http
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-orion/Orion/orion/handlers"
+	"github.com/mitchellh/mapstructure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fileSinkKey struct{}
+
+// limitedBody fails reads once more than the allowed number of bytes have been read
+type limitedBody struct {
+	reader  io.Reader
+	closers []io.Closer
+	remain  int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remain < 0 {
+		return 0, ErrRequestTooLarge
+	}
+	if int64(len(p)) > l.remain+1 {
+		p = p[:l.remain+1]
+	}
+	n, err := l.reader.Read(p)
+	l.remain -= int64(n)
+	if l.remain < 0 {
+		return n, ErrRequestTooLarge
+	}
+	return n, err
+}
+
+func (l *limitedBody) Close() error {
+	var err error
+	for _, c := range l.closers {
+		if e := c.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// isRequestTooLarge checks if the error, or any error it wraps, is ErrRequestTooLarge
+func isRequestTooLarge(err error) bool {
+	return isCause(err, ErrRequestTooLarge)
+}
+
+// isCause checks if the error, or any error it wraps, is the target error
+func isCause(err, target error) bool {
+	for err != nil {
+		if err == target {
+			return true
+		}
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			return false
+		}
+		err = cause.Cause()
+	}
+	return false
+}
+
+// maxBodySize finds the maximum request body size for a method, a method option overrides the handler config
+func (h *httpHandler) maxBodySize(info *methodInfo) int64 {
+	for _, opt := range info.options {
+		opt = strings.TrimSpace(opt)
+		if strings.HasPrefix(strings.ToUpper(opt), MaxBodySize+"=") {
+			if size, err := parseSize(opt[len(MaxBodySize)+1:]); err == nil {
+				return size
+			}
+		}
+	}
+	return h.config.MaxBodySize
+}
+
+// limitRequest limits the request body to maxSize bytes, a size of zero or less means no limit
+func limitRequest(req *http.Request, maxSize int64) error {
+	if maxSize <= 0 {
+		return nil
+	}
+	if req.ContentLength > maxSize {
+		return ErrRequestTooLarge
+	}
+	req.Body = &limitedBody{
+		reader:  req.Body,
+		closers: []io.Closer{req.Body},
+		remain:  maxSize,
+	}
+	return nil
+}
+
+// prepareBody applies the size limit and decompression to the request body and writes the error response on failure
+func (h *httpHandler) prepareBody(resp http.ResponseWriter, req *http.Request, info *methodInfo) error {
+	if err := limitRequest(req, h.maxBodySize(info)); err != nil {
+		writeResp(resp, http.StatusRequestEntityTooLarge, []byte("Request Entity Too Large!"))
+		return err
+	}
+	if err := decompressRequest(req, h.config.MaxDecompressedSize); err != nil {
+		if err == ErrUnsupportedEncoding {
+			writeResp(resp, http.StatusUnsupportedMediaType, []byte("Unsupported Media Type!"))
+		} else {
+			// the body does not match its content encoding
+			writeResp(resp, http.StatusBadRequest, []byte("Bad Request!"))
+		}
+		return err
+	}
+	return nil
+}
+
+// parseSize parses sizes like '512', '64KB' or '10MB'
+func parseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			multiplier = unit.size
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			break
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return size * multiplier, nil
+}
+
+// isMultipart checks if the request carries a multipart/form-data body
+func isMultipart(req *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// fileSinkFromContext fetches the file sink registered for the method being served
+func fileSinkFromContext(ctx context.Context) handlers.FileSink {
+	if sink, ok := ctx.Value(fileSinkKey{}).(handlers.FileSink); ok {
+		return sink
+	}
+	return nil
+}
+
+// decodeMultipart streams the file parts of a multipart request to the registered file sink
+// and binds the form fields, along with the url params, into the request object
+func decodeMultipart(req *http.Request, params map[string]string, r interface{}) error {
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return err
+	}
+	fields := make(map[string]interface{})
+	for key, value := range params {
+		fields[key] = value
+	}
+	sink := fileSinkFromContext(req.Context())
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if part.FileName() != "" {
+			if sink == nil {
+				return status.Error(codes.InvalidArgument, "file uploads are not supported")
+			}
+			if err := sink(req.Context(), part); err != nil {
+				return err
+			}
+			continue
+		}
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			return err
+		}
+		name := part.FormName()
+		switch value := fields[name].(type) {
+		case nil:
+			fields[name] = string(data)
+		case string:
+			fields[name] = []string{value, string(data)}
+		case []string:
+			fields[name] = append(value, string(data))
+		}
+	}
+	return decodeFields(fields, r)
+}
+
+// decodeQuery binds the query parameters, along with the url params, into the request object, this is used for
+// GET, HEAD and DELETE requests without a body
+func decodeQuery(req *http.Request, params map[string]string, r interface{}) error {
+	fields := make(map[string]interface{})
+	for key, value := range params {
+		fields[key] = value
+	}
+	for key, values := range req.URL.Query() {
+		if len(values) == 1 {
+			fields[key] = values[0]
+		} else {
+			fields[key] = values
+		}
+	}
+	return decodeFields(fields, r)
+}
+
+// decodeFields binds string values to the fields of the request object matching their json name
+func decodeFields(fields map[string]interface{}, r interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		TagName:          "json",
+		Result:           r,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(fields)
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-orion/Orion/orion/handlers"
+	"github.com/go-orion/Orion/utils/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value string
+		size  int64
+		err   bool
+	}{
+		{"512", 512, false},
+		{"512B", 512, false},
+		{"64KB", 64 << 10, false},
+		{"10mb", 10 << 20, false},
+		{" 2 GB ", 2 << 30, false},
+		{"MB", 0, true},
+		{"ten", 0, true},
+		{"1.5MB", 0, true},
+	}
+	for _, test := range tests {
+		size, err := parseSize(test.value)
+		assert.Equal(t, test.err, err != nil, test.value)
+		assert.Equal(t, test.size, size, test.value)
+	}
+}
+
+func TestLimitedBody(t *testing.T) {
+	tests := []struct {
+		body  string
+		limit int64
+		err   bool
+	}{
+		{"hello", 5, false},
+		{"hello", 10, false},
+		{"hello world", 5, true},
+		{"", 0, false},
+	}
+	for _, test := range tests {
+		body := &limitedBody{reader: strings.NewReader(test.body), remain: test.limit}
+		data, err := ioutil.ReadAll(body)
+		if test.err {
+			assert.True(t, isRequestTooLarge(err), test.body)
+			assert.True(t, int64(len(data)) <= test.limit+1, "reads should stop after the limit")
+		} else {
+			assert.NoError(t, err, test.body)
+			assert.Equal(t, test.body, string(data))
+		}
+	}
+	assert.False(t, isRequestTooLarge(errors.New("other")))
+	assert.False(t, isRequestTooLarge(nil))
+}
+
+func TestLimitRequest(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader("hello world"))
+	assert.Equal(t, ErrRequestTooLarge, limitRequest(req, 5), "declared content length should be checked upfront")
+
+	req, _ = http.NewRequest(http.MethodPost, "/", ioutil.NopCloser(strings.NewReader("hello world")))
+	req.ContentLength = -1
+	assert.NoError(t, limitRequest(req, 5))
+	_, err := ioutil.ReadAll(req.Body)
+	assert.True(t, isRequestTooLarge(err), "chunked bodies should be limited while reading")
+
+	req, _ = http.NewRequest(http.MethodPost, "/", strings.NewReader("hello world"))
+	assert.NoError(t, limitRequest(req, 0), "zero means no limit")
+	data, _ := ioutil.ReadAll(req.Body)
+	assert.Equal(t, "hello world", string(data))
+}
+
+type multipartRequest struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+	ID   int      `json:"id"`
+}
+
+func newMultipartRequest(t *testing.T, sink handlers.FileSink, fields [][2]string, file string) *http.Request {
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	for _, field := range fields {
+		assert.NoError(t, w.WriteField(field[0], field[1]))
+	}
+	if file != "" {
+		part, err := w.CreateFormFile("upload", "upload.txt")
+		assert.NoError(t, err)
+		part.Write([]byte(file))
+	}
+	assert.NoError(t, w.Close())
+	req, _ := http.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	if sink != nil {
+		req = req.WithContext(context.WithValue(req.Context(), fileSinkKey{}, sink))
+	}
+	return req
+}
+
+func TestDecodeMultipart(t *testing.T) {
+	uploaded := ""
+	sink := func(ctx context.Context, part *multipart.Part) error {
+		data, err := ioutil.ReadAll(part)
+		uploaded = part.FileName() + ":" + string(data)
+		return err
+	}
+	fields := [][2]string{{"name", "gopher"}, {"tags", "a"}, {"tags", "b"}}
+
+	req := newMultipartRequest(t, sink, fields, "file content")
+	assert.True(t, isMultipart(req))
+	r := new(multipartRequest)
+	assert.NoError(t, decodeMultipart(req, map[string]string{"id": "42"}, r))
+	assert.Equal(t, &multipartRequest{Name: "gopher", Tags: []string{"a", "b"}, ID: 42}, r)
+	assert.Equal(t, "upload.txt:file content", uploaded)
+
+	// files are rejected when the method has no file sink
+	req = newMultipartRequest(t, nil, fields, "file content")
+	err := decodeMultipart(req, nil, new(multipartRequest))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// form fields only do not need a file sink
+	req = newMultipartRequest(t, nil, fields[:1], "")
+	r = new(multipartRequest)
+	assert.NoError(t, decodeMultipart(req, nil, r))
+	assert.Equal(t, "gopher", r.Name)
+
+	req, _ = http.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", ContentTypeJSON)
+	assert.False(t, isMultipart(req))
+	assert.Error(t, decodeMultipart(req, nil, new(multipartRequest)))
+}
@@ -0,0 +1,374 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-orion/Orion/orion/handlers"
+	"github.com/go-orion/Orion/orion/modifiers"
+	"github.com/go-orion/Orion/utils/errors"
+	"github.com/go-orion/Orion/utils/headers"
+	"github.com/go-orion/Orion/utils/options"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	// options set on the request context from method annotations
+	methodCodecOption  = "OrionMethodCodec"
+	codecOptionsOption = "OrionCodecOptions"
+)
+
+var (
+	// ErrUnsupportedMediaType is returned when a request body uses a content type with no registered codec
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	// ErrNotAcceptable is returned when none of the accepted media types have a registered codec
+	ErrNotAcceptable = errors.New("not acceptable")
+
+	codecs = map[string]Codec{
+		modifiers.JSON:     jsonCodec{},
+		modifiers.JSONPB:   jsonpbCodec{},
+		modifiers.ProtoBuf: protoCodec{},
+	}
+	// codecPreference is the order in which serialization types are picked for wildcard accept headers
+	codecPreference = []string{modifiers.JSON, modifiers.ProtoBuf}
+)
+
+// Codec marshals and unmarshals HTTP request and response bodies for a serialization type
+type Codec interface {
+	// Marshal serializes the response message
+	Marshal(msg interface{}) ([]byte, error)
+	// Unmarshal deserializes the request body into the request message
+	Unmarshal(data []byte, msg interface{}) error
+	// ContentType is the content type of responses when the client does not ask for a specific one
+	ContentType() string
+}
+
+// OptionsCodec is implemented by codecs that support handlers.CodecOptions
+type OptionsCodec interface {
+	Codec
+	// WithOptions returns a copy of the codec using the provided options
+	WithOptions(opts handlers.CodecOptions) Codec
+}
+
+// RegisterCodec registers a codec for a serialization type, contentTypes are the media types negotiated for it.
+// Serialization types are case insensitive and can be selected with modifiers.SerializeOut or the CODEC method option.
+// This should be called before the server is started
+func RegisterCodec(serType string, codec Codec, contentTypes ...string) {
+	serType = strings.ToUpper(serType)
+	if _, ok := codecs[serType]; !ok {
+		codecPreference = append(codecPreference, serType)
+	}
+	codecs[serType] = codec
+	for _, contentType := range append(contentTypes, codec.ContentType()) {
+		ContentTypeMap[strings.ToLower(contentType)] = serType
+	}
+}
+
+// getCodec returns the codec for a serialization type, falling back to json
+func getCodec(serType string) Codec {
+	if codec, ok := codecs[strings.ToUpper(serType)]; ok {
+		return codec
+	}
+	return codecs[modifiers.JSON]
+}
+
+// codec returns the codec for a serialization type configured with the handler options and the method options
+func (h *httpHandler) codec(ctx context.Context, serType string) Codec {
+	codec := getCodec(serType)
+	if oc, ok := codec.(OptionsCodec); ok {
+		opts := h.config.Codec
+		if value, found := options.FromContext(ctx).Get(codecOptionsOption); found {
+			if overrides, ok := value.(codecOverrides); ok {
+				opts = overrides.apply(opts)
+			}
+		}
+		return oc.WithOptions(opts)
+	}
+	return codec
+}
+
+// codecOverrides are the codec options set by a method, nil values keep the options of the handler
+type codecOverrides struct {
+	emitDefaults *bool
+	origName     *bool
+	enumsAsInts  *bool
+}
+
+func (o codecOverrides) apply(opts handlers.CodecOptions) handlers.CodecOptions {
+	if o.emitDefaults != nil {
+		opts.EmitDefaults = *o.emitDefaults
+	}
+	if o.origName != nil {
+		opts.OrigName = *o.origName
+	}
+	if o.enumsAsInts != nil {
+		opts.EnumsAsInts = *o.enumsAsInts
+	}
+	return opts
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(msg interface{}) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (jsonCodec) Unmarshal(data []byte, msg interface{}) error {
+	return json.Unmarshal(data, msg)
+}
+
+func (jsonCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+type jsonpbCodec struct {
+	opts handlers.CodecOptions
+}
+
+func (c jsonpbCodec) Marshal(msg interface{}) ([]byte, error) {
+	if protoMsg, ok := msg.(proto.Message); ok {
+		mar := jsonpb.Marshaler{
+			EmitDefaults: c.opts.EmitDefaults,
+			OrigName:     c.opts.OrigName,
+			EnumsAsInts:  c.opts.EnumsAsInts,
+		}
+		data, err := mar.MarshalToString(protoMsg)
+		return []byte(data), err
+	}
+	return json.Marshal(msg)
+}
+
+func (jsonpbCodec) WithOptions(opts handlers.CodecOptions) Codec {
+	return jsonpbCodec{opts: opts}
+}
+
+func (jsonpbCodec) Unmarshal(data []byte, msg interface{}) error {
+	if protoMsg, ok := msg.(proto.Message); ok {
+		return jsonpb.UnmarshalString(string(data), protoMsg)
+	}
+	return json.Unmarshal(data, msg)
+}
+
+func (jsonpbCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+type protoCodec struct{}
+
+func (protoCodec) Marshal(msg interface{}) ([]byte, error) {
+	if protoMsg, ok := msg.(proto.Message); ok {
+		return proto.Marshal(protoMsg)
+	}
+	return nil, errors.New("response is not a proto message")
+}
+
+func (protoCodec) Unmarshal(data []byte, msg interface{}) error {
+	if protoMsg, ok := msg.(proto.Message); ok {
+		return proto.Unmarshal(data, protoMsg)
+	}
+	// not a proto message, try json instead
+	return json.Unmarshal(data, msg)
+}
+
+func (protoCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+// serTypeForMediaType finds the serialization type registered for a media type, ignoring its parameters
+func serTypeForMediaType(value string) (string, string) {
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return "", ""
+	}
+	if t, ok := ContentTypeMap[mediaType]; ok {
+		return t, mediaType
+	}
+	if strings.HasSuffix(mediaType, "+json") {
+		// structured syntax suffix, e.g. application/vnd.api+json
+		return modifiers.JSON, mediaType
+	}
+	return "", ""
+}
+
+// isTextContentType checks if a content type carries text, all other content types are treated as binary
+func isTextContentType(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") || strings.HasSuffix(contentType, "json") ||
+		strings.HasSuffix(contentType, "xml")
+}
+
+// mediaRange is a single entry of an Accept header
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// specificity orders exact media types before type/* and */*
+func (m mediaRange) specificity() int {
+	switch {
+	case m.mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(m.mediaType, "/*"):
+		return 1
+	}
+	return 2
+}
+
+func (m mediaRange) matches(mediaType string) bool {
+	switch m.specificity() {
+	case 0:
+		return true
+	case 1:
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(m.mediaType, "*"))
+	}
+	return m.mediaType == mediaType
+}
+
+// parseAccept parses Accept header values, ranges are sorted by quality, specificity and then the order they were sent in
+func parseAccept(values []string) []mediaRange {
+	ranges := make([]mediaRange, 0)
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			mediaType, params, err := mime.ParseMediaType(part)
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if value, ok := params["q"]; ok {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+			ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
+
+// acceptQuality is the quality of the most specific range matching the media type, -1 when no range matches
+func acceptQuality(ranges []mediaRange, mediaType string) float64 {
+	quality, specificity := -1.0, -1
+	for _, r := range ranges {
+		if r.matches(mediaType) && r.specificity() > specificity {
+			quality, specificity = r.q, r.specificity()
+		}
+	}
+	return quality
+}
+
+// negotiateAccept picks the serialization type and content type from Accept header values, an empty
+// serialization type means the client has no preference
+func negotiateAccept(values []string, requestMediaType string) (string, string, error) {
+	ranges := parseAccept(values)
+	if len(ranges) == 0 {
+		return "", "", nil
+	}
+	// media types tried for wildcard ranges, the request content type is preferred over the codec defaults
+	candidates := make([]string, 0, len(codecPreference)+1)
+	if requestMediaType != "" {
+		candidates = append(candidates, requestMediaType)
+	}
+	for _, t := range codecPreference {
+		candidates = append(candidates, getCodec(t).ContentType())
+	}
+	for _, r := range ranges {
+		if r.q <= 0 {
+			continue
+		}
+		if r.specificity() == 2 {
+			if t, mediaType := serTypeForMediaType(r.mediaType); t != "" && acceptQuality(ranges, mediaType) > 0 {
+				return t, mediaType, nil
+			}
+			continue
+		}
+		for _, candidate := range candidates {
+			if r.matches(candidate) && acceptQuality(ranges, candidate) > 0 {
+				if t, mediaType := serTypeForMediaType(candidate); t != "" {
+					return t, mediaType, nil
+				}
+			}
+		}
+	}
+	return "", "", ErrNotAcceptable
+}
+
+// acceptedMediaType picks the content type of a response with a selected serialization type, the first accepted
+// media type that is either registered for the serialization type or its default content type is used, so that
+// clients can tell codecs sharing a default content type apart, e.g. by accepting 'application/jsonpb'
+func acceptedMediaType(ranges []mediaRange, serType, contentType string) string {
+	for _, r := range ranges {
+		if r.q <= 0 || r.specificity() != 2 {
+			continue
+		}
+		if r.mediaType == contentType {
+			return contentType
+		}
+		if t, mediaType := serTypeForMediaType(r.mediaType); t == strings.ToUpper(serType) {
+			return mediaType
+		}
+	}
+	return contentType
+}
+
+// negotiate finds the serialization type and content type of the response
+func negotiate(ctx context.Context) (string, string, error) {
+	hdrs := headers.RequestHeadersFromContext(ctx)
+	// first check if any serialization is forced
+	if serType, _ := modifiers.GetSerialization(ctx); serType != "" {
+		return serType, acceptedMediaType(parseAccept(hdrs["Accept"]), serType, getCodec(serType).ContentType()), nil
+	}
+	// then the codec selected for the method, as long as the client accepts it
+	if value, found := options.FromContext(ctx).Get(methodCodecOption); found {
+		if serType, ok := value.(string); ok {
+			contentType := getCodec(serType).ContentType()
+			ranges := parseAccept(hdrs["Accept"])
+			if len(ranges) == 0 {
+				return serType, contentType, nil
+			}
+			if mediaType := acceptedMediaType(ranges, serType, contentType); acceptQuality(ranges, mediaType) > 0 {
+				return serType, mediaType, nil
+			}
+		}
+	}
+	requestType, requestMediaType := "", ""
+	for _, v := range hdrs["Content-Type"] {
+		if requestType, requestMediaType = serTypeForMediaType(v); requestType != "" {
+			break
+		}
+	}
+	// try and match an accept header
+	serType, contentType, err := negotiateAccept(hdrs["Accept"], requestMediaType)
+	if err != nil || serType != "" {
+		return serType, contentType, err
+	}
+	// try and match the original content type
+	if requestType != "" {
+		return requestType, requestMediaType, nil
+	}
+	return modifiers.JSON, ContentTypeJSON, nil
+}
+
+// encodeErrorToHTTP converts errors returned by encoders into a HTTP status code and message
+func encodeErrorToHTTP(err error) (int, string) {
+	switch {
+	case isRequestTooLarge(err):
+		return http.StatusRequestEntityTooLarge, "Request Entity Too Large!"
+	case isCause(err, ErrUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType, "Unsupported Media Type!"
+	}
+	return http.StatusBadRequest, "Bad Request!"
+}
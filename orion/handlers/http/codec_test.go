@@ -0,0 +1,227 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-orion/Orion/orion/handlers"
+	"github.com/go-orion/Orion/orion/modifiers"
+	orionoptions "github.com/go-orion/Orion/orion/options"
+	"github.com/go-orion/Orion/utils/headers"
+	"github.com/go-orion/Orion/utils/options"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAccept(t *testing.T) {
+	tests := []struct {
+		accept []string
+		ranges []mediaRange
+	}{
+		{nil, []mediaRange{}},
+		{[]string{""}, []mediaRange{}},
+		{[]string{"application/json"}, []mediaRange{{"application/json", 1}}},
+		// quality first, then specificity, then the order sent
+		{[]string{"*/*, application/*, application/json"}, []mediaRange{{"application/json", 1}, {"application/*", 1}, {"*/*", 1}}},
+		{[]string{"application/json;q=0.5, application/protobuf"}, []mediaRange{{"application/protobuf", 1}, {"application/json", 0.5}}},
+		{[]string{"text/html", "application/json;q=0.9"}, []mediaRange{{"text/html", 1}, {"application/json", 0.9}}},
+		{[]string{"application/json, application/protobuf"}, []mediaRange{{"application/json", 1}, {"application/protobuf", 1}}},
+		{[]string{"Application/JSON; charset=utf-8"}, []mediaRange{{"application/json", 1}}},
+		// invalid ranges are skipped and invalid qualities ignored
+		{[]string{"bad/;, application/json;q=high"}, []mediaRange{{"application/json", 1}}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.ranges, parseAccept(test.accept), "%v", test.accept)
+	}
+}
+
+func TestAcceptQuality(t *testing.T) {
+	ranges := parseAccept([]string{"application/json;q=0.2, application/*;q=0.5, */*;q=0.1"})
+	assert.Equal(t, 0.2, acceptQuality(ranges, "application/json"), "the most specific range should win")
+	assert.Equal(t, 0.5, acceptQuality(ranges, "application/protobuf"))
+	assert.Equal(t, 0.1, acceptQuality(ranges, "text/html"))
+	assert.Equal(t, -1.0, acceptQuality(parseAccept([]string{"text/*"}), "application/json"))
+}
+
+func TestNegotiateAccept(t *testing.T) {
+	tests := []struct {
+		accept      string
+		request     string
+		serType     string
+		contentType string
+		err         error
+	}{
+		{"", "", "", "", nil},
+		{"application/json", "", modifiers.JSON, ContentTypeJSON, nil},
+		{"application/protobuf", "", modifiers.ProtoBuf, ContentTypeProtobuf, nil},
+		{"application/x-protobuf", "", modifiers.ProtoBuf, "application/x-protobuf", nil},
+		{"application/jsonpb", "", modifiers.JSONPB, ContentTypeJSONPB, nil},
+		{"application/vnd.api+json", "", modifiers.JSON, "application/vnd.api+json", nil},
+		{"text/html", "", "", "", ErrNotAcceptable},
+		{"text/html, application/json;q=0.1", "", modifiers.JSON, ContentTypeJSON, nil},
+		{"application/json;q=0.5, application/protobuf", "", modifiers.ProtoBuf, ContentTypeProtobuf, nil},
+		// wildcards prefer the request content type and then the codec preference
+		{"*/*", "", modifiers.JSON, ContentTypeJSON, nil},
+		{"*/*", ContentTypeProtobuf, modifiers.ProtoBuf, ContentTypeProtobuf, nil},
+		{"application/*", "", modifiers.JSON, ContentTypeJSON, nil},
+		{"application/json;q=0, */*", "", modifiers.ProtoBuf, ContentTypeProtobuf, nil},
+		{"application/json;q=0, application/protobuf;q=0", "", "", "", ErrNotAcceptable},
+		{"text/*", "", "", "", ErrNotAcceptable},
+	}
+	for _, test := range tests {
+		serType, contentType, err := negotiateAccept([]string{test.accept}, test.request)
+		assert.Equal(t, test.serType, serType, test.accept)
+		assert.Equal(t, test.contentType, contentType, test.accept)
+		assert.Equal(t, test.err, err, test.accept)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name        string
+		accept      string
+		request     string
+		serialize   string
+		serType     string
+		contentType string
+		err         error
+	}{
+		{"no headers", "", "", "", modifiers.JSON, ContentTypeJSON, nil},
+		{"request content type", "", ContentTypeProtobuf, "", modifiers.ProtoBuf, ContentTypeProtobuf, nil},
+		{"request content type with parameters", "", "application/json; charset=utf-8", "", modifiers.JSON, ContentTypeJSON, nil},
+		{"accept over content type", "application/json", ContentTypeProtobuf, "", modifiers.JSON, ContentTypeJSON, nil},
+		{"not acceptable", "text/html", ContentTypeJSON, "", "", "", ErrNotAcceptable},
+		{"forced serialization", "text/html", "", modifiers.ProtoBuf, modifiers.ProtoBuf, ContentTypeProtobuf, nil},
+		{"forced serialization with accepted alias", "application/x-protobuf", "", modifiers.ProtoBuf, modifiers.ProtoBuf, "application/x-protobuf", nil},
+	}
+	for _, test := range tests {
+		ctx := headers.AddToRequestHeaders(context.Background(), "", "")
+		if test.accept != "" {
+			ctx = headers.AddToRequestHeaders(ctx, "Accept", test.accept)
+		}
+		if test.request != "" {
+			ctx = headers.AddToRequestHeaders(ctx, "Content-Type", test.request)
+		}
+		ctx = options.AddToOptions(ctx, "", nil)
+		if test.serialize != "" {
+			modifiers.SerializeOut(ctx, test.serialize)
+		}
+		serType, contentType, err := negotiate(ctx)
+		assert.Equal(t, test.serType, serType, test.name)
+		assert.Equal(t, test.contentType, contentType, test.name)
+		assert.Equal(t, test.err, err, test.name)
+	}
+}
+
+// textCodec is a codec registered by tests
+type textCodec struct{}
+
+func (textCodec) Marshal(msg interface{}) ([]byte, error) {
+	return []byte(fmt.Sprint(msg)), nil
+}
+
+func (textCodec) Unmarshal(data []byte, msg interface{}) error {
+	return nil
+}
+
+func (textCodec) ContentType() string {
+	return "text/plain"
+}
+
+// registerTestCodec registers textCodec as the TEXT serialization type until the test ends
+func registerTestCodec(t *testing.T) {
+	preference := codecPreference
+	t.Cleanup(func() {
+		delete(codecs, "TEXT")
+		delete(ContentTypeMap, "text/plain")
+		delete(ContentTypeMap, "text/x-plain")
+		codecPreference = preference
+	})
+	RegisterCodec("text", textCodec{}, "Text/X-Plain")
+}
+
+func TestRegisterCodec(t *testing.T) {
+	registerTestCodec(t)
+	assert.Equal(t, textCodec{}, getCodec("Text"), "serialization types should be case insensitive")
+	assert.Equal(t, jsonCodec{}, getCodec("unknown"), "unknown serialization types should fall back to json")
+	assert.Equal(t, "TEXT", ContentTypeMap["text/plain"], "the default content type should be registered")
+	assert.Equal(t, "TEXT", ContentTypeMap["text/x-plain"], "content types should be registered in lower case")
+	assert.Equal(t, "TEXT", codecPreference[len(codecPreference)-1], "new codecs should be preferred last")
+
+	serType, contentType, err := negotiateAccept([]string{"text/x-plain"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "TEXT", serType)
+	assert.Equal(t, "text/x-plain", contentType)
+	serType, _, _ = negotiateAccept([]string{"text/*"}, "")
+	assert.Equal(t, "TEXT", serType, "registered codecs should be candidates for wildcards")
+
+	// registering again replaces the codec without changing the preference
+	RegisterCodec("TEXT", textCodec{})
+	assert.Equal(t, 1, strings.Count(strings.Join(codecPreference, ","), "TEXT"))
+}
+
+func TestMethodCodec(t *testing.T) {
+	tests := []struct {
+		name        string
+		codec       string
+		accept      string
+		serType     string
+		contentType string
+	}{
+		{"no accept", modifiers.ProtoBuf, "", modifiers.ProtoBuf, ContentTypeProtobuf},
+		{"accepted", modifiers.ProtoBuf, "application/protobuf", modifiers.ProtoBuf, ContentTypeProtobuf},
+		{"accepted by wildcard", modifiers.ProtoBuf, "*/*", modifiers.ProtoBuf, ContentTypeProtobuf},
+		{"accepted alias", modifiers.ProtoBuf, "application/x-protobuf", modifiers.ProtoBuf, "application/x-protobuf"},
+		{"jsonpb", modifiers.JSONPB, "application/jsonpb, application/json;q=0.9", modifiers.JSONPB, ContentTypeJSONPB},
+		// the client is asked before the method codec is skipped
+		{"not accepted", modifiers.ProtoBuf, "application/json", modifiers.JSON, ContentTypeJSON},
+	}
+	for _, test := range tests {
+		ctx := headers.AddToRequestHeaders(context.Background(), "", "")
+		if test.accept != "" {
+			ctx = headers.AddToRequestHeaders(ctx, "Accept", test.accept)
+		}
+		ctx = processOptions(ctx, nil, &methodInfo{options: []string{"codec=" + strings.ToLower(test.codec)}})
+		serType, contentType, err := negotiate(ctx)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.serType, serType, test.name)
+		assert.Equal(t, test.contentType, contentType, test.name)
+	}
+}
+
+func TestCodecOptions(t *testing.T) {
+	msg := &orionoptions.Auth{}
+	emitted := `{"middlewares":[],"skip":false}`
+	tests := []struct {
+		name    string
+		config  handlers.CodecOptions
+		options []string
+		serType string
+		data    string
+	}{
+		{"defaults", handlers.CodecOptions{}, nil, modifiers.JSON, `{}`},
+		{"handler options are used by jsonpb", handlers.CodecOptions{EmitDefaults: true}, nil, modifiers.JSON, `{}`},
+		{"handler options", handlers.CodecOptions{EmitDefaults: true}, []string{"CODEC=JSONPB"}, modifiers.JSONPB, emitted},
+		{"method options select jsonpb", handlers.CodecOptions{}, []string{EmitDefaults}, modifiers.JSONPB, emitted},
+		{"method options add to handler options", handlers.CodecOptions{OrigName: true}, []string{" emit_defaults "}, modifiers.JSONPB, emitted},
+		{"method options override handler options", handlers.CodecOptions{EmitDefaults: true}, []string{"emit_defaults=false"}, modifiers.JSONPB, `{}`},
+		{"invalid values are ignored", handlers.CodecOptions{EmitDefaults: true}, []string{"EMIT_DEFAULTS=MAYBE"}, modifiers.JSON, `{}`},
+		{"method codec", handlers.CodecOptions{}, []string{"CODEC=JSON", EmitDefaults}, modifiers.JSON, `{}`},
+	}
+	for _, test := range tests {
+		h := &httpHandler{config: Config{Codec: test.config}}
+		ctx := headers.AddToRequestHeaders(context.Background(), "", "")
+		ctx = processOptions(ctx, nil, &methodInfo{options: test.options})
+		ctx = options.AddToOptions(ctx, "", nil)
+		serType, _, err := negotiate(ctx)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.serType, serType, test.name)
+		data, err := h.codec(ctx, serType).Marshal(msg)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.data, string(data), test.name)
+	}
+	// codecs without options are used as is
+	h := &httpHandler{config: Config{Codec: handlers.CodecOptions{EmitDefaults: true}}}
+	assert.Equal(t, jsonCodec{}, h.codec(context.Background(), modifiers.JSON))
+}
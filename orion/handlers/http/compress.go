@@ -0,0 +1,256 @@
+package http
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-orion/Orion/utils/errors"
+)
+
+const (
+	// DefaultCompressionMinSize is the minimum response size compressed when none is configured
+	DefaultCompressionMinSize = 1024
+	// DefaultMaxDecompressedSize is the maximum size a compressed request body can expand to when none is configured
+	DefaultMaxDecompressedSize int64 = 10 << 20
+)
+
+var (
+	// ErrRequestTooLarge is returned when reading a request body larger than the allowed size
+	ErrRequestTooLarge = errors.New("request body too large")
+	// ErrUnsupportedEncoding is returned when a request body uses an unknown content encoding
+	ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+	compressors = map[string]Compressor{
+		"gzip": {
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return gzip.NewWriter(w), nil
+			},
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return gzip.NewReader(r)
+			},
+		},
+		"deflate": {
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return flate.NewWriter(w, flate.DefaultCompression)
+			},
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return flate.NewReader(r), nil
+			},
+		},
+	}
+	// compressionPreference is the order in which encodings are picked when the client accepts them equally, zstd is only
+	// registered in cgo builds, see compress_zstd.go
+	compressionPreference = []string{"zstd", "br", "gzip", "deflate"}
+)
+
+// Compressor provides the writers and readers for a content encoding
+type Compressor struct {
+	NewWriter func(io.Writer) (io.WriteCloser, error)
+	NewReader func(io.Reader) (io.ReadCloser, error)
+}
+
+// RegisterCompressor registers a content encoding that can be negotiated for responses and used by requests,
+// this should be called before the server is started
+func RegisterCompressor(encoding string, compressor Compressor) {
+	encoding = strings.ToLower(encoding)
+	if _, ok := compressors[encoding]; !ok {
+		found := false
+		for _, name := range compressionPreference {
+			if name == encoding {
+				found = true
+			}
+		}
+		if !found {
+			compressionPreference = append(compressionPreference, encoding)
+		}
+	}
+	compressors[encoding] = compressor
+}
+
+// negotiateEncoding picks the response encoding from the Accept-Encoding header, an empty string means identity
+func negotiateEncoding(acceptEncoding string) string {
+	if strings.TrimSpace(acceptEncoding) == "" {
+		return ""
+	}
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, q := parseQuality(part)
+		if name != "" {
+			qualities[name] = q
+		}
+	}
+	best, bestQ := "", 0.0
+	for _, name := range compressionPreference {
+		if _, ok := compressors[name]; !ok {
+			continue
+		}
+		q, ok := qualities[name]
+		if !ok {
+			if q, ok = qualities["*"]; !ok {
+				continue
+			}
+		}
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// parseQuality parses a single header element like 'gzip;q=0.8'
+func parseQuality(part string) (string, float64) {
+	params := strings.Split(part, ";")
+	name := strings.ToLower(strings.TrimSpace(params[0]))
+	q := 1.0
+	for _, param := range params[1:] {
+		param = strings.TrimSpace(param)
+		if strings.HasPrefix(param, "q=") {
+			if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				q = value
+			}
+		}
+	}
+	return name, q
+}
+
+// decompressRequest replaces the body of an encoded request with a decompressing reader limited to maxSize
+func decompressRequest(req *http.Request, maxSize int64) error {
+	encoding := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		return nil
+	}
+	compressor, ok := compressors[encoding]
+	if !ok {
+		return ErrUnsupportedEncoding
+	}
+	reader, err := compressor.NewReader(req.Body)
+	if err != nil {
+		return errors.Wrap(err, "could not decompress request")
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxDecompressedSize
+	}
+	req.Body = &limitedBody{
+		reader:  reader,
+		closers: []io.Closer{reader, req.Body},
+		remain:  maxSize,
+	}
+	req.Header.Del("Content-Encoding")
+	req.Header.Del("Content-Length")
+	req.ContentLength = -1
+	return nil
+}
+
+// compressWriter buffers the response until it reaches the minimum size and then compresses it with the negotiated encoding
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	status   int
+	buf      []byte
+	writer   io.WriteCloser
+	direct   bool
+}
+
+func newCompressWriter(resp http.ResponseWriter, encoding string, minSize int) *compressWriter {
+	if minSize <= 0 {
+		minSize = DefaultCompressionMinSize
+	}
+	return &compressWriter{
+		ResponseWriter: resp,
+		encoding:       encoding,
+		minSize:        minSize,
+	}
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if c.status == 0 {
+		c.status = code
+	}
+}
+
+func (c *compressWriter) Write(data []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	if c.writer != nil {
+		return c.writer.Write(data)
+	}
+	if c.direct {
+		return c.ResponseWriter.Write(data)
+	}
+	c.buf = append(c.buf, data...)
+	if len(c.buf) < c.minSize {
+		return len(data), nil
+	}
+	if err := c.start(); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// start writes the headers and the buffered data, compressing it if the response allows it
+func (c *compressWriter) start() error {
+	buf := c.buf
+	c.buf = nil
+	if c.Header().Get("Content-Encoding") != "" || c.status < http.StatusOK ||
+		c.status == http.StatusNoContent || c.status == http.StatusNotModified {
+		// already encoded or no body
+		c.direct = true
+		c.ResponseWriter.WriteHeader(c.status)
+		_, err := c.ResponseWriter.Write(buf)
+		return err
+	}
+	writer, err := compressors[c.encoding].NewWriter(c.ResponseWriter)
+	if err != nil {
+		return err
+	}
+	c.writer = writer
+	c.Header().Set("Content-Encoding", c.encoding)
+	c.Header().Del("Content-Length")
+	c.ResponseWriter.WriteHeader(c.status)
+	_, err = c.writer.Write(buf)
+	return err
+}
+
+// Flush sends the buffered response to the client, the response is compressed from then on regardless of its size
+func (c *compressWriter) Flush() {
+	if c.writer == nil && !c.direct {
+		if c.status == 0 {
+			c.status = http.StatusOK
+		}
+		if err := c.start(); err != nil {
+			return
+		}
+	}
+	if flusher, ok := c.writer.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the original response writer, it is used by http.NewResponseController
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// Close flushes the response, responses smaller than the minimum size are written uncompressed
+func (c *compressWriter) Close() error {
+	if c.writer != nil {
+		return c.writer.Close()
+	}
+	if c.direct || c.status == 0 {
+		return nil
+	}
+	c.direct = true
+	c.ResponseWriter.WriteHeader(c.status)
+	_, err := c.ResponseWriter.Write(c.buf)
+	c.buf = nil
+	return err
+}
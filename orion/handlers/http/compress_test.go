@@ -0,0 +1,202 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		accept   string
+		encoding string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"gzip;q=0", ""},
+		{"*", compressionPreference[0]},
+		{"br;q=1, unknown", ""},
+	}
+	for _, test := range tests {
+		encoding := negotiateEncoding(test.accept)
+		if test.encoding == compressionPreference[0] {
+			// zstd is not registered in builds without cgo
+			assert.NotEmpty(t, encoding, test.accept)
+			continue
+		}
+		assert.Equal(t, test.encoding, encoding, test.accept)
+	}
+}
+
+func TestCompressedResponse(t *testing.T) {
+	base := startTestHandler(t, Config{EnableCompression: true}, nil)
+	long := strings.Repeat("hello ", 300)
+
+	req, _ := http.NewRequest(http.MethodPost, base+"/testservice/upper", strings.NewReader(`{"value":"`+long+`"}`))
+	req.Header.Set("Content-Type", ContentTypeJSON)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if assert.NoError(t, err) {
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		reader, err := gzip.NewReader(resp.Body)
+		if assert.NoError(t, err) {
+			data, _ := ioutil.ReadAll(reader)
+			assert.Contains(t, string(data), strings.ToUpper(long))
+		}
+	}
+
+	// small responses are not compressed
+	req, _ = http.NewRequest(http.MethodPost, base+"/testservice/upper", strings.NewReader(`{"value":"hello"}`))
+	req.Header.Set("Content-Type", ContentTypeJSON)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err = http.DefaultTransport.RoundTrip(req)
+	if assert.NoError(t, err) {
+		defer resp.Body.Close()
+		assert.Empty(t, resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+	}
+}
+
+func TestCompressedRequest(t *testing.T) {
+	base := startTestHandler(t, Config{}, nil)
+	gzipped := new(bytes.Buffer)
+	w := gzip.NewWriter(gzipped)
+	w.Write([]byte(`{"value":"hello"}`))
+	w.Close()
+
+	tests := []struct {
+		encoding string
+		body     []byte
+		status   int
+	}{
+		{"gzip", gzipped.Bytes(), http.StatusOK},
+		{"gzip", []byte("not gzip"), http.StatusBadRequest},
+		{"unknown", gzipped.Bytes(), http.StatusUnsupportedMediaType},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodPost, base+"/testservice/upper", bytes.NewReader(test.body))
+		req.Header.Set("Content-Type", ContentTypeJSON)
+		req.Header.Set("Content-Encoding", test.encoding)
+		resp, err := http.DefaultClient.Do(req)
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+			assert.Equal(t, test.status, resp.StatusCode, test.encoding+" "+string(test.body))
+		}
+	}
+}
+
+func TestCompressedRequestHTTPHandler(t *testing.T) {
+	echo := func(resp http.ResponseWriter, req *http.Request) bool {
+		data, _ := ioutil.ReadAll(req.Body)
+		resp.Write(data)
+		return true
+	}
+	base := startTestHandler(t, Config{}, func(h *httpHandler) {
+		h.AddHTTPHandler("test.TestService", "Upper", "", echo)
+		h.AddHTTPHandler("test.TestService", "Split", "", echo)
+	})
+	gzipped := new(bytes.Buffer)
+	w := gzip.NewWriter(gzipped)
+	w.Write([]byte(`{"value":"hello"}`))
+	w.Close()
+
+	// handlers of unary and streaming methods get the decompressed body
+	for _, path := range []string{"/testservice/upper", "/testservice/split"} {
+		req, _ := http.NewRequest(http.MethodPost, base+path, bytes.NewReader(gzipped.Bytes()))
+		req.Header.Set("Content-Type", ContentTypeJSON)
+		req.Header.Set("Content-Encoding", "gzip")
+		resp, err := http.DefaultClient.Do(req)
+		if assert.NoError(t, err, path) {
+			data, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Equal(t, `{"value":"hello"}`, string(data), path)
+		}
+	}
+}
+
+func TestCompressedStreams(t *testing.T) {
+	base := startTestHandler(t, Config{EnableCompression: true, EnableGRPCWeb: true}, nil)
+
+	// chunked streams are compressed up to their trailing message
+	req, _ := http.NewRequest(http.MethodPost, base+"/testservice/split", strings.NewReader(`{"value":"hello fail"}`))
+	req.Header.Set("Content-Type", ContentTypeJSON)
+	req.Header.Set("Accept", ContentTypeNDJSON)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if assert.NoError(t, err) {
+		defer resp.Body.Close()
+		assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		reader, err := gzip.NewReader(resp.Body)
+		if assert.NoError(t, err) {
+			data, err := ioutil.ReadAll(reader)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"value":"hello"}`+"\n"+`{"error":{"code":10,"status":"Aborted","message":"cannot split fail"}}`+"\n", string(data))
+		}
+	}
+
+	// gRPC-Web calls are compressed up to their trailers
+	req = grpcWebRequest(t, base+"/test.TestService/Split", ContentTypeGRPCWeb, "hello world", map[string]string{"Accept-Encoding": "gzip"})
+	resp, err = http.DefaultTransport.RoundTrip(req)
+	if assert.NoError(t, err) {
+		defer resp.Body.Close()
+		assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		reader, err := gzip.NewReader(resp.Body)
+		if assert.NoError(t, err) {
+			resp.Body = ioutil.NopCloser(reader)
+			messages, trailer := readGRPCWebResponse(t, resp)
+			assert.Equal(t, []string{"hello", "world"}, messages)
+			assert.Equal(t, "grpc-status: 0\r\nx-words: 2\r\n", trailer)
+		}
+	}
+}
+
+func TestCompressionHTTPHandler(t *testing.T) {
+	base := startTestHandler(t, Config{EnableCompression: true}, func(h *httpHandler) {
+		h.AddHTTPHandler("test.TestService", "Upper", "", func(resp http.ResponseWriter, req *http.Request) bool {
+			// handlers get the original writer and can take over the connection
+			conn, buf, err := http.NewResponseController(resp).Hijack()
+			if !assert.NoError(t, err) {
+				return false
+			}
+			defer conn.Close()
+			buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+			buf.Flush()
+			return true
+		})
+	})
+	req, _ := http.NewRequest(http.MethodPost, base+"/testservice/upper", strings.NewReader(`{"value":"hello"}`))
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if assert.NoError(t, err) {
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		assert.Equal(t, "hijacked", string(data))
+	}
+}
+
+func TestCompressWriterFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	cw := newCompressWriter(rec, "gzip", 1024)
+	cw.Write([]byte("small"))
+	cw.Flush()
+	assert.True(t, rec.Flushed, "flush should reach the original writer")
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"), "flushed responses are compressed")
+	assert.NoError(t, cw.Close())
+	reader, err := gzip.NewReader(rec.Body)
+	if assert.NoError(t, err) {
+		data, _ := ioutil.ReadAll(reader)
+		assert.Equal(t, "small", string(data))
+	}
+	assert.Equal(t, rec, cw.Unwrap())
+}
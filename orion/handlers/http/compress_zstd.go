@@ -0,0 +1,24 @@
+//go:build cgo
+// +build cgo
+
+package http
+
+// zstd is provided by a cgo library, builds with CGO_ENABLED=0 do not negotiate zstd and answer zstd encoded requests
+// with 415, a pure Go implementation can be registered with RegisterCompressor
+
+import (
+	"io"
+
+	"github.com/DataDog/zstd"
+)
+
+func init() {
+	RegisterCompressor("zstd", Compressor{
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w), nil
+		},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return zstd.NewReader(r), nil
+		},
+	})
+}
@@ -2,26 +2,41 @@ package http
 
 import (
 	"context"
-	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/go-orion/Orion/orion/handlers"
 	"github.com/go-orion/Orion/orion/modifiers"
-	"github.com/golang/protobuf/jsonpb"
-	"github.com/golang/protobuf/proto"
+	"github.com/go-orion/Orion/utils/headers"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"github.com/mitchellh/mapstructure"
 )
 
+var (
+	// DefaultWSConfig is the websocket configuration used when none is provided
+	DefaultWSConfig = handlers.WSConfig{
+		HandshakeTimeout: time.Second * 2,
+		ReadBufferSize:   1024,
+		WriteBufferSize:  1024,
+	}
+)
+
 // DefaultEncoder encodes a HTTP request if none are registered. This encoder
 // populates the proto message with URL route variables or fields from a JSON
-// body if either are available.
+// body if either are available. Multipart form fields are bound into the proto
+// message while file parts are streamed to the registered file sink. GET, HEAD
+// and DELETE requests without a body are populated from query parameters.
 func DefaultEncoder(req *http.Request, r interface{}) error {
 	// check and map url params to request
 	params := mux.Vars(req)
+	if isMultipart(req) {
+		return decodeMultipart(req, params, r)
+	}
 	if len(params) > 0 {
 		mapstructure.Decode(params, r)
 	}
@@ -34,36 +49,96 @@ func DefaultEncoder(req *http.Request, r interface{}) error {
 		return err
 	}
 
-	if req.Method == http.MethodGet && len(data) == 0 {
-		return nil
+	if len(data) == 0 {
+		switch req.Method {
+		case http.MethodGet, http.MethodHead, http.MethodDelete:
+			// requests without a body send their fields as query parameters
+			if req.URL.RawQuery != "" {
+				return decodeQuery(req, params, r)
+			}
+			return nil
+		}
 	}
 	return deserialize(req.Context(), data, r)
 }
 
 func deserialize(ctx context.Context, data []byte, r interface{}) error {
 	serType := ContentTypeFromHeaders(ctx)
-	switch serType {
-	case modifiers.ProtoBuf:
-		if protoReq, ok := r.(proto.Message); ok {
-			return proto.Unmarshal(data, protoReq)
+	if serType == "" {
+		if len(headers.RequestHeadersFromContext(ctx)["Content-Type"]) > 0 {
+			return ErrUnsupportedMediaType
 		}
-		fallthrough
-	case modifiers.JSONPB:
-		if protoReq, ok := r.(proto.Message); ok {
-			return jsonpb.UnmarshalString(string(data), protoReq)
-		}
-		fallthrough
-	default:
-		return json.Unmarshal(data, r)
+		serType = modifiers.JSON
 	}
+	return getCodec(serType).Unmarshal(data, r)
 }
 
 // DefaultWSUpgrader upgrades a websocket if none are registered.
 func DefaultWSUpgrader(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (*websocket.Conn, error) {
+	return NewWSUpgrader(DefaultWSConfig)(w, r, responseHeader)
+}
+
+// NewWSUpgrader builds a websocket upgrader from the provided configuration
+func NewWSUpgrader(config handlers.WSConfig) handlers.WSUpgrader {
+	config = wsConfigWithDefaults(config)
 	up := websocket.Upgrader{
-		HandshakeTimeout: time.Second * 2,
-		ReadBufferSize:   1024,
-		WriteBufferSize:  1024,
+		HandshakeTimeout:  config.HandshakeTimeout,
+		ReadBufferSize:    config.ReadBufferSize,
+		WriteBufferSize:   config.WriteBufferSize,
+		Subprotocols:      config.Subprotocols,
+		EnableCompression: config.EnableCompression,
+	}
+	if len(config.AllowedOrigins) > 0 {
+		up.CheckOrigin = originChecker(config.AllowedOrigins)
+	}
+	return up.Upgrade
+}
+
+// wsConfigWithDefaults fills in the missing values from DefaultWSConfig
+func wsConfigWithDefaults(config handlers.WSConfig) handlers.WSConfig {
+	if config.HandshakeTimeout <= 0 {
+		config.HandshakeTimeout = DefaultWSConfig.HandshakeTimeout
+	}
+	if config.ReadBufferSize <= 0 {
+		config.ReadBufferSize = DefaultWSConfig.ReadBufferSize
+	}
+	if config.WriteBufferSize <= 0 {
+		config.WriteBufferSize = DefaultWSConfig.WriteBufferSize
+	}
+	if config.PingInterval > 0 && config.PongWait <= 0 {
+		config.PongWait = config.PingInterval * 2
+	}
+	return config
+}
+
+// originChecker allows requests without an origin header or with an origin present in the allowed list
+func originChecker(allowed []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			// not a browser request
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		host := strings.ToLower(u.Host)
+		hostname := strings.ToLower(u.Hostname())
+		for _, a := range allowed {
+			a = strings.ToLower(strings.TrimSpace(a))
+			switch {
+			case a == "*":
+				return true
+			case strings.HasPrefix(a, "*."):
+				// wildcards match the apex domain and its subdomains on any port
+				if hostname == a[2:] || strings.HasSuffix(hostname, a[1:]) {
+					return true
+				}
+			case a == strings.ToLower(origin), a == host:
+				return true
+			}
+		}
+		return false
 	}
-	return up.Upgrade(w, r, responseHeader)
 }
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-orion/Orion/orion/handlers"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOriginChecker(t *testing.T) {
+	tests := []struct {
+		allowed []string
+		origin  string
+		ok      bool
+	}{
+		{nil, "", true},
+		{nil, "https://example.com", false},
+		{[]string{"*"}, "https://any.org", true},
+		{[]string{"https://example.com"}, "https://example.com", true},
+		{[]string{"https://example.com"}, "http://example.com", false},
+		{[]string{"example.com"}, "http://example.com", true},
+		{[]string{"example.com:8443"}, "https://example.com:8443", true},
+		{[]string{"example.com"}, "https://example.com:8443", false},
+		{[]string{"*.example.com"}, "https://example.com", true},
+		{[]string{"*.example.com"}, "https://app.example.com", true},
+		{[]string{"*.example.com"}, "https://a.b.example.com", true},
+		{[]string{"*.example.com"}, "https://app.example.com:8443", true},
+		{[]string{" *.EXAMPLE.com "}, "https://App.Example.com", true},
+		{[]string{"*.example.com"}, "https://badexample.com", false},
+		{[]string{"*.example.com"}, "https://example.com.evil.com", false},
+		{[]string{"other.com", "*.example.com"}, "https://app.example.com", true},
+		{[]string{"*.example.com"}, "://bad", false},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if test.origin != "" {
+			req.Header.Set("Origin", test.origin)
+		}
+		assert.Equal(t, test.ok, originChecker(test.allowed)(req), "%v %s", test.allowed, test.origin)
+	}
+}
+
+func TestWSConfigWithDefaults(t *testing.T) {
+	config := wsConfigWithDefaults(handlers.WSConfig{})
+	assert.Equal(t, DefaultWSConfig.HandshakeTimeout, config.HandshakeTimeout)
+	assert.Equal(t, DefaultWSConfig.ReadBufferSize, config.ReadBufferSize)
+	assert.Equal(t, DefaultWSConfig.WriteBufferSize, config.WriteBufferSize)
+	assert.Zero(t, config.PongWait, "no pong wait without pings")
+
+	config = wsConfigWithDefaults(handlers.WSConfig{PingInterval: time.Second})
+	assert.Equal(t, 2*time.Second, config.PongWait)
+	config = wsConfigWithDefaults(handlers.WSConfig{PingInterval: time.Second, PongWait: 5 * time.Second})
+	assert.Equal(t, 5*time.Second, config.PongWait)
+}
+
+func TestWSUpgraderOrigins(t *testing.T) {
+	upgrade := NewWSUpgrader(handlers.WSConfig{AllowedOrigins: []string{"*.example.com"}})
+	srv := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if con, err := upgrade(resp, req, nil); err == nil {
+			con.Close()
+		}
+	}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	for origin, ok := range map[string]bool{"https://example.com": true, "https://app.example.com": true, "https://evil.com": false} {
+		con, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {origin}})
+		assert.Equal(t, ok, err == nil, origin)
+		if con != nil {
+			con.Close()
+		} else if assert.NotNil(t, resp, origin) {
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode, origin)
+		}
+	}
+}
+
+func TestWSKeepalive(t *testing.T) {
+	streams := make(chan *streamServer, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		con, err := NewWSUpgrader(handlers.WSConfig{})(resp, req, nil)
+		if err != nil {
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := &streamServer{ctx: ctx, cancel: cancel, con: con}
+		streams <- stream
+		stream.keepalive(10 * time.Millisecond)
+		con.Close()
+	}))
+	defer srv.Close()
+
+	con, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var pings int32
+	con.SetPingHandler(func(string) error {
+		atomic.AddInt32(&pings, 1)
+		return nil
+	})
+	// pings are processed while reading
+	go func() {
+		for {
+			if _, _, err := con.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+	stream := <-streams
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, atomic.LoadInt32(&pings) >= 2, "client should be pinged on every interval")
+
+	// the stream is canceled once pings can no longer be sent
+	con.Close()
+	select {
+	case <-stream.ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Error("stream should be canceled when the client goes away")
+	}
+}
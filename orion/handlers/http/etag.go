@@ -0,0 +1,69 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// etag computes a strong entity tag for a serialized response, the content type and the negotiated
+// content encoding are part of the tag as each of them is a different representation
+func etag(data []byte, contentType string, resp http.ResponseWriter) string {
+	hash := sha256.New()
+	hash.Write([]byte(contentType))
+	if cw, ok := resp.(*compressWriter); ok {
+		hash.Write([]byte(cw.encoding))
+	}
+	hash.Write(data)
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches checks the If-None-Match header values against a entity tag using weak comparison
+func etagMatches(ifNoneMatch []string, tag string) bool {
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, value := range ifNoneMatch {
+		for _, candidate := range strings.Split(value, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// notModified evaluates the conditional headers of a GET or HEAD request, If-Modified-Since
+// is only used when the request has no If-None-Match header
+func notModified(req *http.Request, responseHeaders http.Header) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if values := req.Header["If-None-Match"]; len(values) > 0 {
+		tag := responseHeaders.Get("ETag")
+		return tag != "" && etagMatches(values, tag)
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(responseHeaders.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// etagEnabled checks if entity tags are computed for the method
+func (h *httpHandler) etagEnabled(info *methodInfo) bool {
+	if h.config.EnableETags {
+		return true
+	}
+	for _, opt := range info.options {
+		if strings.ToUpper(strings.TrimSpace(opt)) == ETag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETag(t *testing.T) {
+	tag := etag([]byte("data"), ContentTypeJSON, httptest.NewRecorder())
+	assert.True(t, strings.HasPrefix(tag, `"`) && strings.HasSuffix(tag, `"`), "tags should be quoted")
+	assert.Len(t, tag, 34)
+	assert.Equal(t, tag, etag([]byte("data"), ContentTypeJSON, httptest.NewRecorder()), "tags should be stable")
+	assert.NotEqual(t, tag, etag([]byte("other"), ContentTypeJSON, httptest.NewRecorder()))
+	assert.NotEqual(t, tag, etag([]byte("data"), ContentTypeProtobuf, httptest.NewRecorder()), "content types should change the tag")
+	cw := newCompressWriter(httptest.NewRecorder(), "gzip", 0)
+	assert.NotEqual(t, tag, etag([]byte("data"), ContentTypeJSON, cw), "content encodings should change the tag")
+}
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch []string
+		tag         string
+		ok          bool
+	}{
+		{[]string{`"abc"`}, `"abc"`, true},
+		{[]string{`"abc"`}, `"abd"`, false},
+		{[]string{`*`}, `"abc"`, true},
+		{[]string{`"x", "abc"`}, `"abc"`, true},
+		{[]string{`"x"`, `"abc"`}, `"abc"`, true},
+		// weak comparison
+		{[]string{`W/"abc"`}, `"abc"`, true},
+		{[]string{`"abc"`}, `W/"abc"`, true},
+		{[]string{`abc`}, `"abc"`, false},
+		{[]string{``}, `"abc"`, false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.ok, etagMatches(test.ifNoneMatch, test.tag), "%v %s", test.ifNoneMatch, test.tag)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		method   string
+		request  http.Header
+		response http.Header
+		ok       bool
+	}{
+		{"matching tag", http.MethodGet, http.Header{"If-None-Match": {`"a"`}}, http.Header{"Etag": {`"a"`}}, true},
+		{"head", http.MethodHead, http.Header{"If-None-Match": {`"a"`}}, http.Header{"Etag": {`"a"`}}, true},
+		{"post", http.MethodPost, http.Header{"If-None-Match": {`"a"`}}, http.Header{"Etag": {`"a"`}}, false},
+		{"other tag", http.MethodGet, http.Header{"If-None-Match": {`"b"`}}, http.Header{"Etag": {`"a"`}}, false},
+		{"no tag", http.MethodGet, http.Header{"If-None-Match": {`*`}}, http.Header{}, false},
+		{"not modified since", http.MethodGet, http.Header{"If-Modified-Since": {modified.Format(http.TimeFormat)}},
+			http.Header{"Last-Modified": {modified.Format(http.TimeFormat)}}, true},
+		{"modified since", http.MethodGet, http.Header{"If-Modified-Since": {modified.Add(-time.Hour).Format(http.TimeFormat)}},
+			http.Header{"Last-Modified": {modified.Format(http.TimeFormat)}}, false},
+		{"invalid date", http.MethodGet, http.Header{"If-Modified-Since": {"yesterday"}},
+			http.Header{"Last-Modified": {modified.Format(http.TimeFormat)}}, false},
+		{"no last modified", http.MethodGet, http.Header{"If-Modified-Since": {modified.Format(http.TimeFormat)}}, http.Header{}, false},
+		// If-Modified-Since is ignored when If-None-Match is sent
+		{"tags before dates", http.MethodGet, http.Header{"If-None-Match": {`"b"`}, "If-Modified-Since": {modified.Format(http.TimeFormat)}},
+			http.Header{"Etag": {`"a"`}, "Last-Modified": {modified.Format(http.TimeFormat)}}, false},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, "/", nil)
+		req.Header = test.request
+		assert.Equal(t, test.ok, notModified(req, test.response), test.name)
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		options []string
+		tagged  bool
+	}{
+		{"disabled", Config{}, nil, false},
+		{"handler", Config{EnableETags: true}, nil, true},
+		{"method option", Config{}, []string{" etag "}, true},
+	}
+	for _, test := range tests {
+		base := startTestHandler(t, test.config, func(h *httpHandler) {
+			h.AddEncoder("test.TestService", "Upper", []string{http.MethodGet}, "", nil)
+			for _, opt := range test.options {
+				h.AddOption("test.TestService", "Upper", opt)
+			}
+		})
+		resp, err := http.Get(base + "/testservice/upper?value=hello")
+		if !assert.NoError(t, err, test.name) {
+			continue
+		}
+		resp.Body.Close()
+		tag := resp.Header.Get("ETag")
+		assert.Equal(t, test.tagged, tag != "", test.name)
+		if tag == "" {
+			continue
+		}
+
+		for value, status := range map[string]int{"hello": http.StatusNotModified, "world": http.StatusOK} {
+			req, _ := http.NewRequest(http.MethodGet, base+"/testservice/upper?value="+value, nil)
+			req.Header.Set("If-None-Match", tag)
+			resp, err := http.DefaultClient.Do(req)
+			if !assert.NoError(t, err, test.name) {
+				continue
+			}
+			resp.Body.Close()
+			assert.Equal(t, status, resp.StatusCode, test.name+" "+value)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-orion/Orion/utils"
+	"github.com/go-orion/Orion/utils/accesslog"
+	"github.com/go-orion/Orion/utils/errors"
+	"github.com/go-orion/Orion/utils/errors/notifier"
+	"github.com/go-orion/Orion/utils/log"
+	"github.com/go-orion/Orion/utils/log/loggers"
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/mux"
+	"github.com/grpc-ecosystem/go-grpc-middleware/util/metautils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// grpcWebSkipHeaders are the request headers that are not passed on as gRPC metadata of gRPC-Web calls
+	grpcWebSkipHeaders = map[string]bool{
+		"connection":        true,
+		"content-length":    true,
+		"transfer-encoding": true,
+		"upgrade":           true,
+	}
+)
+
+const (
+	// ContentTypeGRPCWeb is the content type used by binary gRPC-Web requests
+	ContentTypeGRPCWeb = "application/grpc-web"
+	// ContentTypeGRPCWebText is the content type used by base64 encoded gRPC-Web requests
+	ContentTypeGRPCWebText = "application/grpc-web-text"
+
+	// grpcWebExposedHeaders are the response headers browsers need to read the status of a call
+	grpcWebExposedHeaders = "grpc-status, grpc-message"
+)
+
+// isGRPCWebRequest checks if the request is a gRPC-Web request
+func isGRPCWebRequest(req *http.Request, _ *mux.RouteMatch) bool {
+	return strings.HasPrefix(strings.ToLower(req.Header.Get("Content-Type")), ContentTypeGRPCWeb)
+}
+
+func (h *httpHandler) getGRPCWebHandler(serviceName, methodName string) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		h.grpcWebHandler(resp, req, serviceName, methodName)
+	}
+}
+
+// grpcWebUnknownHandler answers gRPC-Web calls to unknown or client streaming methods
+func (h *httpHandler) grpcWebUnknownHandler(resp http.ResponseWriter, req *http.Request) {
+	service, method := "", ""
+	if parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2); len(parts) == 2 {
+		service, method = parts[0], parts[1]
+	}
+	h.grpcWebHandler(resp, req, service, method)
+}
+
+// isPreflightRequest checks if the request is a CORS preflight request
+func isPreflightRequest(req *http.Request, _ *mux.RouteMatch) bool {
+	return req.Header.Get("Origin") != "" && req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// grpcWebPreflight answers the CORS preflight requests sent by browsers before calling gRPC-Web methods from other origins
+func (h *httpHandler) grpcWebPreflight(resp http.ResponseWriter, req *http.Request) {
+	if !h.grpcWebCORS(resp, req) {
+		writeResp(resp, http.StatusForbidden, []byte("Forbidden: origin not allowed"))
+		return
+	}
+	resp.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	if hdrs := req.Header.Get("Access-Control-Request-Headers"); hdrs != "" {
+		resp.Header().Set("Access-Control-Allow-Headers", hdrs)
+	}
+	resp.Header().Set("Access-Control-Max-Age", "600")
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+// grpcWebCORS allows the origin of a gRPC-Web request and exposes the status headers to the browser, requests from the
+// same origin and from the configured origins are allowed, it returns false for any other origin
+func (h *httpHandler) grpcWebCORS(resp http.ResponseWriter, req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err != nil || !strings.EqualFold(u.Host, req.Host) {
+		if !originChecker(h.config.GRPCWebAllowedOrigins)(req) {
+			return false
+		}
+	}
+	resp.Header().Set("Access-Control-Allow-Origin", origin)
+	resp.Header().Add("Vary", "Origin")
+	resp.Header().Set("Access-Control-Expose-Headers", grpcWebExposedHeaders)
+	return true
+}
+
+func (h *httpHandler) grpcWebHandler(resp http.ResponseWriter, req *http.Request, service, method string) {
+	ctx := utils.StartNRTransaction(req.URL.Path, req.Context(), req, resp)
+	var err error
+	var stream *grpcWebStream
+	defer func(t time.Time) {
+		if r := recover(); r != nil {
+			err = errors.Wrap(fmt.Errorf("panic: %v", r), "PANIC")
+			log.Error(ctx, "panic", r, "path", req.URL.String(), "took", time.Since(t))
+			// the browser reads the status of the call from the trailers
+			if stream != nil && !stream.finished {
+				stream.finish(status.Error(codes.Internal, "Internal Server Error!"))
+			} else if stream == nil {
+				writeResp(resp, http.StatusInternalServerError, []byte("Internal Server Error!"))
+			}
+		}
+		utils.FinishNRTransaction(ctx, err)
+		notifier.Notify(err, req.URL.String(), ctx)
+		if entry := accesslog.FromContext(ctx); entry != nil {
+			// requests are recorded by the access log
+			entry.Error = err
+			log.Debug(ctx, "path", req.URL.Path, "method", req.Method, "error", err, "took", time.Since(t))
+		} else {
+			log.Info(ctx, "path", req.URL.Path, "method", req.Method, "error", err, "took", time.Since(t))
+		}
+	}(time.Now())
+	h.grpcWebCORS(resp, req)
+	contentType := req.Header.Get("Content-Type")
+	text := strings.HasPrefix(strings.ToLower(contentType), ContentTypeGRPCWebText)
+	info, ok := h.mapping.Get(service, method)
+	if !ok || (info.method == nil && info.stream == nil) || info.clientStreams {
+		// gRPC-Web has no support for client streaming, clients read the status of the call from the trailers
+		err = status.Error(codes.Unimplemented, "method not supported over gRPC-Web: "+req.URL.Path)
+		stream = &grpcWebStream{ctx: ctx, resp: resp, text: text, contentType: contentType, method: req.URL.Path}
+		stream.finish(err)
+		return
+	}
+
+	//setup context
+	ctx = prepareContext(req.WithContext(ctx), info)
+	ctx = processOptions(ctx, req, info)
+	ctx = loggers.AddToLogContext(ctx, "transport", "grpc-web")
+	ctx = grpcWebMetadata(ctx, req)
+
+	maxSize := h.maxBodySize(info)
+	if err = limitRequest(req, maxSize); err != nil {
+		writeResp(resp, http.StatusRequestEntityTooLarge, []byte("Request Entity Too Large!"))
+		return
+	}
+
+	var body io.Reader = req.Body
+	if text {
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	stream = &grpcWebStream{
+		ctx:         ctx,
+		resp:        resp,
+		text:        text,
+		contentType: contentType,
+		method:      generateProtoURL(info.serviceName, info.methodName),
+	}
+	// every frame is flushed, calls are compressed regardless of the minimum size
+	if cw := h.compressWriter(resp, req, info); cw != nil {
+		stream.resp, stream.compressor = cw, cw
+	}
+	if flusher, ok := stream.resp.(http.Flusher); ok {
+		stream.flusher = flusher
+	}
+	// populate the request message, it should be the first frame
+	stream.request, err = readGRPCWebFrame(bufio.NewReader(body), maxSize)
+	if err == nil {
+		if info.method != nil {
+			err = h.grpcWebUnary(ctx, info, stream)
+		} else {
+			err = h.serveStream(info, stream)
+		}
+	}
+	stream.finish(err)
+}
+
+func (h *httpHandler) grpcWebUnary(ctx context.Context, info *methodInfo, stream *grpcWebStream) error {
+	// allows grpc.SetHeader/SetTrailer to be used by services
+	ctx = grpc.NewContextWithServerTransportStream(ctx, &grpcWebTransportStream{stream})
+	stream.ctx = ctx
+	dec := func(r interface{}) error {
+		return stream.decode(r)
+	}
+	resp, err := info.method(info.svc.svc, ctx, dec, h.getInterceptors(info))
+	if err != nil {
+		return err
+	}
+	return stream.SendMsg(resp)
+}
+
+// readGRPCWebFrame reads a single length prefixed message no larger than maxSize
+func readGRPCWebFrame(r io.Reader, maxSize int64) ([]byte, error) {
+	prefix := make([]byte, 5)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		if err == io.EOF {
+			return nil, status.Error(codes.InvalidArgument, "missing request message")
+		}
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if prefix[0]&0x01 != 0 {
+		return nil, status.Error(codes.Unimplemented, "compressed messages are not supported")
+	}
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if maxSize > 0 && int64(length) > maxSize {
+		return nil, status.Error(codes.ResourceExhausted, ErrRequestTooLarge.Error())
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if isRequestTooLarge(err) {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// drain anything after the request message so the connection can be reused
+	io.Copy(ioutil.Discard, r)
+	return data, nil
+}
+
+// grpcWebMetadata populates incoming gRPC metadata from the request headers, gRPC-Web clients send metadata as
+// plain headers, 'Grpc-Metadata-' headers are added by prefixedMetadata
+func grpcWebMetadata(ctx context.Context, req *http.Request) context.Context {
+	md := metautils.ExtractIncoming(ctx)
+	for key, values := range req.Header {
+		key = strings.ToLower(key)
+		if grpcWebSkipHeaders[key] || strings.HasPrefix(key, strings.ToLower(GRPCMetadataHeaderPrefix)) {
+			continue
+		}
+		for _, value := range values {
+			md.Add(key, value)
+		}
+	}
+	return md.ToIncoming(ctx)
+}
+
+// grpcWebStream implements grpc.ServerStream for gRPC-Web requests
+type grpcWebStream struct {
+	ctx         context.Context
+	resp        http.ResponseWriter
+	flusher     http.Flusher
+	compressor  *compressWriter
+	text        bool
+	contentType string
+	method      string
+	request     []byte
+	received    bool
+	headersSent bool
+	finished    bool
+	header      metadata.MD
+	trailer     metadata.MD
+}
+
+func (s *grpcWebStream) decode(m interface{}) error {
+	if protoMsg, ok := m.(proto.Message); ok {
+		if err := proto.Unmarshal(s.request, protoMsg); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil
+	}
+	return status.Error(codes.Internal, "request is not a proto message")
+}
+
+func (s *grpcWebStream) SetHeader(md metadata.MD) error {
+	if s.headersSent {
+		return errors.New("headers already sent")
+	}
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *grpcWebStream) SendHeader(md metadata.MD) error {
+	if err := s.SetHeader(md); err != nil {
+		return err
+	}
+	s.writeHeader()
+	return nil
+}
+
+func (s *grpcWebStream) SetTrailer(md metadata.MD) {
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+func (s *grpcWebStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *grpcWebStream) writeHeader() {
+	if s.headersSent {
+		return
+	}
+	s.headersSent = true
+	exposed := make([]string, 0)
+	for key, values := range s.header {
+		for _, value := range values {
+			s.resp.Header().Add(key, value)
+		}
+		exposed = append(exposed, key)
+	}
+	if len(exposed) > 0 && s.resp.Header().Get("Access-Control-Expose-Headers") != "" {
+		// header metadata is only readable by browsers from other origins when it is exposed
+		sort.Strings(exposed)
+		s.resp.Header().Set("Access-Control-Expose-Headers", grpcWebExposedHeaders+", "+strings.Join(exposed, ", "))
+	}
+	s.resp.Header().Set("Content-Type", s.contentType)
+	s.resp.WriteHeader(http.StatusOK)
+}
+
+func (s *grpcWebStream) writeFrame(flag byte, data []byte) error {
+	frame := make([]byte, 5+len(data))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(data)))
+	copy(frame[5:], data)
+	if s.text {
+		// every frame is encoded on its own so that it can be flushed
+		frame = []byte(base64.StdEncoding.EncodeToString(frame))
+	}
+	if _, err := s.resp.Write(frame); err != nil {
+		return err
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return nil
+}
+
+func (s *grpcWebStream) SendMsg(m interface{}) error {
+	protoMsg, ok := m.(proto.Message)
+	if !ok {
+		return status.Error(codes.Internal, "response is not a proto message")
+	}
+	data, err := proto.Marshal(protoMsg)
+	if err != nil {
+		return err
+	}
+	s.writeHeader()
+	return s.writeFrame(frameMessage, data)
+}
+
+func (s *grpcWebStream) RecvMsg(m interface{}) error {
+	// gRPC-Web only supports a single request message
+	if s.received {
+		return io.EOF
+	}
+	s.received = true
+	return s.decode(m)
+}
+
+// finish writes the trailer frame carrying the status of the call
+func (s *grpcWebStream) finish(err error) {
+	s.finished = true
+	if s.compressor != nil {
+		// the compressed response ends after the trailers
+		defer s.compressor.Close()
+	}
+	s.writeHeader()
+	st := status.Convert(err)
+	if entry := accesslog.FromContext(s.ctx); entry != nil {
+		entry.Code = st.Code().String()
+	}
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "grpc-status: %d\r\n", st.Code())
+	if st.Message() != "" {
+		fmt.Fprintf(buf, "grpc-message: %s\r\n", encodeGrpcMessage(st.Message()))
+	}
+	for key, values := range s.trailer {
+		for _, value := range values {
+			fmt.Fprintf(buf, "%s: %s\r\n", strings.ToLower(key), value)
+		}
+	}
+	if e := s.writeFrame(frameTrailer, buf.Bytes()); e != nil {
+		log.Debug(s.ctx, "grpc-web", "could not write trailers", "error", e)
+	}
+}
+
+// encodeGrpcMessage percent encodes the status message as required by the gRPC spec
+func encodeGrpcMessage(msg string) string {
+	buf := new(bytes.Buffer)
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
+
+// grpcWebTransportStream allows services to set headers and trailers for unary calls using grpc.SetHeader/SetTrailer
+type grpcWebTransportStream struct {
+	stream *grpcWebStream
+}
+
+func (t *grpcWebTransportStream) Method() string {
+	return t.stream.method
+}
+
+func (t *grpcWebTransportStream) SetHeader(md metadata.MD) error {
+	return t.stream.SetHeader(md)
+}
+
+func (t *grpcWebTransportStream) SendHeader(md metadata.MD) error {
+	return t.stream.SendHeader(md)
+}
+
+func (t *grpcWebTransportStream) SetTrailer(md metadata.MD) error {
+	t.stream.SetTrailer(md)
+	return nil
+}
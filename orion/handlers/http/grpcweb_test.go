@@ -0,0 +1,151 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/stretchr/testify/assert"
+)
+
+func grpcWebRequest(t *testing.T, url, contentType, value string, hdrs map[string]string) *http.Request {
+	data, err := proto.Marshal(&wrappers.StringValue{Value: value})
+	assert.NoError(t, err)
+	frame := make([]byte, 5+len(data))
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(data)))
+	copy(frame[5:], data)
+	if contentType == ContentTypeGRPCWebText {
+		frame = []byte(base64.StdEncoding.EncodeToString(frame))
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(frame))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range hdrs {
+		req.Header.Set(key, value)
+	}
+	return req
+}
+
+// readGRPCWebResponse returns the messages and the trailers of a gRPC-Web response
+func readGRPCWebResponse(t *testing.T, resp *http.Response) ([]string, string) {
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), ContentTypeGRPCWebText) {
+		// frames are encoded on their own, groups of 4 characters can be decoded independently
+		decoded := new(bytes.Buffer)
+		for i := 0; i+4 <= len(body); i += 4 {
+			group, err := base64.StdEncoding.DecodeString(string(body[i : i+4]))
+			assert.NoError(t, err)
+			decoded.Write(group)
+		}
+		body = decoded.Bytes()
+	}
+	messages := make([]string, 0)
+	trailer := ""
+	for len(body) >= 5 {
+		length := binary.BigEndian.Uint32(body[1:5])
+		data := body[5 : 5+length]
+		if body[0]&frameTrailer != 0 {
+			trailer = string(data)
+		} else {
+			msg := new(wrappers.StringValue)
+			assert.NoError(t, proto.Unmarshal(data, msg))
+			messages = append(messages, msg.GetValue())
+		}
+		body = body[5+length:]
+	}
+	return messages, trailer
+}
+
+func TestGRPCWeb(t *testing.T) {
+	base := startTestHandler(t, Config{EnableGRPCWeb: true}, nil)
+	tests := []struct {
+		method   string
+		value    string
+		messages []string
+		status   string
+	}{
+		{"Upper", "hello", []string{"HELLO"}, "grpc-status: 0\r\n"},
+		{"Upper", "fail", []string{}, "grpc-status: 3\r\ngrpc-message: cannot upper fail\r\n"},
+		{"Upper", "panic", []string{}, "grpc-status: 13\r\ngrpc-message: Internal Server Error!\r\n"},
+		{"Split", "hello big world", []string{"hello", "big", "world"}, "grpc-status: 0\r\nx-words: 3\r\n"},
+		{"Split", "hello fail", []string{"hello"}, "grpc-status: 10\r\ngrpc-message: cannot split fail\r\n"},
+		{"Split", "hello panic", []string{"hello"}, "grpc-status: 13\r\ngrpc-message: Internal Server Error!\r\n"},
+		{"Missing", "hello", []string{}, "grpc-status: 12\r\ngrpc-message: method not supported over gRPC-Web: /test.TestService/Missing\r\n"},
+	}
+	for _, contentType := range []string{ContentTypeGRPCWeb, ContentTypeGRPCWebText} {
+		for _, test := range tests {
+			name := contentType + " " + test.method + " " + test.value
+			req := grpcWebRequest(t, base+"/test.TestService/"+test.method, contentType, test.value, nil)
+			resp, err := http.DefaultClient.Do(req)
+			if !assert.NoError(t, err, name) {
+				continue
+			}
+			assert.Equal(t, http.StatusOK, resp.StatusCode, name)
+			assert.Equal(t, contentType, resp.Header.Get("Content-Type"), name)
+			messages, trailer := readGRPCWebResponse(t, resp)
+			resp.Body.Close()
+			assert.Equal(t, test.messages, messages, name)
+			assert.Equal(t, test.status, trailer, name)
+		}
+	}
+}
+
+func TestGRPCWebCORS(t *testing.T) {
+	base := startTestHandler(t, Config{EnableGRPCWeb: true, GRPCWebAllowedOrigins: []string{"*.example.com"}}, nil)
+	url := base + "/test.TestService/Upper"
+
+	preflight := func(origin string) *http.Response {
+		req, _ := http.NewRequest(http.MethodOptions, url, nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web")
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	resp := preflight("https://app.example.com")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "https://app.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "POST, OPTIONS", resp.Header.Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "content-type,x-grpc-web", resp.Header.Get("Access-Control-Allow-Headers"))
+
+	resp = preflight("https://evil.com")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+
+	for _, contentType := range []string{ContentTypeGRPCWeb, ContentTypeGRPCWebText} {
+		req := grpcWebRequest(t, url, contentType, "hello", map[string]string{"Origin": "https://app.example.com"})
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		resp.Body.Close()
+		assert.Equal(t, "https://app.example.com", resp.Header.Get("Access-Control-Allow-Origin"), contentType)
+		assert.Equal(t, "grpc-status, grpc-message, x-upper", resp.Header.Get("Access-Control-Expose-Headers"), contentType)
+		assert.Equal(t, "1", resp.Header.Get("X-Upper"), contentType)
+	}
+
+	// requests from the server's own origin do not need to be configured
+	req := grpcWebRequest(t, url, ContentTypeGRPCWeb, "hello", map[string]string{"Origin": base})
+	resp, err := http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, base, resp.Header.Get("Access-Control-Allow-Origin"))
+	}
+
+	// origins that are not allowed get no CORS headers
+	req = grpcWebRequest(t, url, ContentTypeGRPCWeb, "hello", map[string]string{"Origin": "https://evil.com"})
+	resp, err = http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+	}
+}
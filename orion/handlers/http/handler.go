@@ -9,7 +9,6 @@ import (
 	"time"
 
 	"github.com/go-orion/Orion/orion/handlers"
-	"github.com/go-orion/Orion/utils/log"
 	"github.com/gorilla/mux"
 	"google.golang.org/grpc"
 )
@@ -47,41 +46,53 @@ func (h *httpHandler) Add(sd *grpc.ServiceDesc, ss interface{}) error {
 			methodName:  m.MethodName,
 			urls:        make([]string, 0),
 		}
-		info.urls = append(info.urls, generateURL(sd.ServiceName, m.MethodName))
-		if h.config.EnableProtoURL {
-			// add proto urls if enabled
-			info.urls = append(info.urls, generateProtoURL(sd.ServiceName, m.MethodName))
-		}
+		h.addURLs(info)
 		h.mapping.Add(info.serviceName, info.methodName, info)
 	}
 	for _, s := range sd.Streams {
+		httpMethod := []string{"GET"}
+		if !s.ClientStreams {
+			// server streams can also be served over chunked HTTP
+			httpMethod = append(httpMethod, "POST")
+		}
 		info := &methodInfo{
 			stream:        s.Handler,
 			svc:           svcInfo,
-			httpMethod:    []string{"GET"},
+			httpMethod:    httpMethod,
 			serviceName:   sd.ServiceName,
 			methodName:    s.StreamName,
 			urls:          make([]string, 0),
 			clientStreams: s.ClientStreams,
 			serverStreams: s.ServerStreams,
 		}
-		info.urls = append(info.urls, generateURL(sd.ServiceName, s.StreamName))
-		if h.config.EnableProtoURL {
-			// add proto urls if enabled
-			info.urls = append(info.urls, generateProtoURL(sd.ServiceName, s.StreamName))
-		}
+		h.addURLs(info)
 		h.mapping.Add(info.serviceName, info.methodName, info)
 
 	}
 	return nil
 }
 
+// addURLs adds the generated urls of a method, the first url is the canonical one
+func (h *httpHandler) addURLs(info *methodInfo) {
+	url := generateURL(info.serviceName, info.methodName)
+	if versioned := generateVersionedURL(info.serviceName, info.methodName); h.config.VersionPrefix && versioned != "" {
+		// the unversioned url is still served for existing clients
+		info.aliases = map[string]string{url: versioned}
+		url = versioned
+	}
+	info.urls = append(info.urls, url)
+	if h.config.EnableProtoURL {
+		// add proto urls if enabled
+		info.urls = append(info.urls, generateProtoURL(info.serviceName, info.methodName))
+	}
+}
+
 func (h *httpHandler) AddEncoder(serviceName, method string, httpMethod []string, path string, encoder handlers.Encoder) {
 	if h.mapping != nil {
 		if info, ok := h.mapping.Get(serviceName, method); ok {
 			info.encoder = encoder
 			info.httpMethod = httpMethod
-			url := generateURL(serviceName, method)
+			url := info.urls[0]
 			if strings.TrimSpace(path) != "" {
 				info.encoderPath = path
 				info.urls = append(info.urls, path)
@@ -89,8 +100,7 @@ func (h *httpHandler) AddEncoder(serviceName, method string, httpMethod []string
 				info.encoderPath = url
 			}
 		} else {
-			log.Warn(context.Background(), "error", "Service and Method NOT found!", "service", serviceName,
-				"method", method, "mapping", h.mapping)
+			h.notFound("encoder", serviceName, method)
 		}
 	}
 }
@@ -109,8 +119,7 @@ func (h *httpHandler) AddHTTPHandler(serviceName string, method string, path str
 		if info, ok := h.mapping.Get(serviceName, method); ok {
 			info.httpHandler = handler
 		} else {
-			log.Warn(context.Background(), "error", "Service and Method NOT found!", "service", serviceName,
-				"method", method, "mapping", h.mapping)
+			h.notFound("http handler", serviceName, method)
 		}
 	}
 }
@@ -120,8 +129,7 @@ func (h *httpHandler) AddDecoder(serviceName, method string, decoder handlers.De
 		if info, ok := h.mapping.Get(serviceName, method); ok {
 			info.decoder = decoder
 		} else {
-			log.Warn(context.Background(), "error", "Service and Method NOT found!", "service", serviceName,
-				"method", method, "mapping", h.mapping)
+			h.notFound("decoder", serviceName, method)
 		}
 	}
 }
@@ -135,14 +143,61 @@ func (h *httpHandler) AddDefaultDecoder(serviceName string, decoder handlers.Dec
 	}
 }
 
+func (h *httpHandler) AddWSConfig(serviceName, method string, config handlers.WSConfig) {
+	if h.mapping != nil {
+		if info, ok := h.mapping.Get(serviceName, method); ok {
+			info.wsConfig = &config
+		} else {
+			h.notFound("websocket config", serviceName, method)
+		}
+	}
+}
+
+func (h *httpHandler) AddDefaultWSConfig(serviceName string, config handlers.WSConfig) {
+	if h.defWSConfigs == nil {
+		h.defWSConfigs = make(map[string]handlers.WSConfig)
+	}
+	h.defWSConfigs[cleanSvcName(serviceName)] = config
+}
+
+func (h *httpHandler) AddWSUpgrader(serviceName, method string, upgrader handlers.WSUpgrader) {
+	if h.mapping != nil {
+		if info, ok := h.mapping.Get(serviceName, method); ok {
+			info.wsUpgrader = upgrader
+		} else {
+			h.notFound("websocket upgrader", serviceName, method)
+		}
+	}
+}
+
+func (h *httpHandler) AddDefaultWSUpgrader(serviceName string, upgrader handlers.WSUpgrader) {
+	if h.defWSUpgraders == nil {
+		h.defWSUpgraders = make(map[string]handlers.WSUpgrader)
+	}
+	if upgrader != nil {
+		h.defWSUpgraders[cleanSvcName(serviceName)] = upgrader
+	}
+}
+
+func (h *httpHandler) AddFileSink(serviceName, method string, sink handlers.FileSink) {
+	if h.mapping != nil {
+		if info, ok := h.mapping.Get(serviceName, method); ok {
+			info.fileSink = sink
+		} else {
+			h.notFound("file sink", serviceName, method)
+		}
+	}
+}
+
 func (h *httpHandler) AddOption(serviceName, method, option string) {
 	if info, ok := h.mapping.Get(serviceName, method); ok {
 		if info.options == nil {
 			info.options = make([]string, 0)
 		}
 		info.options = append(info.options, option)
+	} else {
+		h.notFound("option "+option, serviceName, method)
 	}
-
 }
 
 func (h *httpHandler) AddMiddleware(serviceName string, method string, middlewares ...string) {
@@ -156,45 +211,64 @@ func (h *httpHandler) Run(httpListener net.Listener) error {
 	r := mux.NewRouter()
 	fmt.Println("Mapped URLs: ")
 	allPaths := h.mapping.GetAllMethodInfoByOrder()
-	for i := range allPaths {
-		info := allPaths[i]
-		for _, url := range info.urls {
-			if strings.TrimSpace(info.encoderPath) != "" {
-				if info.encoderPath != url {
-					// only add the encoder url if encoder is defined, skip others
-					continue
-				}
+	if h.config.EnableGRPCWeb {
+		// gRPC-Web routes are matched first as they share the proto urls
+		for i := range allPaths {
+			info := allPaths[i]
+			if info.clientStreams {
+				continue
 			}
-			routeURL := url
-			var handler http.HandlerFunc
-			methodClassifier := make([]string, 0)
-			if info.clientStreams || info.serverStreams {
-				handler = h.getWSHandler(info.serviceName, info.methodName)
-				if info.clientStreams {
-					methodClassifier = append(methodClassifier, "CLIENT_STREAMING")
-				}
-				if info.serverStreams {
-					methodClassifier = append(methodClassifier, "SERVER_STREAMING")
-				}
-			} else {
-				handler = h.getHTTPHandler(info.serviceName, info.methodName)
-				methodClassifier = append(methodClassifier, "NON_STREAMING")
+			url := generateProtoURL(info.serviceName, info.methodName)
+			r.Methods(http.MethodPost).Path(url).MatcherFunc(isGRPCWebRequest).Handler(withRouteTimeouts(info, h.getGRPCWebHandler(info.serviceName, info.methodName)))
+			r.Methods(http.MethodOptions).Path(url).MatcherFunc(isPreflightRequest).HandlerFunc(h.grpcWebPreflight)
+			fmt.Println("\t", []string{http.MethodPost, http.MethodOptions}, url, "mapped to", info.serviceName, info.methodName, []string{"GRPC_WEB"})
+		}
+	}
+	routes := h.routes()
+	for _, rt := range routes {
+		info, url := rt.info, rt.path
+		routeURL := url
+		var handler http.HandlerFunc
+		methodClassifier := make([]string, 0)
+		if info.clientStreams || info.serverStreams {
+			handler = h.getStreamHandler(info.serviceName, info.methodName)
+			if info.clientStreams {
+				methodClassifier = append(methodClassifier, "CLIENT_STREAMING")
 			}
-			r.Methods(info.httpMethod...).Path(url).Handler(handler)
-			if !strings.HasSuffix(url, "/") {
-				routeURL = url + "/"
-				r.Methods(info.httpMethod...).Path(url + "/").Handler(handler)
+			if info.serverStreams {
+				methodClassifier = append(methodClassifier, "SERVER_STREAMING")
 			}
-			fmt.Println("\t", info.httpMethod, routeURL, "mapped to", info.serviceName, info.methodName, methodClassifier)
+		} else {
+			handler = h.getHTTPHandler(info.serviceName, info.methodName)
+			methodClassifier = append(methodClassifier, "NON_STREAMING")
+		}
+		handler = withDeprecation(rt, withRouteTimeouts(info, handler))
+		r.Methods(info.httpMethod...).Path(url).Handler(handler)
+		if !strings.HasSuffix(url, "/") && !h.config.RedirectTrailingSlash {
+			routeURL = url + "/"
+			r.Methods(info.httpMethod...).Path(url + "/").Handler(handler)
+		}
+		if rt.deprecated {
+			methodClassifier = append(methodClassifier, "DEPRECATED")
 		}
+		fmt.Println("\t", info.httpMethod, routeURL, "mapped to", info.serviceName, info.methodName, methodClassifier)
 	}
-	r.NotFoundHandler = &notFoundHandler{}
-	h.svr = &http.Server{
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		Handler:      r,
+	// mounts are matched after the RPC routes
+	for _, m := range h.sortedMounts() {
+		r.MatcherFunc(m.match).Handler(h.getMountHandler(m))
+		fmt.Println("\t", m.prefix+"*", "mounted")
 	}
-	return h.svr.Serve(httpListener)
+	if h.config.EnableGRPCWeb {
+		// gRPC-Web clients read the status of calls to methods that are not served from the trailers
+		r.Methods(http.MethodPost).MatcherFunc(isGRPCWebRequest).HandlerFunc(h.grpcWebUnknownHandler)
+	}
+	r.NotFoundHandler = accessLogMiddleware(&notFoundHandler{})
+	r.Use(accessLogMiddleware)
+	svr := h.newServer(h.routingPolicy(r, routes))
+	h.mu.Lock()
+	h.svr = svr
+	h.mu.Unlock()
+	return svr.Serve(httpListener)
 }
 
 func (h *httpHandler) Stop(timeout time.Duration) error {
@@ -203,5 +277,6 @@ func (h *httpHandler) Stop(timeout time.Duration) error {
 	ctx, can := context.WithTimeout(context.Background(), timeout)
 	defer can()
 	h.svr.Shutdown(ctx)
+	h.dangling = nil
 	return nil
 }
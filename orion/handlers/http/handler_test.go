@@ -0,0 +1,127 @@
+package http
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// testService is the service served by the handlers under test, it adds its interceptors to those of the handler
+type testService struct {
+	interceptors       []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
+}
+
+func (s testService) GetInterceptors() []grpc.UnaryServerInterceptor {
+	return s.interceptors
+}
+
+func (s testService) GetStreamInterceptors() []grpc.StreamServerInterceptor {
+	return s.streamInterceptors
+}
+
+func (testService) Upper(ctx context.Context, req *wrappers.StringValue) (*wrappers.StringValue, error) {
+	switch req.GetValue() {
+	case "panic":
+		panic("upper panicked")
+	case "fail":
+		return nil, status.Error(codes.InvalidArgument, "cannot upper fail")
+	}
+	grpc.SetHeader(ctx, map[string][]string{"x-upper": {"1"}})
+	return &wrappers.StringValue{Value: strings.ToUpper(req.GetValue())}, nil
+}
+
+func (testService) Split(req *wrappers.StringValue, stream grpc.ServerStream) error {
+	stream.SetHeader(metadata.Pairs("x-split", "1"))
+	words := strings.Fields(req.GetValue())
+	for _, word := range words {
+		if word == "fail" {
+			return status.Error(codes.Aborted, "cannot split fail")
+		}
+		if word == "panic" {
+			panic("split panicked")
+		}
+		if err := stream.SendMsg(&wrappers.StringValue{Value: word}); err != nil {
+			return err
+		}
+	}
+	stream.SetTrailer(metadata.Pairs("x-words", strconv.Itoa(len(words))))
+	return nil
+}
+
+var testServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.TestService",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Upper",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(wrappers.StringValue)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(testService).Upper(ctx, req.(*wrappers.StringValue))
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/test.TestService/Upper"}, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Split",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				in := new(wrappers.StringValue)
+				if err := stream.RecvMsg(in); err != nil {
+					return err
+				}
+				return srv.(testService).Split(in, stream)
+			},
+			ServerStreams: true,
+		},
+	},
+}
+
+// startTestHandler serves testService with an HTTP handler, setup can register encoders and options before serving,
+// it returns the base url of the server
+func startTestHandler(t *testing.T, config Config, setup func(h *httpHandler)) string {
+	return startTestServiceHandler(t, config, testService{}, setup)
+}
+
+// startTestServiceHandler is startTestHandler serving svc
+func startTestServiceHandler(t *testing.T, config Config, svc testService, setup func(h *httpHandler)) string {
+	config.NoDefaultInterceptors = true
+	h := NewHTTPHandler(config).(*httpHandler)
+	if err := h.Add(&testServiceDesc, svc); err != nil {
+		t.Fatal(err)
+	}
+	if setup != nil {
+		setup(h)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Run(lis)
+	}()
+	t.Cleanup(func() {
+		h.Stop(0)
+		<-done
+	})
+	// requests wait in the listener backlog until the router is built
+	return "http://" + lis.Addr().String()
+}
@@ -2,16 +2,19 @@ package http
 
 import (
 	"context"
-	"encoding/json"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-orion/Orion/orion/handlers"
 	"github.com/go-orion/Orion/orion/modifiers"
 	"github.com/go-orion/Orion/utils"
+	"github.com/go-orion/Orion/utils/accesslog"
 	"github.com/go-orion/Orion/utils/errors"
 	"github.com/go-orion/Orion/utils/errors/notifier"
 	"github.com/go-orion/Orion/utils/headers"
@@ -21,6 +24,9 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/grpc-ecosystem/go-grpc-middleware/util/metautils"
 	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 func (h *httpHandler) getHTTPHandler(serviceName, methodName string) http.HandlerFunc {
@@ -30,13 +36,25 @@ func (h *httpHandler) getHTTPHandler(serviceName, methodName string) http.Handle
 }
 
 func (h *httpHandler) httpHandler(resp http.ResponseWriter, req *http.Request, service, method string) {
+	serveInstrumented(resp, req, "http", func(resp http.ResponseWriter, req *http.Request) (context.Context, error) {
+		return h.serveHTTP(resp, req, service, method)
+	}, nil)
+}
+
+// serveInstrumented serves a request with newrelic tracing, logging, error reporting and panic recovery, recovered
+// writes the response of a panic and defaults to a plain 500 when nil
+func serveInstrumented(resp http.ResponseWriter, req *http.Request, transport string, serve func(http.ResponseWriter, *http.Request) (context.Context, error), recovered func(http.ResponseWriter)) {
 	ctx := utils.StartNRTransaction(req.URL.Path, req.Context(), req, resp)
-	ctx = loggers.AddToLogContext(ctx, "transport", "http")
+	ctx = loggers.AddToLogContext(ctx, "transport", transport)
 	var err error
 	defer func(resp http.ResponseWriter, ctx context.Context, t time.Time) {
 		// panic handler
 		if r := recover(); r != nil {
-			writeResp(resp, http.StatusInternalServerError, []byte("Internal Server Error!"))
+			if recovered != nil {
+				recovered(resp)
+			} else {
+				writeResp(resp, http.StatusInternalServerError, []byte("Internal Server Error!"))
+			}
 			log.Error(ctx, "panic", r, "path", req.URL.String(), "method", req.Method, "took", time.Since(t))
 			log.Error(ctx, string(debug.Stack()))
 			var err error
@@ -47,12 +65,19 @@ func (h *httpHandler) httpHandler(resp http.ResponseWriter, req *http.Request, s
 			}
 			utils.FinishNRTransaction(ctx, err)
 			notifier.NotifyWithLevel(err, "critical", req.URL.String(), ctx)
+			if entry := accesslog.FromContext(ctx); entry != nil {
+				entry.Error = err
+			}
+		} else if entry := accesslog.FromContext(ctx); entry != nil {
+			// requests are recorded by the access log
+			entry.Error = err
+			log.Debug(ctx, "path", req.URL.Path, "method", req.Method, "error", err, "took", time.Since(t))
 		} else {
 			log.Info(ctx, "path", req.URL.Path, "method", req.Method, "error", err, "took", time.Since(t))
 		}
 	}(resp, ctx, time.Now())
 	req = req.WithContext(ctx)
-	ctx, err = h.serveHTTP(resp, req, service, method)
+	ctx, err = serve(resp, req)
 	if modifiers.HasDontLogError(ctx) {
 		utils.FinishNRTransaction(req.Context(), nil)
 	} else {
@@ -88,6 +113,12 @@ func prepareContext(req *http.Request, info *methodInfo) context.Context {
 	// populate options
 	ctx = options.AddToOptions(ctx, modifiers.RequestHTTP, true)
 
+	if info.fileSink != nil {
+		ctx = context.WithValue(ctx, fileSinkKey{}, info.fileSink)
+	}
+
+	ctx = prefixedMetadata(ctx, req)
+
 	// translate from http zipkin context to gRPC
 	wireContext, err := opentracing.GlobalTracer().Extract(
 		opentracing.HTTPHeaders,
@@ -100,24 +131,92 @@ func prepareContext(req *http.Request, info *methodInfo) context.Context {
 	return ctx
 }
 
+// prefixedMetadata populates incoming gRPC metadata from the request headers starting with GRPCMetadataHeaderPrefix
+func prefixedMetadata(ctx context.Context, req *http.Request) context.Context {
+	var md metadata.MD
+	for key, values := range req.Header {
+		if len(key) <= len(GRPCMetadataHeaderPrefix) || !strings.EqualFold(key[:len(GRPCMetadataHeaderPrefix)], GRPCMetadataHeaderPrefix) {
+			continue
+		}
+		if md == nil {
+			incoming, _ := metadata.FromIncomingContext(ctx)
+			md = incoming.Copy()
+		}
+		key = strings.ToLower(key[len(GRPCMetadataHeaderPrefix):])
+		for _, value := range values {
+			if strings.HasSuffix(key, "-bin") {
+				// binary values are base64 encoded in headers
+				if data, err := base64.StdEncoding.DecodeString(value); err == nil {
+					value = string(data)
+				}
+			}
+			md[key] = append(md[key], value)
+		}
+	}
+	if md == nil {
+		return ctx
+	}
+	return metadata.NewIncomingContext(ctx, md)
+}
+
 func processOptions(ctx context.Context, req *http.Request, info *methodInfo) context.Context {
 	if info.options != nil {
+		codec := ""
+		overrides := codecOverrides{}
 		for _, opt := range info.options {
-			switch strings.ToUpper(opt) {
+			opt = strings.ToUpper(strings.TrimSpace(opt))
+			name, value := opt, ""
+			if i := strings.Index(opt, "="); i >= 0 {
+				name, value = opt[:i], opt[i+1:]
+			}
+			switch name {
 			case IgnoreNR:
 				utils.IgnoreNRTransaction(ctx)
+			case EmitDefaults:
+				overrides.emitDefaults = boolOption(value)
+			case OrigName:
+				overrides.origName = boolOption(value)
+			case EnumsAsInts:
+				overrides.enumsAsInts = boolOption(value)
+			case CodecOption:
+				codec = value
+			}
+		}
+		if overrides != (codecOverrides{}) {
+			// the codec options are only supported by jsonpb, they select it unless the method sets a codec
+			if codec == "" {
+				codec = modifiers.JSONPB
 			}
+			ctx = options.AddToOptions(ctx, codecOptionsOption, overrides)
+		}
+		if codec != "" {
+			ctx = options.AddToOptions(ctx, methodCodecOption, codec)
 		}
 	}
 	return ctx
 }
 
+// boolOption parses the value of a flag option, a flag without value is true and invalid values are ignored
+func boolOption(value string) *bool {
+	if value == "" {
+		value = "true"
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil
+	}
+	return &b
+}
+
 func (h *httpHandler) serveHTTP(resp http.ResponseWriter, req *http.Request, serviceName, methodName string) (context.Context, error) {
 	info, ok := h.mapping.Get(serviceName, methodName)
 	if ok {
 		ctx := prepareContext(req, info)
 		ctx = processOptions(ctx, req, info)
 		req = req.WithContext(ctx)
+		if err := h.prepareBody(resp, req, info); err != nil {
+			return ctx, errors.Wrap(err, "Bad Request")
+		}
 		// httpHandler allows handling entire http request
 		if info.httpHandler != nil {
 			if info.httpHandler(resp, req) {
@@ -125,31 +224,31 @@ func (h *httpHandler) serveHTTP(resp http.ResponseWriter, req *http.Request, ser
 				return ctx, nil
 			}
 		}
+		// responses written by httpHandler are not compressed, it gets the original writer so that it can flush or hijack
+		if cw := h.compressWriter(resp, req, info); cw != nil {
+			defer cw.Close()
+			resp = cw
+		}
+
+		// fail early when the response can not be serialized in any of the accepted types
+		if info.decoder == nil && h.defDecoders[cleanSvcName(info.svc.desc.ServiceName)] == nil {
+			if _, _, err := negotiate(ctx); err != nil {
+				writeResp(resp, http.StatusNotAcceptable, []byte("Not Acceptable!"))
+				return ctx, errors.Wrap(err, "Not Acceptable")
+			}
+		}
 
 		// decoder func
 		var encErr error
 		dec := func(r interface{}) error {
-			if info.encoder != nil {
-				encErr = info.encoder(req, r)
-			} else if enc, ok := h.defEncoders[cleanSvcName(info.svc.desc.ServiceName)]; ok {
-				// check for default encoder and invoke it
-				encErr = enc(req, r)
-			} else {
-				encErr = DefaultEncoder(req, r)
-			}
+			encErr = h.encode(req, info, r)
 			return encErr
 		}
 
-		// fetch all method middlewares
-		middlewares := make([]string, 0)
-		if h.middlewares != nil {
-			middlewares = append(middlewares, h.middlewares.GetMiddlewares(info.serviceName, info.methodName)...)
-		}
-		// fetch all interceptors
-		interceptors := handlers.GetInterceptorsWithMethodMiddlewares(info.svc.svc, h.config.CommonConfig, middlewares)
-
 		// make service call
-		protoResponse, err := info.method(info.svc.svc, ctx, dec, interceptors)
+		stream := &httpTransportStream{method: "/" + info.svc.desc.ServiceName + "/" + info.methodName}
+		protoResponse, err := info.method(info.svc.svc, grpc.NewContextWithServerTransportStream(ctx, stream), dec, h.getInterceptors(info))
+		stream.writeMetadata(resp)
 
 		//apply decoder if any
 		if info.decoder != nil {
@@ -170,56 +269,201 @@ func (h *httpHandler) serveHTTP(resp http.ResponseWriter, req *http.Request, ser
 		responseHeaders := processWhitelist(ctx, hdr, append(info.svc.responseHeaders, DefaultHTTPResponseHeaders...))
 		if err != nil {
 			if encErr != nil {
-				writeRespWithHeaders(resp, http.StatusBadRequest, []byte("Bad Request!"), responseHeaders)
-				return ctx, errors.Wrap(encErr, "Bad Request")
+				code, msg := encodeErrorToHTTP(encErr)
+				writeRespWithHeaders(resp, code, []byte(msg), responseHeaders)
+				return ctx, errors.Wrap(encErr, msg)
 			}
 			code, msg := GrpcErrorToHTTP(err, http.StatusInternalServerError, "Internal Server Error!")
+			// clients use the gRPC code as HTTP status codes are ambiguous
+			responseHeaders[GRPCStatusHeader] = []string{strconv.Itoa(int(status.Code(err)))}
 			writeRespWithHeaders(resp, code, []byte(msg), responseHeaders)
 			return ctx, errors.Wrap(err, msg)
 		}
-		return ctx, h.serializeOut(ctx, resp, protoResponse.(proto.Message), responseHeaders)
+		return ctx, h.serializeOut(ctx, resp, req, info, protoResponse.(proto.Message), responseHeaders)
 	}
 	writeResp(resp, http.StatusNotFound, []byte("Not Found: "+req.URL.String()))
 	return req.Context(), errors.New("Not Found: " + req.URL.String())
 }
 
-func (h *httpHandler) serialize(ctx context.Context, msg proto.Message) ([]byte, string, error) {
-	// first check if any serialization is
-	serType, _ := modifiers.GetSerialization(ctx)
-	if serType == "" {
-		// try and match an accept header
-		serType = AcceptTypeFromHeaders(ctx)
-		if serType == "" {
-			// try and match the original content type
-			serType = ContentTypeFromHeaders(ctx)
-			if serType == "" {
-				serType = modifiers.JSON
-			}
+// compressWriter returns a response writer compressing the response if compression is enabled and negotiated
+func (h *httpHandler) compressWriter(resp http.ResponseWriter, req *http.Request, info *methodInfo) *compressWriter {
+	if !h.config.EnableCompression {
+		return nil
+	}
+	for _, opt := range info.options {
+		if strings.ToUpper(strings.TrimSpace(opt)) == NoCompression {
+			return nil
+		}
+	}
+	// the response depends on Accept-Encoding even when it is not compressed
+	resp.Header().Add("Vary", "Accept-Encoding")
+	encoding := negotiateEncoding(req.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		return nil
+	}
+	return newCompressWriter(resp, encoding, h.config.CompressionMinSize)
+}
+
+// getInterceptors fetches all interceptors for a method including method middlewares
+func (h *httpHandler) getInterceptors(info *methodInfo) grpc.UnaryServerInterceptor {
+	h.buildChain(info, true)
+	return info.unary
+}
+
+// getStreamInterceptors fetches all stream interceptors for a method including method middlewares
+func (h *httpHandler) getStreamInterceptors(info *methodInfo) grpc.StreamServerInterceptor {
+	h.buildChain(info, true)
+	return info.streamChain
+}
+
+// buildChain composes the interceptors of a method once, the chain is rebuilt on reload as methods are added again,
+// errors are logged when report is set, otherwise they are only returned
+func (h *httpHandler) buildChain(info *methodInfo, report bool) error {
+	info.chain.Do(func() {
+		// fetch all method middlewares
+		middlewares := make([]string, 0)
+		if h.middlewares != nil {
+			middlewares = append(middlewares, h.middlewares.GetMiddlewares(info.serviceName, info.methodName)...)
+		}
+		if info.clientStreams || info.serverStreams {
+			info.streamChain, info.chainErr = handlers.BuildStreamInterceptors(info.svc.svc, h.config.CommonConfig, middlewares, info.options)
+		} else {
+			info.unary, info.chainErr = handlers.BuildInterceptors(info.svc.svc, h.config.CommonConfig, middlewares, info.options)
+		}
+		if info.chainErr != nil && report {
+			log.Error(context.Background(), "service", info.serviceName, "method", info.methodName, "error", info.chainErr, "middleware", "could not fetch middleware")
 		}
+	})
+	return info.chainErr
+}
+
+// serveStream runs the stream handler of a method through its stream interceptors
+func (h *httpHandler) serveStream(info *methodInfo, stream grpc.ServerStream) error {
+	streamInfo := &grpc.StreamServerInfo{
+		FullMethod:     generateProtoURL(info.serviceName, info.methodName),
+		IsClientStream: info.clientStreams,
+		IsServerStream: info.serverStreams,
+	}
+	return h.getStreamInterceptors(info)(info.svc.svc, stream, streamInfo, info.stream)
+}
+
+// encode populates the request object using the encoder registered for this method
+func (h *httpHandler) encode(req *http.Request, info *methodInfo, r interface{}) error {
+	if info.encoder != nil {
+		return info.encoder(req, r)
+	} else if enc, ok := h.defEncoders[cleanSvcName(info.svc.desc.ServiceName)]; ok {
+		// check for default encoder and invoke it
+		return enc(req, r)
+	}
+	return DefaultEncoder(req, r)
+}
+
+// serializationType finds the serialization type to be used for the response
+func serializationType(ctx context.Context) string {
+	serType, _, err := negotiate(ctx)
+	if err != nil {
+		return modifiers.JSON
 	}
-	switch serType {
-	case modifiers.JSONPB:
-		sData, err := h.mar.MarshalToString(msg)
-		return []byte(sData), ContentTypeJSON, err
-	case modifiers.ProtoBuf:
-		data, err := proto.Marshal(msg)
-		return data, ContentTypeProto, err
-	case modifiers.JSON:
-		fallthrough
-	default:
-		// modifiers.JSON goes in here
-		data, err := json.Marshal(msg)
-		return data, ContentTypeJSON, err
+	return serType
+}
+
+func (h *httpHandler) serialize(ctx context.Context, msg proto.Message) ([]byte, string, error) {
+	serType, contentType, err := negotiate(ctx)
+	if err != nil {
+		return nil, "", err
 	}
+	data, err := h.codec(ctx, serType).Marshal(msg)
+	return data, contentType, err
 }
 
-func (h *httpHandler) serializeOut(ctx context.Context, resp http.ResponseWriter, msg proto.Message, responseHeaders http.Header) error {
+func (h *httpHandler) serializeAs(ctx context.Context, serType string, msg proto.Message) ([]byte, string, error) {
+	codec := h.codec(ctx, serType)
+	data, err := codec.Marshal(msg)
+	return data, codec.ContentType(), err
+}
+
+func (h *httpHandler) serializeOut(ctx context.Context, resp http.ResponseWriter, req *http.Request, info *methodInfo, msg proto.Message, responseHeaders http.Header) error {
 	data, contentType, err := h.serialize(ctx, msg)
 	if err != nil {
 		writeRespWithHeaders(resp, http.StatusInternalServerError, []byte("Internal Server Error!"), responseHeaders)
 		return fmt.Errorf("Internal Server Error")
 	}
+	if h.etagEnabled(info) && responseHeaders.Get("ETag") == "" {
+		responseHeaders.Set("ETag", etag(data, contentType, resp))
+	}
+	if notModified(req, responseHeaders) {
+		writeRespWithHeaders(resp, http.StatusNotModified, nil, responseHeaders)
+		return nil
+	}
 	responseHeaders.Add("Content-Type", contentType)
 	writeRespWithHeaders(resp, http.StatusOK, data, responseHeaders)
 	return nil
 }
+
+// httpTransportStream collects the metadata services set with grpc.SetHeader and grpc.SetTrailer during HTTP calls
+type httpTransportStream struct {
+	method  string
+	mu      sync.Mutex
+	header  metadata.MD
+	trailer metadata.MD
+}
+
+func (s *httpTransportStream) Method() string {
+	return s.method
+}
+
+func (s *httpTransportStream) SetHeader(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *httpTransportStream) SendHeader(md metadata.MD) error {
+	// headers are written with the response
+	return s.SetHeader(md)
+}
+
+func (s *httpTransportStream) SetTrailer(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
+
+// writeMetadata adds the collected header metadata as GRPCMetadataHeaderPrefix headers and the trailer metadata as
+// trailers with the same prefix, this has to be called before the response is written
+func (s *httpTransportStream) writeMetadata(resp http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeHeaderMetadata(resp.Header(), s.header)
+	writeTrailerMetadata(resp.Header(), s.trailer)
+}
+
+// writeHeaderMetadata adds the header metadata as GRPCMetadataHeaderPrefix headers, the prefix keeps services from
+// overwriting the headers set by the handler
+func writeHeaderMetadata(hdr http.Header, md metadata.MD) {
+	for key, values := range md {
+		for _, value := range values {
+			hdr.Add(GRPCMetadataHeaderPrefix+key, metadataHeaderValue(key, value))
+		}
+	}
+}
+
+// writeTrailerMetadata adds the trailer metadata as GRPCMetadataHeaderPrefix trailers
+func writeTrailerMetadata(hdr http.Header, md metadata.MD) {
+	for key, values := range md {
+		key = http.TrailerPrefix + http.CanonicalHeaderKey(GRPCMetadataHeaderPrefix+key)
+		for _, value := range values {
+			hdr[key] = append(hdr[key], metadataHeaderValue(key, value))
+		}
+	}
+}
+
+// metadataHeaderValue encodes the value of a metadata key as a header value, binary values are base64 encoded
+func metadataHeaderValue(key, value string) string {
+	if strings.HasSuffix(strings.ToLower(key), "-bin") {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	return value
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestResponseMetadata(t *testing.T) {
+	base := startTestHandler(t, Config{}, nil)
+	tests := []struct {
+		value  string
+		status int
+		header string
+	}{
+		{"hello", http.StatusOK, "1"},
+		{"fail", http.StatusBadRequest, ""},
+	}
+	for _, test := range tests {
+		resp, err := http.Post(base+"/testservice/upper", ContentTypeJSON, strings.NewReader(`{"value":"`+test.value+`"}`))
+		if !assert.NoError(t, err, test.value) {
+			continue
+		}
+		resp.Body.Close()
+		assert.Equal(t, test.status, resp.StatusCode, test.value)
+		assert.Equal(t, test.header, resp.Header.Get(GRPCMetadataHeaderPrefix+"X-Upper"), "header metadata should be sent as prefixed headers")
+	}
+}
+
+func TestHTTPTransportStream(t *testing.T) {
+	stream := &httpTransportStream{method: "/test.TestService/Upper"}
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+	assert.Equal(t, "/test.TestService/Upper", grpc.ServerTransportStreamFromContext(ctx).Method())
+	assert.NoError(t, grpc.SetHeader(ctx, metadata.Pairs("x-version", "1", "trace-bin", "\x00\x01")))
+	assert.NoError(t, grpc.SendHeader(ctx, metadata.Pairs("x-version", "2")))
+	assert.NoError(t, grpc.SetTrailer(ctx, metadata.Pairs("x-count", "3")))
+
+	rec := &headerWriter{header: http.Header{}}
+	stream.writeMetadata(rec)
+	assert.Equal(t, []string{"1", "2"}, rec.header.Values("Grpc-Metadata-X-Version"))
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte{0, 1}), rec.header.Get("Grpc-Metadata-Trace-Bin"))
+	assert.Equal(t, []string{"3"}, rec.header[http.TrailerPrefix+"Grpc-Metadata-X-Count"], "trailer metadata should be sent as trailers")
+}
+
+// headerWriter is a response writer that only records headers
+type headerWriter struct {
+	http.ResponseWriter
+	header http.Header
+}
+
+func (w *headerWriter) Header() http.Header {
+	return w.header
+}
+
+func TestAcceptedMediaType(t *testing.T) {
+	tests := []struct {
+		accept    string
+		serType   string
+		mediaType string
+	}{
+		{"", "JSONPB", ContentTypeJSON},
+		{"application/jsonpb", "JSONPB", ContentTypeJSONPB},
+		{"application/jsonpb, application/json;q=0.9", "JSONPB", ContentTypeJSONPB},
+		{"application/jsonpb, application/json;q=0.9", "JSON", ContentTypeJSON},
+		{"application/json, application/jsonpb", "JSONPB", ContentTypeJSON},
+		{"application/x-jsonpb", "jsonpb", "application/x-jsonpb"},
+		{"application/jsonpb;q=0, application/json", "JSONPB", ContentTypeJSON},
+		{"*/*", "JSONPB", ContentTypeJSON},
+	}
+	for _, test := range tests {
+		mediaType := acceptedMediaType(parseAccept([]string{test.accept}), test.serType, getCodec(test.serType).ContentType())
+		assert.Equal(t, test.mediaType, mediaType, test.accept+" "+test.serType)
+	}
+}
+
+func TestDefaultEncoderQuery(t *testing.T) {
+	tests := []struct {
+		method string
+		url    string
+		body   string
+		value  string
+	}{
+		{http.MethodGet, "/upper?value=hello", "", "hello"},
+		{http.MethodHead, "/upper?value=hello", "", "hello"},
+		{http.MethodDelete, "/upper?value=hello", "", "hello"},
+		{http.MethodDelete, "/upper", "", ""},
+		{http.MethodGet, "/upper", "", ""},
+		// bodies are preferred over query parameters
+		{http.MethodDelete, "/upper?value=query", `{"value":"body"}`, "body"},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, test.url, ioutil.NopCloser(strings.NewReader(test.body)))
+		msg := new(wrappers.StringValue)
+		assert.NoError(t, DefaultEncoder(req, msg), test.method+" "+test.url)
+		assert.Equal(t, test.value, msg.GetValue(), test.method+" "+test.url)
+	}
+}
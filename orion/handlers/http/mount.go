@@ -0,0 +1,143 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-orion/Orion/utils/log/loggers"
+	"github.com/gorilla/mux"
+)
+
+// mount is a handler served for all paths under a prefix
+type mount struct {
+	prefix  string
+	handler http.Handler
+}
+
+//AddMount is the implementation of handlers.Mountable
+func (h *httpHandler) AddMount(prefix string, handler http.Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	for i := range h.mounts {
+		if h.mounts[i].prefix == prefix {
+			h.mounts[i].handler = handler
+			return
+		}
+	}
+	h.mounts = append(h.mounts, mount{prefix: prefix, handler: handler})
+}
+
+// match matches the paths served by the mount, see underPrefix
+func (m mount) match(req *http.Request, _ *mux.RouteMatch) bool {
+	return underPrefix(req.URL.Path, m.prefix)
+}
+
+// underPrefix checks if a path is the prefix or lies under it, '/admin' matches '/admin' and '/admin/users'
+// but not '/administrator'
+func underPrefix(p, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// stripPrefix removes the prefix from the path of requests under it, other requests are not found
+func stripPrefix(prefix string, handler http.Handler) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	strip := http.StripPrefix(prefix, handler)
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if !underPrefix(req.URL.Path, prefix) {
+			http.NotFound(resp, req)
+			return
+		}
+		strip.ServeHTTP(resp, req)
+	})
+}
+
+// sortedMounts returns the mounts with the longest prefixes first, so that the most specific mount serves a path
+func (h *httpHandler) sortedMounts() []mount {
+	mounts := append([]mount(nil), h.mounts...)
+	sort.SliceStable(mounts, func(i, j int) bool {
+		return len(mounts[i].prefix) > len(mounts[j].prefix)
+	})
+	return mounts
+}
+
+// getMountHandler wraps a mounted handler with the same tracing, logging and panic recovery as RPC routes
+func (h *httpHandler) getMountHandler(m mount) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		serveInstrumented(resp, req, "http", func(resp http.ResponseWriter, req *http.Request) (context.Context, error) {
+			ctx := loggers.AddToLogContext(req.Context(), "mount", m.prefix)
+			m.handler.ServeHTTP(resp, req.WithContext(ctx))
+			return ctx, nil
+		}, nil)
+	}
+}
+
+//FileServer serves the files of fs for all paths under prefix, use http.Dir for directories and http.FS for embedded assets
+func FileServer(prefix string, fs http.FileSystem) http.Handler {
+	return stripPrefix(prefix, http.FileServer(fs))
+}
+
+//SPAServer serves the files of fs for all paths under prefix and falls back to index.html for paths
+//that do not match a file, so that client side routes of single page applications can be loaded directly
+func SPAServer(prefix string, fs http.FileSystem) http.Handler {
+	return stripPrefix(prefix, &spaHandler{
+		fs:    fs,
+		files: http.FileServer(fs),
+	})
+}
+
+type spaHandler struct {
+	fs    http.FileSystem
+	files http.Handler
+}
+
+func (s *spaHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		if f, err := s.fs.Open(path.Clean("/" + req.URL.Path)); err == nil {
+			f.Close()
+		} else {
+			// unknown paths are client side routes, the file server serves index.html for the root
+			req = req.Clone(req.Context())
+			req.URL.Path, req.URL.RawPath = "/", ""
+		}
+	}
+	s.files.ServeHTTP(resp, req)
+}
+
+//OpenAPIServer serves an OpenAPI document, e.g. the one generated by protoc-gen-orion, at path
+func OpenAPIServer(path string, spec []byte) http.Handler {
+	return &documentHandler{
+		path:        strings.TrimSuffix(path, "/"),
+		contentType: ContentTypeJSON,
+		data:        spec,
+	}
+}
+
+// documentHandler serves a static document at a single path
+type documentHandler struct {
+	path        string
+	contentType string
+	data        []byte
+}
+
+func (d *documentHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if strings.TrimSuffix(req.URL.Path, "/") != d.path {
+		http.NotFound(resp, req)
+		return
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		resp.Header().Set("Allow", "GET, HEAD")
+		http.Error(resp, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	resp.Header().Set("Content-Type", d.contentType)
+	http.ServeContent(resp, req, "", time.Time{}, bytes.NewReader(d.data))
+}
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnderPrefix(t *testing.T) {
+	tests := []struct {
+		path   string
+		prefix string
+		ok     bool
+	}{
+		{"/admin", "/admin", true},
+		{"/admin/", "/admin", true},
+		{"/admin/users", "/admin", true},
+		{"/admin/users", "/admin/", true},
+		{"/admin", "/admin/", true},
+		{"/administrator", "/admin", false},
+		{"/administrator", "/admin/", false},
+		{"/adm", "/admin", false},
+		{"/anything", "/", true},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.ok, underPrefix(test.path, test.prefix), test.path+" "+test.prefix)
+	}
+}
+
+func TestMounts(t *testing.T) {
+	files := http.FS(fstest.MapFS{
+		"index.html": {Data: []byte("index")},
+		"app.js":     {Data: []byte("app")},
+	})
+	base := startTestHandler(t, Config{}, func(h *httpHandler) {
+		h.AddMount("/admin", http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			resp.Write([]byte("admin " + req.URL.Path))
+		}))
+		h.AddMount("/static/", FileServer("/static/", files))
+		h.AddMount("/app", SPAServer("/app", files))
+	})
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/admin", http.StatusOK, "admin /admin"},
+		{"/admin/users", http.StatusOK, "admin /admin/users"},
+		{"/administrator", http.StatusNotFound, ""},
+		{"/static/app.js", http.StatusOK, "app"},
+		{"/staticapp.js", http.StatusNotFound, ""},
+		{"/app/app.js", http.StatusOK, "app"},
+		{"/app/settings/profile", http.StatusOK, "index"},
+		{"/apple", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		resp, err := http.Get(base + test.path)
+		if !assert.NoError(t, err, test.path) {
+			continue
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, test.status, resp.StatusCode, test.path)
+		if test.body != "" {
+			assert.Equal(t, test.body, string(data), test.path)
+		}
+	}
+
+	// handlers used outside of mounts check the prefix as well
+	for _, handler := range []http.Handler{FileServer("/static", files), SPAServer("/static", files)} {
+		req, _ := http.NewRequest(http.MethodGet, "/staticapp.js", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// deprecatedRequests counts the requests served on deprecated routes
+var deprecatedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "orion_http_deprecated_route_requests_total",
+	Help: "Total number of HTTP requests served on deprecated routes.",
+}, []string{"route"})
+
+func init() {
+	prometheus.MustRegister(deprecatedRequests)
+}
+
+// withDeprecation marks responses of deprecated routes with the Deprecation header and counts their requests
+func withDeprecation(rt route, handler http.HandlerFunc) http.HandlerFunc {
+	if !rt.deprecated {
+		return handler
+	}
+	counter := deprecatedRequests.WithLabelValues(rt.path)
+	return func(resp http.ResponseWriter, req *http.Request) {
+		resp.Header().Set("Deprecation", "true")
+		if rt.canonical != "" {
+			resp.Header().Add("Link", "<"+rt.canonical+">; rel=\"successor-version\"")
+		}
+		counter.Inc()
+		handler(resp, req)
+	}
+}
+
+// routingPolicy applies the trailing slash and case policies to requests that do not match a route as is,
+// requests with a trailing slash are redirected and requests differing in case are served by the canonical route
+func (h *httpHandler) routingPolicy(r *mux.Router, routes []route) http.Handler {
+	if !h.config.RedirectTrailingSlash && !h.config.CaseInsensitiveRoutes {
+		return r
+	}
+	templates := make([]string, 0, len(routes))
+	for _, rt := range routes {
+		templates = append(templates, rt.path)
+	}
+	matches := func(req *http.Request) bool {
+		var match mux.RouteMatch
+		return r.Match(req, &match) && match.MatchErr == nil
+	}
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if matches(req) {
+			r.ServeHTTP(resp, req)
+			return
+		}
+		path, trimmed := req.URL.Path, false
+		if h.config.RedirectTrailingSlash && len(path) > 1 && strings.HasSuffix(path, "/") {
+			path, trimmed = strings.TrimSuffix(path, "/"), true
+		}
+		if h.config.CaseInsensitiveRoutes {
+			if canonical, ok := canonicalPath(templates, path); ok {
+				path = canonical
+			}
+		}
+		target := req.Clone(req.Context())
+		target.URL.Path, target.URL.RawPath = path, ""
+		if path == req.URL.Path || !matches(target) {
+			r.ServeHTTP(resp, req)
+			return
+		}
+		if trimmed {
+			// 308 keeps the method and body of non idempotent requests
+			code := http.StatusPermanentRedirect
+			if req.Method == http.MethodGet || req.Method == http.MethodHead {
+				code = http.StatusMovedPermanently
+			}
+			accessLogMiddleware(http.RedirectHandler(target.URL.String(), code)).ServeHTTP(resp, req)
+			return
+		}
+		r.ServeHTTP(resp, target)
+	})
+}
+
+// canonicalPath finds the first template matching path regardless of the case of its literal segments
+// and returns path with the literal segments of the template
+func canonicalPath(templates []string, path string) (string, bool) {
+	segments := strings.Split(path, "/")
+	for _, tpl := range templates {
+		parts := strings.Split(tpl, "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		canonical := make([]string, len(parts))
+		for i := range parts {
+			_, isVar, pattern := parseSegment(parts[i])
+			switch {
+			case !isVar && strings.EqualFold(parts[i], segments[i]):
+				canonical[i] = parts[i]
+			case isVar && segments[i] != "" && segmentMatches(pattern, segments[i]):
+				canonical[i] = segments[i]
+			default:
+				canonical = nil
+			}
+			if canonical == nil {
+				break
+			}
+		}
+		if canonical != nil {
+			return strings.Join(canonical, "/"), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteURL(t *testing.T) {
+	tests := []struct {
+		service   string
+		method    string
+		versioned bool
+		url       string
+	}{
+		// unversioned urls use the second part of the service name
+		{"echo.v1.EchoService", "Upper", false, "/v1/upper"},
+		{"echo.v1.EchoService", "Upper", true, "/v1/echoservice/upper"},
+		{"echo.v2beta1.EchoService", "Upper", true, "/v2beta1/echoservice/upper"},
+		{"v1.echo.EchoService", "Upper", true, "/v1/echoservice/upper"},
+		{"echo.EchoService", "Upper", true, "/echoservice/upper"},
+		{"echo.version1.EchoService", "Upper", true, "/version1/upper"},
+		// the service name itself is not a version
+		{"echo.V1", "Upper", true, "/v1/upper"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.url, RouteURL(test.service, test.method, test.versioned), test.service)
+	}
+}
+
+func TestCanonicalPath(t *testing.T) {
+	templates := []string{"/users/{id:[0-9]+}/Profile", "/users/{name}/settings", "/Files/{path}"}
+	tests := []struct {
+		path      string
+		canonical string
+		ok        bool
+	}{
+		{"/users/42/Profile", "/users/42/Profile", true},
+		{"/USERS/42/profile", "/users/42/Profile", true},
+		// variables keep the case of the request
+		{"/users/Alice/SETTINGS", "/users/Alice/settings", true},
+		{"/files/README", "/Files/README", true},
+		{"/users/alice/profile", "", false},
+		{"/users//settings", "", false},
+		{"/users/42", "", false},
+		{"/other", "", false},
+	}
+	for _, test := range tests {
+		canonical, ok := canonicalPath(templates, test.path)
+		assert.Equal(t, test.ok, ok, test.path)
+		assert.Equal(t, test.canonical, canonical, test.path)
+	}
+}
+
+func TestRoutingPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		method   string
+		path     string
+		status   int
+		location string
+	}{
+		{"exact", Config{}, http.MethodPost, "/testservice/upper", http.StatusOK, ""},
+		{"trailing slash served", Config{}, http.MethodPost, "/testservice/upper/", http.StatusOK, ""},
+		{"case sensitive", Config{}, http.MethodPost, "/TestService/Upper", http.StatusNotFound, ""},
+		{"redirect post", Config{RedirectTrailingSlash: true}, http.MethodPost, "/testservice/upper/", http.StatusPermanentRedirect, "/testservice/upper"},
+		{"redirect get", Config{RedirectTrailingSlash: true}, http.MethodGet, "/testservice/split/?value=a", http.StatusMovedPermanently, "/testservice/split?value=a"},
+		{"redirect unknown", Config{RedirectTrailingSlash: true}, http.MethodPost, "/testservice/unknown/", http.StatusNotFound, ""},
+		{"case insensitive", Config{CaseInsensitiveRoutes: true}, http.MethodPost, "/TestService/Upper", http.StatusOK, ""},
+		{"redirect to canonical case", Config{RedirectTrailingSlash: true, CaseInsensitiveRoutes: true}, http.MethodPost, "/TestService/Upper/",
+			http.StatusPermanentRedirect, "/testservice/upper"},
+		{"case insensitive method mismatch", Config{CaseInsensitiveRoutes: true}, http.MethodGet, "/TestService/Upper", http.StatusNotFound, ""},
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	for _, test := range tests {
+		base := startTestHandler(t, test.config, nil)
+		req, _ := http.NewRequest(test.method, base+test.path, strings.NewReader(`{"value":"a"}`))
+		resp, err := client.Do(req)
+		if !assert.NoError(t, err, test.name) {
+			continue
+		}
+		resp.Body.Close()
+		assert.Equal(t, test.status, resp.StatusCode, test.name)
+		assert.Equal(t, test.location, resp.Header.Get("Location"), test.name)
+	}
+}
+
+func TestVersionPrefix(t *testing.T) {
+	versioned := testServiceDesc
+	versioned.ServiceName = "test.v1.VersionedService"
+	tests := []struct {
+		path       string
+		status     int
+		deprecated bool
+	}{
+		{"/v1/versionedservice/upper", http.StatusOK, false},
+		{"/v1/upper", http.StatusOK, true},
+		// services without a versioned package keep their urls
+		{"/testservice/upper", http.StatusOK, false},
+		{"/v1/testservice/upper", http.StatusNotFound, false},
+	}
+	base := startTestHandler(t, Config{VersionPrefix: true}, func(h *httpHandler) {
+		assert.NoError(t, h.Add(&versioned, testService{}))
+	})
+	for _, test := range tests {
+		resp, err := http.Post(base+test.path, ContentTypeJSON, strings.NewReader(`{"value":"a"}`))
+		if !assert.NoError(t, err, test.path) {
+			continue
+		}
+		resp.Body.Close()
+		assert.Equal(t, test.status, resp.StatusCode, test.path)
+		assert.Equal(t, test.deprecated, resp.Header.Get("Deprecation") == "true", test.path)
+		if test.deprecated {
+			assert.Equal(t, `</v1/versionedservice/upper>; rel="successor-version"`, resp.Header.Get("Link"), test.path)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-orion/Orion/utils/log"
+)
+
+// route is a RPC route of the HTTP handler
+type route struct {
+	methods []string
+	path    string
+	info    *methodInfo
+	// deprecated routes are served with a Deprecation header, canonical is the route replacing them if any
+	deprecated bool
+	canonical  string
+}
+
+func (r route) String() string {
+	return fmt.Sprintf("%v %s (%s/%s)", r.methods, r.path, r.info.serviceName, r.info.methodName)
+}
+
+//RouteTableError reports the problems found when validating the route table
+type RouteTableError struct {
+	Problems []string
+}
+
+func (e *RouteTableError) Error() string {
+	return "invalid route table:\n\t- " + strings.Join(e.Problems, "\n\t- ")
+}
+
+// routes returns the RPC routes in the order they are matched, routes with a trailing slash are not included
+func (h *httpHandler) routes() []route {
+	routes := make([]route, 0)
+	for _, info := range h.mapping.GetAllMethodInfoByOrder() {
+		deprecated := false
+		for _, opt := range info.options {
+			if strings.ToUpper(strings.TrimSpace(opt)) == Deprecated {
+				deprecated = true
+			}
+		}
+		for _, url := range info.urls {
+			if strings.TrimSpace(info.encoderPath) != "" {
+				if info.encoderPath != url {
+					// only add the encoder url if encoder is defined, skip others
+					continue
+				}
+			}
+			routes = append(routes, route{
+				methods:    info.httpMethod,
+				path:       url,
+				info:       info,
+				deprecated: deprecated,
+			})
+			for alias, canonical := range info.aliases {
+				if canonical == url {
+					routes = append(routes, route{
+						methods:    info.httpMethod,
+						path:       alias,
+						info:       info,
+						deprecated: true,
+						canonical:  canonical,
+					})
+				}
+			}
+		}
+	}
+	return routes
+}
+
+// notFound records a registration for a method that is not served by this handler
+func (h *httpHandler) notFound(kind, serviceName, method string) {
+	log.Warn(context.Background(), "error", "Service and Method NOT found!", "service", serviceName,
+		"method", method, "mapping", h.mapping)
+	h.dangling = append(h.dangling, fmt.Sprintf("%s registered for unknown method %s/%s", kind, serviceName, method))
+}
+
+//Validate is the implementation of handlers.Validatable
+func (h *httpHandler) Validate() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	problems := append([]string(nil), h.dangling...)
+
+	// defaults registered for services that are not served
+	services := make(map[string]bool)
+	for _, info := range h.mapping.GetAllMethodInfoByOrder() {
+		services[cleanSvcName(info.serviceName)] = true
+	}
+	unknown := make([]string, 0)
+	check := func(kind, svc string) {
+		if !services[svc] {
+			unknown = append(unknown, fmt.Sprintf("%s registered for unknown service %s", kind, svc))
+		}
+	}
+	for svc := range h.defEncoders {
+		check("default encoder", svc)
+	}
+	for svc := range h.defDecoders {
+		check("default decoder", svc)
+	}
+	for svc := range h.defWSConfigs {
+		check("default websocket config", svc)
+	}
+	for svc := range h.defWSUpgraders {
+		check("default websocket upgrader", svc)
+	}
+	sort.Strings(unknown)
+	problems = append(problems, unknown...)
+
+	// middlewares that cannot be resolved, chains are built here so that they are not resolved on first request
+	for _, info := range h.mapping.GetAllMethodInfoByOrder() {
+		if err := h.buildChain(info, false); err != nil {
+			problems = append(problems, fmt.Sprintf("middlewares of %s/%s: %s", info.serviceName, info.methodName, err))
+		}
+	}
+
+	routes := h.routes()
+	for i := range routes {
+		for j := i + 1; j < len(routes); j++ {
+			a, b := routes[i], routes[j]
+			if a.info == b.info || !methodsOverlap(a.methods, b.methods) {
+				continue
+			}
+			switch comparePaths(a.path, b.path) {
+			case pathsEqual:
+				problems = append(problems, fmt.Sprintf("%s conflicts with %s", b, a))
+			case pathsAmbiguous:
+				problems = append(problems, fmt.Sprintf("%s and %s can match the same requests", a, b))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return &RouteTableError{Problems: problems}
+	}
+	return nil
+}
+
+func methodsOverlap(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if strings.EqualFold(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+const (
+	pathsDistinct = iota
+	pathsEqual
+	pathsAmbiguous
+)
+
+// comparePaths checks if two path templates match the same requests, variables match any value unless they have a pattern
+func comparePaths(a, b string) int {
+	as, bs := strings.Split(strings.Trim(a, "/"), "/"), strings.Split(strings.Trim(b, "/"), "/")
+	if len(as) != len(bs) {
+		return pathsDistinct
+	}
+	result := pathsEqual
+	for i := range as {
+		x, xVar, xPattern := parseSegment(as[i])
+		y, yVar, yPattern := parseSegment(bs[i])
+		switch {
+		case x == y:
+			continue
+		case !xVar && !yVar:
+			return pathsDistinct
+		case xVar && yVar && xPattern != "" && yPattern != "":
+			// different patterns are assumed to match different values
+			return pathsDistinct
+		case !xVar && !segmentMatches(yPattern, as[i]), !yVar && !segmentMatches(xPattern, bs[i]):
+			return pathsDistinct
+		}
+		result = pathsAmbiguous
+	}
+	return result
+}
+
+// parseSegment normalizes a path segment by removing variable names, isVar is true if the segment has variables and
+// pattern is the pattern of a segment that is a single variable
+func parseSegment(segment string) (normalized string, isVar bool, pattern string) {
+	buf := new(strings.Builder)
+	depth, start := 0, 0
+	for i, c := range segment {
+		switch {
+		case c == '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+			if depth == 0 {
+				isVar = true
+				variable := segment[start+1 : i]
+				p := ""
+				if idx := strings.Index(variable, ":"); idx >= 0 {
+					p = variable[idx+1:]
+				}
+				buf.WriteString("{" + p + "}")
+				if start == 0 && i == len(segment)-1 {
+					pattern = p
+				}
+			}
+		case depth == 0:
+			buf.WriteRune(c)
+		}
+	}
+	return buf.String(), isVar, pattern
+}
+
+// segmentMatches checks if a variable with pattern can match a literal segment
+func segmentMatches(pattern, literal string) bool {
+	if pattern == "" {
+		return true
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return true
+	}
+	return re.MatchString(literal)
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSegment(t *testing.T) {
+	tests := []struct {
+		segment    string
+		normalized string
+		isVar      bool
+		pattern    string
+	}{
+		{"users", "users", false, ""},
+		{"{id}", "{}", true, ""},
+		{"{id:[0-9]+}", "{[0-9]+}", true, "[0-9]+"},
+		{"{id:[0-9]{1,3}}", "{[0-9]{1,3}}", true, "[0-9]{1,3}"},
+		{"v{version}", "v{}", true, ""},
+		{"{name}.{ext}", "{}.{}", true, ""},
+		{"{ id : [a-z]+ }", "{ [a-z]+ }", true, " [a-z]+ "},
+	}
+	for _, test := range tests {
+		normalized, isVar, pattern := parseSegment(test.segment)
+		assert.Equal(t, test.normalized, normalized, test.segment)
+		assert.Equal(t, test.isVar, isVar, test.segment)
+		assert.Equal(t, test.pattern, pattern, test.segment)
+	}
+}
+
+func TestComparePaths(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		result int
+	}{
+		{"/users", "/users", pathsEqual},
+		{"/users/", "/users", pathsEqual},
+		{"/users/{id}", "/users/{name}", pathsEqual},
+		{"/users/{id:[0-9]+}", "/users/{uid:[0-9]+}", pathsEqual},
+		{"/users", "/groups", pathsDistinct},
+		{"/users", "/users/{id}", pathsDistinct},
+		{"/users/{id}", "/users/me", pathsAmbiguous},
+		{"/users/{id:[0-9]+}", "/users/me", pathsDistinct},
+		{"/users/{id:[0-9]+}", "/users/42", pathsAmbiguous},
+		{"/users/{id:[0-9]+}", "/users/{name:[a-z]+}", pathsDistinct},
+		{"/users/{id:[0-9]+}", "/users/{name}", pathsAmbiguous},
+		{"/{svc}/{method}", "/users/me", pathsAmbiguous},
+		{"/{svc}/list", "/users/get", pathsDistinct},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.result, comparePaths(test.a, test.b), test.a+" "+test.b)
+		assert.Equal(t, test.result, comparePaths(test.b, test.a), "comparison should be symmetric "+test.b+" "+test.a)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	h := NewHTTPHandler(Config{}).(*httpHandler)
+	assert.NoError(t, h.Add(&testServiceDesc, testService{}))
+	assert.NoError(t, h.Validate())
+
+	h.AddEncoder("test.TestService", "Upper", []string{"POST"}, "/api/{value}", nil)
+	h.AddEncoder("test.TestService", "Split", []string{"POST"}, "/api/{other}", nil)
+	h.AddEncoder("test.TestService", "Missing", []string{"POST"}, "/missing", nil)
+	err := h.Validate()
+	if assert.IsType(t, &RouteTableError{}, err) {
+		problems := err.(*RouteTableError).Problems
+		assert.Len(t, problems, 2)
+		assert.Contains(t, problems[0], "unknown method test.TestService/Missing")
+		assert.Contains(t, problems[1], "conflicts with")
+	}
+}
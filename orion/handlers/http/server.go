@@ -0,0 +1,94 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-orion/Orion/utils/log"
+)
+
+const (
+	// DefaultReadTimeout is the time allowed to read a request when none is configured
+	DefaultReadTimeout = 5 * time.Second
+	// DefaultWriteTimeout is the time allowed to write a response when none is configured
+	DefaultWriteTimeout = 10 * time.Second
+)
+
+// timeout returns the configured timeout, zero uses the default and negative values disable the timeout
+func timeout(value, def time.Duration) time.Duration {
+	switch {
+	case value == 0:
+		return def
+	case value < 0:
+		return 0
+	}
+	return value
+}
+
+// newServer builds the http server for the router using the handler config
+func (h *httpHandler) newServer(handler http.Handler) *http.Server {
+	svr := &http.Server{
+		ReadTimeout:       timeout(h.config.ReadTimeout, DefaultReadTimeout),
+		ReadHeaderTimeout: timeout(h.config.ReadHeaderTimeout, 0),
+		WriteTimeout:      timeout(h.config.WriteTimeout, DefaultWriteTimeout),
+		IdleTimeout:       timeout(h.config.IdleTimeout, 0),
+		MaxHeaderBytes:    h.config.MaxHeaderBytes,
+		Handler:           handler,
+	}
+	if h.config.EnableH2C {
+		// HTTP/2 over cleartext connections with prior knowledge, as used by gRPC clients and load balancers
+		protocols := new(http.Protocols)
+		protocols.SetHTTP1(true)
+		protocols.SetUnencryptedHTTP2(true)
+		svr.Protocols = protocols
+	}
+	return svr
+}
+
+// routeTimeouts finds the READ_TIMEOUT and WRITE_TIMEOUT options of a method, found is false when the server timeouts apply
+func routeTimeouts(info *methodInfo) (read, write time.Duration, readFound, writeFound bool) {
+	for _, opt := range info.options {
+		opt = strings.ToUpper(strings.TrimSpace(opt))
+		switch {
+		case strings.HasPrefix(opt, ReadTimeout+"="):
+			if d, err := time.ParseDuration(strings.ToLower(opt[len(ReadTimeout)+1:])); err == nil {
+				read, readFound = d, true
+			}
+		case strings.HasPrefix(opt, WriteTimeout+"="):
+			if d, err := time.ParseDuration(strings.ToLower(opt[len(WriteTimeout)+1:])); err == nil {
+				write, writeFound = d, true
+			}
+		}
+	}
+	return read, write, readFound, writeFound
+}
+
+// withRouteTimeouts overrides the server read and write deadlines for methods with timeout options,
+// a timeout of zero removes the deadline
+func withRouteTimeouts(info *methodInfo, handler http.HandlerFunc) http.HandlerFunc {
+	read, write, readFound, writeFound := routeTimeouts(info)
+	if !readFound && !writeFound {
+		return handler
+	}
+	deadline := func(d time.Duration) time.Time {
+		if d <= 0 {
+			return time.Time{}
+		}
+		return time.Now().Add(d)
+	}
+	return func(resp http.ResponseWriter, req *http.Request) {
+		rc := http.NewResponseController(resp)
+		if readFound {
+			if err := rc.SetReadDeadline(deadline(read)); err != nil {
+				log.Debug(req.Context(), "path", req.URL.Path, "error", err, "msg", "could not set read deadline")
+			}
+		}
+		if writeFound {
+			if err := rc.SetWriteDeadline(deadline(write)); err != nil {
+				log.Debug(req.Context(), "path", req.URL.Path, "error", err, "msg", "could not set write deadline")
+			}
+		}
+		handler(resp, req)
+	}
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		value  time.Duration
+		def    time.Duration
+		result time.Duration
+	}{
+		{0, time.Second, time.Second},
+		{0, 0, 0},
+		{time.Minute, time.Second, time.Minute},
+		{-1, time.Second, 0},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.result, timeout(test.value, test.def), "%s %s", test.value, test.def)
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	h := &httpHandler{}
+	svr := h.newServer(nil)
+	assert.Equal(t, DefaultReadTimeout, svr.ReadTimeout)
+	assert.Equal(t, DefaultWriteTimeout, svr.WriteTimeout)
+	assert.Zero(t, svr.ReadHeaderTimeout)
+	assert.Zero(t, svr.IdleTimeout)
+	assert.Nil(t, svr.Protocols, "h2c should be disabled by default")
+
+	h = &httpHandler{config: Config{
+		ReadTimeout:       -1,
+		ReadHeaderTimeout: time.Second,
+		WriteTimeout:      time.Minute,
+		IdleTimeout:       time.Hour,
+		MaxHeaderBytes:    1024,
+		EnableH2C:         true,
+	}}
+	svr = h.newServer(nil)
+	assert.Zero(t, svr.ReadTimeout, "negative timeouts should disable the timeout")
+	assert.Equal(t, time.Second, svr.ReadHeaderTimeout)
+	assert.Equal(t, time.Minute, svr.WriteTimeout)
+	assert.Equal(t, time.Hour, svr.IdleTimeout)
+	assert.Equal(t, 1024, svr.MaxHeaderBytes)
+	if assert.NotNil(t, svr.Protocols) {
+		assert.True(t, svr.Protocols.HTTP1())
+		assert.True(t, svr.Protocols.UnencryptedHTTP2())
+	}
+}
+
+func TestRouteTimeouts(t *testing.T) {
+	tests := []struct {
+		options    []string
+		read       time.Duration
+		write      time.Duration
+		readFound  bool
+		writeFound bool
+	}{
+		{nil, 0, 0, false, false},
+		{[]string{"READ_TIMEOUT=5s"}, 5 * time.Second, 0, true, false},
+		{[]string{" write_timeout=10M "}, 0, 10 * time.Minute, false, true},
+		{[]string{"READ_TIMEOUT=1m30s", "WRITE_TIMEOUT=0"}, 90 * time.Second, 0, true, true},
+		{[]string{"READ_TIMEOUT=soon", "OTHER"}, 0, 0, false, false},
+	}
+	for _, test := range tests {
+		read, write, readFound, writeFound := routeTimeouts(&methodInfo{options: test.options})
+		assert.Equal(t, test.read, read, "%v", test.options)
+		assert.Equal(t, test.write, write, "%v", test.options)
+		assert.Equal(t, test.readFound, readFound, "%v", test.options)
+		assert.Equal(t, test.writeFound, writeFound, "%v", test.options)
+	}
+}
+
+func TestWithRouteTimeouts(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []string
+		ok      bool
+	}{
+		{"server timeout", nil, false},
+		{"longer route timeout", []string{"WRITE_TIMEOUT=5s"}, true},
+		{"no route timeout", []string{"WRITE_TIMEOUT=0"}, true},
+	}
+	for _, test := range tests {
+		slow := func(resp http.ResponseWriter, req *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+			resp.Write([]byte("done"))
+		}
+		h := &httpHandler{config: Config{WriteTimeout: 50 * time.Millisecond}}
+		svr := h.newServer(withRouteTimeouts(&methodInfo{options: test.options}, slow))
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if !assert.NoError(t, err) {
+			return
+		}
+		go svr.Serve(lis)
+
+		resp, err := http.Get("http://" + lis.Addr().String())
+		if err == nil {
+			data, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Equal(t, "done", string(data), test.name)
+		}
+		assert.Equal(t, test.ok, err == nil, test.name)
+		svr.Close()
+	}
+}
+
+func TestH2C(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		base := startTestHandler(t, Config{EnableH2C: enabled}, nil)
+		protocols := new(http.Protocols)
+		protocols.SetUnencryptedHTTP2(true)
+		client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+		resp, err := client.Get(base + "/testservice/upper")
+		if enabled && assert.NoError(t, err) {
+			resp.Body.Close()
+			assert.Equal(t, 2, resp.ProtoMajor, "HTTP/2 should be served with prior knowledge")
+		}
+		if !enabled {
+			assert.Error(t, err, "HTTP/2 should not be served without h2c")
+		}
+		client.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,278 @@
+package http
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-orion/Orion/orion/modifiers"
+	"github.com/go-orion/Orion/utils/errors"
+	"github.com/go-orion/Orion/utils/log"
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// frame flags used by length prefixed protobuf streams
+const (
+	frameMessage byte = 0x00
+	frameTrailer byte = 0x80
+)
+
+// getStreamHandler serves websocket connections for upgrade requests and falls back to chunked HTTP for the rest
+func (h *httpHandler) getStreamHandler(serviceName, methodName string) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		if websocket.IsWebSocketUpgrade(req) {
+			h.wsHandler(resp, req, serviceName, methodName)
+			return
+		}
+		h.chunkedHandler(resp, req, serviceName, methodName)
+	}
+}
+
+func (h *httpHandler) chunkedHandler(resp http.ResponseWriter, req *http.Request, service, method string) {
+	var stream *chunkedStream
+	serveInstrumented(resp, req, "chunked", func(resp http.ResponseWriter, req *http.Request) (context.Context, error) {
+		return h.serveChunked(resp, req, service, method, &stream)
+	}, func(resp http.ResponseWriter) {
+		// once messages have been written the panic is reported as the trailing message of the stream
+		if stream != nil && stream.headersSent {
+			stream.finish(status.Error(codes.Internal, "Internal Server Error!"))
+			return
+		}
+		writeResp(resp, http.StatusInternalServerError, []byte("Internal Server Error!"))
+	})
+}
+
+// serveChunked serves a server stream over a chunked HTTP response, the stream is stored in stream as soon as it
+// is created so that panics can be reported on it
+func (h *httpHandler) serveChunked(resp http.ResponseWriter, req *http.Request, service, method string, stream **chunkedStream) (context.Context, error) {
+	info, ok := h.mapping.Get(service, method)
+	if !ok {
+		writeResp(resp, http.StatusNotFound, []byte("Not Found: "+req.URL.String()))
+		return req.Context(), errors.New("Not Found: " + req.URL.String())
+	}
+
+	//setup context
+	ctx := prepareContext(req, info)
+	ctx = processOptions(ctx, req, info)
+	req = req.WithContext(ctx)
+	if err := h.prepareBody(resp, req, info); err != nil {
+		return ctx, errors.Wrap(err, "Bad Request")
+	}
+
+	// httpHandler allows handling entire http request
+	if info.httpHandler != nil {
+		if info.httpHandler(resp, req) {
+			// short circuit if handler has handled request
+			return ctx, nil
+		}
+	}
+
+	if info.stream == nil || info.clientStreams {
+		// client streams need a full duplex connection, only websockets can provide that
+		writeResp(resp, http.StatusBadRequest, []byte("Bad Request: websocket connection required"))
+		return ctx, errors.New("only server streams can be served over chunked HTTP")
+	}
+
+	if _, _, err := negotiate(ctx); err != nil {
+		writeResp(resp, http.StatusNotAcceptable, []byte("Not Acceptable!"))
+		return ctx, errors.Wrap(err, "Not Acceptable")
+	}
+
+	flusher, ok := resp.(http.Flusher)
+	if !ok {
+		writeResp(resp, http.StatusInternalServerError, []byte("Internal Server Error!"))
+		return ctx, errors.New("response writer does not support flushing")
+	}
+	// every frame is flushed, streams are compressed regardless of the minimum size
+	cw := h.compressWriter(resp, req, info)
+	if cw != nil {
+		resp, flusher = cw, cw
+	}
+
+	//create a cancelable context from request context
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	*stream = &chunkedStream{
+		ctx:        streamCtx,
+		req:        req,
+		resp:       resp,
+		flusher:    flusher,
+		compressor: cw,
+		han:        h,
+		info:       info,
+		serType:    serializationType(ctx),
+	}
+	// handle the stream
+	err := h.serveStream(info, *stream)
+	(*stream).finish(err)
+	return ctx, err
+}
+
+// chunkedStream implements grpc.ServerStream over a chunked HTTP/1.1 response
+//
+// Messages are written as newline delimited json, or as length prefixed protobuf when
+// protobuf is negotiated. Every protobuf frame starts with a one byte flag followed by the
+// four byte big endian length of the payload.
+type chunkedStream struct {
+	ctx         context.Context
+	req         *http.Request
+	resp        http.ResponseWriter
+	flusher     http.Flusher
+	compressor  *compressWriter
+	han         *httpHandler
+	info        *methodInfo
+	serType     string
+	received    bool
+	headersSent bool
+	header      metadata.MD
+	trailer     metadata.MD
+}
+
+// chunkedError is the trailing message sent on newline delimited json streams when the stream fails
+type chunkedError struct {
+	Error struct {
+		Code    uint32 `json:"code"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (s *chunkedStream) isProto() bool {
+	return s.serType == modifiers.ProtoBuf
+}
+
+func (s *chunkedStream) SetHeader(md metadata.MD) error {
+	if s.headersSent {
+		return errors.New("headers already sent")
+	}
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *chunkedStream) SendHeader(md metadata.MD) error {
+	if err := s.SetHeader(md); err != nil {
+		return err
+	}
+	s.writeHeader(http.StatusOK)
+	return nil
+}
+
+func (s *chunkedStream) SetTrailer(md metadata.MD) {
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+func (s *chunkedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *chunkedStream) writeHeader(code int) {
+	if s.headersSent {
+		return
+	}
+	s.headersSent = true
+	writeHeaderMetadata(s.resp.Header(), s.header)
+	if code == http.StatusOK {
+		if s.isProto() {
+			s.resp.Header().Set("Content-Type", ContentTypeProtoStream)
+		} else {
+			s.resp.Header().Set("Content-Type", ContentTypeNDJSON)
+		}
+		s.resp.Header().Set("X-Content-Type-Options", "nosniff")
+	}
+	s.resp.WriteHeader(code)
+	s.flusher.Flush()
+}
+
+func (s *chunkedStream) writeFrame(flag byte, data []byte) error {
+	if s.isProto() {
+		prefix := make([]byte, 5)
+		prefix[0] = flag
+		binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+		if _, err := s.resp.Write(prefix); err != nil {
+			return err
+		}
+		if _, err := s.resp.Write(data); err != nil {
+			return err
+		}
+	} else {
+		if _, err := s.resp.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	// flush every message, a slow client blocks the writer here
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *chunkedStream) SendMsg(m interface{}) error {
+	if err := s.ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	var data []byte
+	var err error
+	if protoMsg, ok := m.(proto.Message); ok {
+		data, _, err = s.han.serializeAs(s.ctx, s.serType, protoMsg)
+	} else {
+		data, err = json.Marshal(m)
+	}
+	if err != nil {
+		return err
+	}
+	s.writeHeader(http.StatusOK)
+	return s.writeFrame(frameMessage, data)
+}
+
+func (s *chunkedStream) RecvMsg(m interface{}) error {
+	// server streams only ever receive a single request
+	if s.received {
+		return io.EOF
+	}
+	s.received = true
+	return s.han.encode(s.req, s.info, m)
+}
+
+// finish writes the stream status, errors are sent as a HTTP error if nothing has been written yet
+// otherwise they are sent as a trailing message
+func (s *chunkedStream) finish(err error) {
+	if s.compressor != nil {
+		// the compressed response ends after the status
+		defer s.compressor.Close()
+	}
+	writeTrailerMetadata(s.resp.Header(), s.trailer)
+	if err == nil {
+		s.writeHeader(http.StatusOK)
+		return
+	}
+	if !s.headersSent {
+		code, msg := GrpcErrorToHTTP(err, http.StatusInternalServerError, "Internal Server Error!")
+		s.resp.Header().Set(GRPCStatusHeader, strconv.Itoa(int(status.Code(err))))
+		s.writeHeader(code)
+		s.resp.Write([]byte(msg))
+		return
+	}
+	st := status.Convert(err)
+	var data []byte
+	if s.isProto() {
+		data, err = proto.Marshal(st.Proto())
+	} else {
+		msg := chunkedError{}
+		msg.Error.Code = uint32(st.Code())
+		msg.Error.Status = st.Code().String()
+		msg.Error.Message = st.Message()
+		data, err = json.Marshal(msg)
+	}
+	if err == nil {
+		err = s.writeFrame(frameTrailer, data)
+	}
+	if err != nil {
+		log.Warn(s.ctx, "stream", "could not write trailing message", "error", err)
+	}
+}
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/stretchr/testify/assert"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestChunkedNDJSON(t *testing.T) {
+	base := startTestHandler(t, Config{}, nil)
+	tests := []struct {
+		value    string
+		status   int
+		messages []string
+		trailer  string
+	}{
+		{"hello big world", http.StatusOK, []string{"hello", "big", "world"}, ""},
+		{"hello fail", http.StatusOK, []string{"hello"}, `{"error":{"code":10,"status":"Aborted","message":"cannot split fail"}}`},
+		{"hello panic", http.StatusOK, []string{"hello"}, `{"error":{"code":13,"status":"Internal","message":"Internal Server Error!"}}`},
+		{"fail", http.StatusConflict, nil, ""},
+		{"panic", http.StatusInternalServerError, nil, ""},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodPost, base+"/testservice/split", strings.NewReader(`{"value":"`+test.value+`"}`))
+		req.Header.Set("Content-Type", ContentTypeJSON)
+		req.Header.Set("Accept", ContentTypeNDJSON)
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err, test.value) {
+			continue
+		}
+		assert.Equal(t, test.status, resp.StatusCode, test.value)
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			continue
+		}
+		assert.Equal(t, ContentTypeNDJSON, resp.Header.Get("Content-Type"), test.value)
+		assert.Equal(t, "1", resp.Header.Get(GRPCMetadataHeaderPrefix+"x-split"), test.value)
+		var messages []string
+		trailer := ""
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.HasPrefix(line, `{"error"`) {
+				trailer = line
+				continue
+			}
+			msg := new(wrappers.StringValue)
+			assert.NoError(t, json.Unmarshal([]byte(line), msg), line)
+			messages = append(messages, msg.GetValue())
+		}
+		resp.Body.Close()
+		assert.Equal(t, test.messages, messages, test.value)
+		assert.Equal(t, test.trailer, trailer, test.value)
+	}
+}
+
+func TestChunkedProtoStream(t *testing.T) {
+	base := startTestHandler(t, Config{}, nil)
+	tests := []struct {
+		value    string
+		messages []string
+		code     int32
+		message  string
+	}{
+		{"hello big world", []string{"hello", "big", "world"}, -1, ""},
+		{"hello fail", []string{"hello"}, 10, "cannot split fail"},
+		{"hello panic", []string{"hello"}, 13, "Internal Server Error!"},
+	}
+	for _, test := range tests {
+		data, _ := proto.Marshal(&wrappers.StringValue{Value: test.value})
+		req, _ := http.NewRequest(http.MethodPost, base+"/testservice/split", bytes.NewReader(data))
+		req.Header.Set("Content-Type", ContentTypeProtobuf)
+		req.Header.Set("Accept", ContentTypeProtoStream)
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err, test.value) {
+			continue
+		}
+		assert.Equal(t, http.StatusOK, resp.StatusCode, test.value)
+		assert.Equal(t, ContentTypeProtoStream, resp.Header.Get("Content-Type"), test.value)
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		var messages []string
+		code := int32(-1)
+		message := ""
+		for len(body) >= 5 {
+			length := binary.BigEndian.Uint32(body[1:5])
+			if !assert.True(t, len(body) >= int(5+length), "frames should not be truncated") {
+				break
+			}
+			payload := body[5 : 5+length]
+			if body[0]&frameTrailer != 0 {
+				st := new(spb.Status)
+				assert.NoError(t, proto.Unmarshal(payload, st))
+				code, message = st.GetCode(), st.GetMessage()
+			} else {
+				msg := new(wrappers.StringValue)
+				assert.NoError(t, proto.Unmarshal(payload, msg))
+				messages = append(messages, msg.GetValue())
+			}
+			body = body[5+length:]
+		}
+		assert.Empty(t, body, "stream should end at a frame boundary")
+		assert.Equal(t, test.messages, messages, test.value)
+		assert.Equal(t, test.code, code, test.value)
+		assert.Equal(t, test.message, message, test.value)
+	}
+}
+
+func TestChunkedMetadata(t *testing.T) {
+	rec := httptest.NewRecorder()
+	stream := &chunkedStream{
+		ctx:     context.Background(),
+		resp:    rec,
+		flusher: rec,
+		header:  metadata.Pairs("content-type", "text/html", "x-content-type-options", "sniff", "x-data-bin", "\x00\x01"),
+		trailer: metadata.Pairs("x-words", "2", "x-sum-bin", "\xff"),
+	}
+	stream.finish(nil)
+	resp := rec.Result()
+	assert.Equal(t, ContentTypeNDJSON, resp.Header.Get("Content-Type"), "services should not overwrite the content type")
+	assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+	assert.Equal(t, "text/html", resp.Header.Get(GRPCMetadataHeaderPrefix+"content-type"))
+	assert.Equal(t, "sniff", resp.Header.Get(GRPCMetadataHeaderPrefix+"x-content-type-options"))
+	assert.Equal(t, "AAE=", resp.Header.Get(GRPCMetadataHeaderPrefix+"x-data-bin"), "binary values should be base64 encoded")
+	assert.Equal(t, "2", resp.Trailer.Get(GRPCMetadataHeaderPrefix+"x-words"))
+	assert.Equal(t, "/w==", resp.Trailer.Get(GRPCMetadataHeaderPrefix+"x-sum-bin"))
+}
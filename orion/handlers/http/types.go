@@ -3,10 +3,10 @@ package http
 import (
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/go-orion/Orion/orion/handlers"
 	"github.com/go-orion/Orion/orion/modifiers"
-	"github.com/golang/protobuf/jsonpb"
 	"google.golang.org/grpc"
 )
 
@@ -14,35 +14,126 @@ var (
 	//ContentTypeMap is the mapping of content-type with marshaling type
 	ContentTypeMap = map[string]string{
 		ContentTypeJSON:                   modifiers.JSON,
-		"application/jsonpb":              modifiers.JSONPB,
+		ContentTypeJSONPB:                 modifiers.JSONPB,
 		"application/x-jsonpb":            modifiers.JSONPB,
-		"application/protobuf":            modifiers.ProtoBuf,
+		ContentTypeProtobuf:               modifiers.ProtoBuf,
+		"application/x-protobuf":          modifiers.ProtoBuf,
 		"application/proto":               modifiers.ProtoBuf,
 		"application/x-proto":             modifiers.ProtoBuf,
 		"application/vnd.google.protobuf": modifiers.ProtoBuf,
 		ContentTypeProto:                  modifiers.ProtoBuf,
+		ContentTypeNDJSON:                 modifiers.JSON,
+		"application/ndjson":              modifiers.JSON,
+		"application/x-json-stream":       modifiers.JSON,
+		ContentTypeProtoStream:            modifiers.ProtoBuf,
 	}
 
 	// DefaultHTTPResponseHeaders are response headers that are whitelisted by default
 	DefaultHTTPResponseHeaders = []string{
 		"Content-Type",
+		"Cache-Control",
+		"Vary",
+		"ETag",
+		"Last-Modified",
+		"Expires",
 	}
 )
 
 const (
 	//IgnoreNR is the option flag to ignore newrelic for this method
 	IgnoreNR = "IGNORE_NR"
+	//NoCompression is the option flag to disable response compression for this method
+	NoCompression = "NO_COMPRESSION"
+	//MaxBodySize is the option used to set the maximum request body size for this method, e.g. 'MAX_BODY_SIZE=10MB'
+	MaxBodySize = "MAX_BODY_SIZE"
+	//CodecOption is the option used to select the codec for this method, e.g. 'CODEC=JSONPB'
+	CodecOption = "CODEC"
+	//EmitDefaults is the option flag to render zero values in responses of this method, 'EMIT_DEFAULTS=FALSE'
+	//overrides the handler options. Like the other codec options it selects the JSONPB codec unless CODEC is set
+	EmitDefaults = "EMIT_DEFAULTS"
+	//OrigName is the option flag to use proto field names in responses of this method, 'ORIG_NAME=FALSE' overrides
+	//the handler options
+	OrigName = "ORIG_NAME"
+	//EnumsAsInts is the option flag to render enums as integers in responses of this method, 'ENUMS_AS_INTS=FALSE'
+	//overrides the handler options
+	EnumsAsInts = "ENUMS_AS_INTS"
+	//ETag is the option flag to compute entity tags and answer conditional GET requests for this method
+	ETag = "ETAG"
+	//ReadTimeout is the option used to override the server read timeout for this method, e.g. 'READ_TIMEOUT=5M'
+	ReadTimeout = "READ_TIMEOUT"
+	//WriteTimeout is the option used to override the server write timeout for this method, e.g. 'WRITE_TIMEOUT=10M'
+	WriteTimeout = "WRITE_TIMEOUT"
+	//Deprecated is the option flag to mark all routes of this method as deprecated
+	Deprecated = "DEPRECATED"
 )
 
 const (
 	ContentTypeJSON  = "application/json"
 	ContentTypeProto = "application/octet-stream"
+	// ContentTypeJSONPB is accepted by clients that need to tell jsonpb responses from json ones, both use
+	// ContentTypeJSON unless the client asks for this content type
+	ContentTypeJSONPB = "application/jsonpb"
+	// ContentTypeProtobuf is the content type of protobuf responses unless the client asks for another one
+	ContentTypeProtobuf = "application/protobuf"
+	// ContentTypeNDJSON is the content type used for newline delimited json streams
+	ContentTypeNDJSON = "application/x-ndjson"
+	// ContentTypeProtoStream is the content type used for length prefixed protobuf streams
+	ContentTypeProtoStream = "application/x-protobuf-stream"
+)
+
+const (
+	//GRPCStatusHeader is the response header carrying the gRPC status code of failed calls
+	GRPCStatusHeader = "Grpc-Status"
+	//GRPCMetadataHeaderPrefix is the prefix of request headers that are passed on to the service as gRPC metadata,
+	//e.g. 'Grpc-Metadata-Request-Id' is available as the 'request-id' key of incoming metadata. Header and trailer
+	//metadata set by the service with grpc.SetHeader and grpc.SetTrailer is sent back with the same prefix as
+	//response headers and trailers
+	GRPCMetadataHeaderPrefix = "Grpc-Metadata-"
 )
 
 //Config is the configuration for HTTP Handler
 type Config struct {
 	handlers.CommonConfig
 	EnableProtoURL bool
+	// EnableGRPCWeb serves gRPC-Web requests on the proto urls of unary and server streaming methods
+	EnableGRPCWeb bool
+	// GRPCWebAllowedOrigins are the origins other than the server's own that can call gRPC-Web methods from a browser,
+	// '*' allows all origins and '*.example.com' allows example.com and its subdomains
+	GRPCWebAllowedOrigins []string
+	// RedirectTrailingSlash redirects requests with a trailing slash to the canonical route instead of
+	// serving every route with and without a trailing slash
+	RedirectTrailingSlash bool
+	// CaseInsensitiveRoutes matches the literal parts of routes regardless of case
+	CaseInsensitiveRoutes bool
+	// VersionPrefix serves generated urls under the version of the proto package, e.g. '/v1/echoservice/upper',
+	// the unversioned urls are kept as deprecated aliases
+	VersionPrefix bool
+	// EnableH2C serves HTTP/2 over cleartext connections using prior knowledge
+	EnableH2C bool
+	// ReadTimeout, ReadHeaderTimeout, WriteTimeout and IdleTimeout are the server timeouts, zero uses
+	// the default and a negative value disables the timeout
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// MaxHeaderBytes is the maximum size in bytes of request headers, zero uses http.DefaultMaxHeaderBytes
+	MaxHeaderBytes int
+	// EnableCompression compresses responses with the encoding negotiated from Accept-Encoding, gzip and deflate are
+	// always available and zstd only in cgo builds. Chunked streams and gRPC-Web calls are flushed per frame and
+	// compressed regardless of CompressionMinSize, websockets negotiate their own compression
+	EnableCompression bool
+	// CompressionMinSize is the minimum response size in bytes that is compressed
+	CompressionMinSize int
+	// MaxDecompressedSize is the maximum size in bytes a compressed request body can expand to
+	MaxDecompressedSize int64
+	// MaxBodySize is the maximum size in bytes of request bodies, zero means no limit
+	MaxBodySize int64
+	// EnableETags computes entity tags for all responses and answers conditional GET requests with 304
+	EnableETags bool
+	// WebSocket is the websocket configuration used when none is registered for the service or method
+	WebSocket handlers.WSConfig
+	// Codec are the default options used by codecs that support them, methods can override them with annotations
+	Codec handlers.CodecOptions
 }
 
 type serviceInfo struct {
@@ -59,23 +150,35 @@ type methodInfo struct {
 	encoder       handlers.Encoder
 	decoder       handlers.Decoder
 	httpHandler   handlers.HTTPHandler
+	wsConfig      *handlers.WSConfig
+	wsUpgrader    handlers.WSUpgrader
+	fileSink      handlers.FileSink
 	httpMethod    []string
 	encoderPath   string
 	serviceName   string
 	methodName    string
 	urls          []string
+	aliases       map[string]string
 	options       []string
 	clientStreams bool
 	serverStreams bool
+	// chain is the composed interceptor chain of the method, built once on first use
+	chain       sync.Once
+	unary       grpc.UnaryServerInterceptor
+	streamChain grpc.StreamServerInterceptor
+	chainErr    error
 }
 
 type httpHandler struct {
-	mu          sync.Mutex
-	mapping     *methodInfoMapping
-	middlewares *handlers.MiddlewareMapping
-	defEncoders map[string]handlers.Encoder
-	defDecoders map[string]handlers.Decoder
-	mar         jsonpb.Marshaler
-	svr         *http.Server
-	config      Config
+	mu             sync.Mutex
+	mapping        *methodInfoMapping
+	middlewares    *handlers.MiddlewareMapping
+	defEncoders    map[string]handlers.Encoder
+	defDecoders    map[string]handlers.Decoder
+	defWSConfigs   map[string]handlers.WSConfig
+	defWSUpgraders map[string]handlers.WSUpgrader
+	mounts         []mount
+	dangling       []string
+	svr            *http.Server
+	config         Config
 }
@@ -3,6 +3,7 @@ package http
 import (
 	"context"
 	"net/http"
+	"regexp"
 	"strings"
 
 	"github.com/go-orion/Orion/utils/headers"
@@ -11,12 +12,12 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-//ContentTypeFromHeaders searches for a matching content type
+//ContentTypeFromHeaders searches for a matching content type, media type parameters like charset are ignored
 func ContentTypeFromHeaders(ctx context.Context) string {
 	hdrs := headers.RequestHeadersFromContext(ctx)
 	if values, found := hdrs["Content-Type"]; found {
 		for _, v := range values {
-			if t, ok := ContentTypeMap[v]; ok {
+			if t, _ := serTypeForMediaType(v); t != "" {
 				return t
 			}
 		}
@@ -24,17 +25,11 @@ func ContentTypeFromHeaders(ctx context.Context) string {
 	return ""
 }
 
-//AcceptTypeFromHeaders searches for a mathing accept type
+//AcceptTypeFromHeaders searches for the best matching accept type using q-values and wildcards (RFC 7231)
 func AcceptTypeFromHeaders(ctx context.Context) string {
 	hdrs := headers.RequestHeadersFromContext(ctx)
-	if values, found := hdrs["Accept"]; found {
-		for _, v := range values {
-			if t, ok := ContentTypeMap[v]; ok {
-				return t
-			}
-		}
-	}
-	return ""
+	t, _, _ := negotiateAccept(hdrs["Accept"], "")
+	return t
 }
 
 // GrpcErrorToHTTP converts gRPC error code into HTTP response status code.
@@ -122,6 +117,41 @@ func generateProtoURL(serviceName, method string) string {
 	return "/" + serviceName + "/" + method
 }
 
+var versionSegment = regexp.MustCompile(`^v[0-9]+((alpha|beta)[0-9]*)?$`)
+
+// packageVersion returns the version of the proto package of a service, e.g. 'v1' for 'echo.v1.EchoService'
+func packageVersion(serviceName string) string {
+	parts := strings.Split(strings.ToLower(serviceName), ".")
+	for i := len(parts) - 2; i >= 0; i-- {
+		if versionSegment.MatchString(parts[i]) {
+			return parts[i]
+		}
+	}
+	return ""
+}
+
+// generateVersionedURL generates '/<version>/<service>/<method>' for services with a versioned proto package,
+// it returns an empty string for other services
+func generateVersionedURL(serviceName, method string) string {
+	version := packageVersion(serviceName)
+	if version == "" {
+		return ""
+	}
+	parts := strings.Split(strings.ToLower(serviceName), ".")
+	return "/" + version + "/" + parts[len(parts)-1] + "/" + strings.ToLower(method)
+}
+
+//RouteURL returns the url generated for a method, versioned selects the url served with the VersionPrefix config,
+//it falls back to the unversioned url for services without a versioned proto package
+func RouteURL(serviceName, method string, versioned bool) string {
+	if versioned {
+		if url := generateVersionedURL(serviceName, method); url != "" {
+			return url
+		}
+	}
+	return generateURL(serviceName, method)
+}
+
 func writeResp(resp http.ResponseWriter, status int, data []byte) {
 	writeRespWithHeaders(resp, status, data, nil)
 }
@@ -2,30 +2,60 @@ package http
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/go-orion/Orion/orion/handlers"
 	"github.com/go-orion/Orion/utils/errors"
 	"github.com/go-orion/Orion/utils/errors/notifier"
 	"github.com/go-orion/Orion/utils/log"
 	"github.com/go-orion/Orion/utils/log/loggers"
 	"github.com/golang/protobuf/proto"
 	"github.com/gorilla/websocket"
+	"github.com/grpc-ecosystem/go-grpc-middleware/util/metautils"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
-func (h *httpHandler) getWSHandler(serviceName, methodName string) http.HandlerFunc {
-	return func(resp http.ResponseWriter, req *http.Request) {
-		h.wsHandler(resp, req, serviceName, methodName)
-	}
+const (
+	wsFrameHeader  = "header"
+	wsFrameTrailer = "trailer"
+	wsFrameMessage = "message"
+)
+
+// wsHeaderFrame is the initial frame sent to the client carrying the header metadata set by the service
+type wsHeaderFrame struct {
+	Type     string              `json:"type"`
+	Metadata map[string][]string `json:"metadata"`
+}
+
+// wsMessageFrame wraps a text message sent to the client when metadata frames are enabled, json messages are
+// embedded as they are and other text messages are sent as a string
+type wsMessageFrame struct {
+	Type    string          `json:"type"`
+	Message json.RawMessage `json:"message,omitempty"`
+	Data    string          `json:"data,omitempty"`
+}
+
+// wsTrailerFrame is the final frame sent to the client carrying the trailer metadata and status of the stream
+type wsTrailerFrame struct {
+	Type     string              `json:"type"`
+	Metadata map[string][]string `json:"metadata,omitempty"`
+	Code     uint32              `json:"code"`
+	Status   string              `json:"status"`
+	Message  string              `json:"message,omitempty"`
 }
 
 func (h *httpHandler) wsHandler(resp http.ResponseWriter, req *http.Request, service, method string) {
 	var err error
 	var ctx context.Context
 	defer func(t time.Time) {
-		notifier.Notify(err, ctx, req.URL.String())
+		notifier.Notify(err, req.URL.String(), ctx)
 		log.Info(ctx, "path", req.URL.String(), "duration", time.Since(t), "err", err)
 	}(time.Now())
 	info, ok := h.mapping.Get(service, method)
@@ -34,6 +64,7 @@ func (h *httpHandler) wsHandler(resp http.ResponseWriter, req *http.Request, ser
 		ctx = prepareContext(req, info)
 		ctx = processOptions(ctx, req, info)
 		ctx = loggers.AddToLogContext(ctx, "transport", "ws")
+		ctx = incomingContext(ctx, req, info)
 		req = req.WithContext(ctx)
 
 		notifier.SetTraceId(ctx)
@@ -51,15 +82,21 @@ func (h *httpHandler) wsHandler(resp http.ResponseWriter, req *http.Request, ser
 		if info.stream == nil {
 			log.Error(ctx, "ws", "no stream registered", "url", req.URL.String())
 			err = errors.New("No stream registered")
+			writeResp(resp, http.StatusNotFound, []byte("Not Found: "+req.URL.String()))
+			return
 		}
 
+		config := wsConfigWithDefaults(h.wsConfig(info))
 		var con *websocket.Conn
-		con, err = DefaultWSUpgrader(resp, req, http.Header{})
+		con, err = h.wsUpgrader(info, config)(resp, req, http.Header{})
 		if err != nil {
 			log.Error(ctx, "wsUpgrade", "failed", "err", err, "url", req.URL.String())
 			return
 		}
 		defer con.Close()
+		if config.ReadLimit > 0 {
+			con.SetReadLimit(config.ReadLimit)
+		}
 
 		//create a cancelable context from request context
 		ctx = req.Context()
@@ -67,60 +104,208 @@ func (h *httpHandler) wsHandler(resp http.ResponseWriter, req *http.Request, ser
 		defer cancel()
 
 		stream := streamServer{
-			ctx: streamCtx,
-			con: con,
-			han: h,
+			ctx:           streamCtx,
+			cancel:        cancel,
+			con:           con,
+			han:           h,
+			clientStreams: info.clientStreams,
+			frames:        config.MetadataFrames,
+		}
+		if config.PingInterval > 0 {
+			con.SetReadDeadline(time.Now().Add(config.PongWait))
+			con.SetPongHandler(func(string) error {
+				return con.SetReadDeadline(time.Now().Add(config.PongWait))
+			})
+			go stream.keepalive(config.PingInterval)
 		}
 		// handle the stream
-		err = info.stream(info.svc.svc, &stream)
+		err = h.serveStream(info, &stream)
+		stream.finish(err)
 		return
 	}
 	writeResp(resp, http.StatusNotFound, []byte("Not Found: "+req.URL.String()))
 }
 
+// wsConfig finds the websocket configuration for a method, falling back to the service and handler configuration
+func (h *httpHandler) wsConfig(info *methodInfo) handlers.WSConfig {
+	if info.wsConfig != nil {
+		return *info.wsConfig
+	}
+	if config, ok := h.defWSConfigs[cleanSvcName(info.svc.desc.ServiceName)]; ok {
+		return config
+	}
+	return h.config.WebSocket
+}
+
+// wsUpgrader finds the websocket upgrader registered for a method, falling back to one built from config
+func (h *httpHandler) wsUpgrader(info *methodInfo, config handlers.WSConfig) handlers.WSUpgrader {
+	if info.wsUpgrader != nil {
+		return info.wsUpgrader
+	}
+	if upgrader, ok := h.defWSUpgraders[cleanSvcName(info.svc.desc.ServiceName)]; ok {
+		return upgrader
+	}
+	return NewWSUpgrader(config)
+}
+
+// incomingContext populates incoming gRPC metadata from the whitelisted request headers, like for unary calls other
+// headers of the upgrade request are not passed on, 'Grpc-Metadata-' headers are added by prefixedMetadata
+func incomingContext(ctx context.Context, req *http.Request, info *methodInfo) context.Context {
+	if len(info.svc.requestHeaders) == 0 {
+		return ctx
+	}
+	md := metautils.ExtractIncoming(ctx)
+	for _, hdr := range info.svc.requestHeaders {
+		for _, value := range req.Header.Values(hdr) {
+			md.Add(strings.ToLower(hdr), value)
+		}
+	}
+	return md.ToIncoming(ctx)
+}
+
 type streamServer struct {
-	ctx context.Context
-	con *websocket.Conn
-	han *httpHandler
+	ctx           context.Context
+	cancel        context.CancelFunc
+	con           *websocket.Conn
+	han           *httpHandler
+	header        metadata.MD
+	trailer       metadata.MD
+	headerSent    bool
+	clientStreams bool
+	received      bool
+	// frames sends metadata frames and wraps text messages, see WSConfig.MetadataFrames
+	frames bool
 }
 
-func (s *streamServer) SetHeader(metadata.MD) error {
-	return nil
+// keepalive pings the client until the stream is done, the stream is canceled when a ping can not be sent
+func (s *streamServer) keepalive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.con.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval)); err != nil {
+				log.Debug(s.ctx, "ws", "ping failed", "error", err)
+				s.cancel()
+				return
+			}
+		}
+	}
 }
 
-func (s *streamServer) SendHeader(metadata.MD) error {
+// discard keeps reading from a server stream once its request has been received, this processes
+// pongs and close messages and cancels the stream when the client goes away
+func (s *streamServer) discard() {
+	defer s.cancel()
+	for {
+		if _, _, err := s.con.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *streamServer) SetHeader(md metadata.MD) error {
+	if s.headerSent {
+		return errors.New("headers already sent")
+	}
+	s.header = metadata.Join(s.header, md)
 	return nil
 }
 
-func (s *streamServer) SetTrailer(metadata.MD) {
+func (s *streamServer) SendHeader(md metadata.MD) error {
+	if err := s.SetHeader(md); err != nil {
+		return err
+	}
+	return s.writeHeader(true)
+}
+
+func (s *streamServer) SetTrailer(md metadata.MD) {
+	s.trailer = metadata.Join(s.trailer, md)
 }
 
 func (s *streamServer) Context() context.Context {
 	return s.ctx
 }
 
+// writeHeader sends the header frame, unless forced it is only sent when the service has set any header
+func (s *streamServer) writeHeader(force bool) error {
+	if s.headerSent || !s.frames {
+		return nil
+	}
+	if !force && len(s.header) == 0 {
+		return nil
+	}
+	s.headerSent = true
+	md := s.header
+	if md == nil {
+		md = metadata.MD{}
+	}
+	return s.con.WriteJSON(wsHeaderFrame{
+		Type:     wsFrameHeader,
+		Metadata: md,
+	})
+}
+
 func (s *streamServer) SendMsg(m interface{}) error {
+	if err := s.writeHeader(false); err != nil {
+		return err
+	}
 	if protoMsg, ok := m.(proto.Message); ok {
-		data, contentType, err := s.han.serialize(s.Context(), protoMsg)
+		data, contentType, err := s.han.serializeAs(s.Context(), serializationType(s.Context()), protoMsg)
 		if err != nil {
 			return err
 		}
-		switch contentType {
-		case ContentTypeProto:
+		if !isTextContentType(contentType) {
 			return s.con.WriteMessage(websocket.BinaryMessage, data)
-		default:
+		}
+		if !s.frames {
 			return s.con.WriteMessage(websocket.TextMessage, data)
 		}
+		frame := wsMessageFrame{Type: wsFrameMessage}
+		if strings.HasSuffix(contentType, "json") {
+			frame.Message = data
+		} else {
+			frame.Data = string(data)
+		}
+		return s.con.WriteJSON(frame)
+	}
+	if s.frames {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		return s.con.WriteJSON(wsMessageFrame{Type: wsFrameMessage, Message: data})
 	}
 	return s.con.WriteJSON(m)
 }
 
 func (s *streamServer) RecvMsg(m interface{}) error {
+	if !s.clientStreams {
+		// server streams only ever receive a single request
+		if s.received {
+			return io.EOF
+		}
+		if err := s.recvMsg(m); err != nil {
+			return err
+		}
+		s.received = true
+		go s.discard()
+		return nil
+	}
+	return s.recvMsg(m)
+}
+
+func (s *streamServer) recvMsg(m interface{}) error {
 	msgType, data, err := s.con.ReadMessage()
 	if err != nil {
 		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseNoStatusReceived, websocket.CloseGoingAway) {
 			return io.EOF
 		}
+		if e, ok := err.(*websocket.CloseError); ok {
+			return status.Error(WSCloseToGrpcCode(e.Code), e.Text)
+		}
 		return err
 	}
 	switch msgType {
@@ -131,5 +316,86 @@ func (s *streamServer) RecvMsg(m interface{}) error {
 	case websocket.CloseMessage:
 		return io.EOF
 	}
-	return s.RecvMsg(m)
+	return s.recvMsg(m)
+}
+
+// finish sends the trailer frame with the status of the stream, when metadata frames are enabled, and closes the
+// connection with a matching close code
+func (s *streamServer) finish(err error) {
+	st := status.Convert(err)
+	err = s.writeHeader(false)
+	if err == nil && s.frames {
+		err = s.con.WriteJSON(wsTrailerFrame{
+			Type:     wsFrameTrailer,
+			Metadata: s.trailer,
+			Code:     uint32(st.Code()),
+			Status:   st.Code().String(),
+			Message:  st.Message(),
+		})
+	}
+	if err == nil {
+		msg := websocket.FormatCloseMessage(GrpcCodeToWSClose(st.Code()), closeReason(st.Message()))
+		err = s.con.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	}
+	if err != nil {
+		log.Debug(s.ctx, "ws", "could not close stream", "error", err)
+	}
+}
+
+// closeReason truncates the reason to fit in a close frame
+func closeReason(reason string) string {
+	// control frames are limited to 125 bytes, 2 of which are used for the close code
+	if len(reason) <= 123 {
+		return reason
+	}
+	// cut on a rune boundary, close reasons have to be valid UTF-8
+	n := 123
+	for n > 0 && !utf8.RuneStart(reason[n]) {
+		n--
+	}
+	return reason[:n]
+}
+
+// GrpcCodeToWSClose converts a gRPC status code into a websocket close code
+func GrpcCodeToWSClose(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return websocket.CloseNormalClosure
+	case codes.Canceled:
+		return websocket.CloseGoingAway
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return websocket.CloseInvalidFramePayloadData
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return websocket.ClosePolicyViolation
+	case codes.Unimplemented:
+		return websocket.CloseUnsupportedData
+	case codes.ResourceExhausted:
+		return websocket.CloseTryAgainLater
+	case codes.Unavailable:
+		return websocket.CloseServiceRestart
+	}
+	return websocket.CloseInternalServerErr
+}
+
+// WSCloseToGrpcCode converts a websocket close code into a gRPC status code
+func WSCloseToGrpcCode(code int) codes.Code {
+	switch code {
+	case websocket.CloseNormalClosure:
+		return codes.OK
+	case websocket.CloseGoingAway, websocket.CloseNoStatusReceived:
+		return codes.Canceled
+	case websocket.CloseInvalidFramePayloadData, websocket.CloseProtocolError:
+		return codes.InvalidArgument
+	case websocket.ClosePolicyViolation:
+		return codes.PermissionDenied
+	case websocket.CloseUnsupportedData, websocket.CloseMandatoryExtension:
+		return codes.Unimplemented
+	case websocket.CloseMessageTooBig:
+		return codes.ResourceExhausted
+	case websocket.CloseTryAgainLater, websocket.CloseServiceRestart, websocket.CloseAbnormalClosure:
+		return codes.Unavailable
+	case websocket.CloseInternalServerErr:
+		return codes.Internal
+	}
+	return codes.Unknown
 }
@@ -0,0 +1,155 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/go-orion/Orion/orion/handlers"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestGrpcCodeToWSClose(t *testing.T) {
+	tests := []struct {
+		code  codes.Code
+		close int
+	}{
+		{codes.OK, websocket.CloseNormalClosure},
+		{codes.Canceled, websocket.CloseGoingAway},
+		{codes.InvalidArgument, websocket.CloseInvalidFramePayloadData},
+		{codes.FailedPrecondition, websocket.CloseInvalidFramePayloadData},
+		{codes.OutOfRange, websocket.CloseInvalidFramePayloadData},
+		{codes.Unauthenticated, websocket.ClosePolicyViolation},
+		{codes.PermissionDenied, websocket.ClosePolicyViolation},
+		{codes.Unimplemented, websocket.CloseUnsupportedData},
+		{codes.ResourceExhausted, websocket.CloseTryAgainLater},
+		{codes.Unavailable, websocket.CloseServiceRestart},
+		{codes.Internal, websocket.CloseInternalServerErr},
+		{codes.Unknown, websocket.CloseInternalServerErr},
+		{codes.DataLoss, websocket.CloseInternalServerErr},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.close, GrpcCodeToWSClose(test.code), test.code.String())
+	}
+}
+
+func TestWSCloseToGrpcCode(t *testing.T) {
+	tests := []struct {
+		close int
+		code  codes.Code
+	}{
+		{websocket.CloseNormalClosure, codes.OK},
+		{websocket.CloseGoingAway, codes.Canceled},
+		{websocket.CloseNoStatusReceived, codes.Canceled},
+		{websocket.CloseInvalidFramePayloadData, codes.InvalidArgument},
+		{websocket.CloseProtocolError, codes.InvalidArgument},
+		{websocket.ClosePolicyViolation, codes.PermissionDenied},
+		{websocket.CloseUnsupportedData, codes.Unimplemented},
+		{websocket.CloseMandatoryExtension, codes.Unimplemented},
+		{websocket.CloseMessageTooBig, codes.ResourceExhausted},
+		{websocket.CloseTryAgainLater, codes.Unavailable},
+		{websocket.CloseServiceRestart, codes.Unavailable},
+		{websocket.CloseAbnormalClosure, codes.Unavailable},
+		{websocket.CloseInternalServerErr, codes.Internal},
+		{4000, codes.Unknown},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.code, WSCloseToGrpcCode(test.close), test.close)
+	}
+
+	// codes with a dedicated close code survive a round trip
+	for _, code := range []codes.Code{codes.OK, codes.Canceled, codes.InvalidArgument, codes.Unimplemented, codes.Unavailable, codes.Internal} {
+		assert.Equal(t, code, WSCloseToGrpcCode(GrpcCodeToWSClose(code)), code.String())
+	}
+}
+
+func TestCloseReason(t *testing.T) {
+	tests := []struct {
+		reason string
+		length int
+	}{
+		{"", 0},
+		{"short reason", 12},
+		{strings.Repeat("a", 123), 123},
+		{strings.Repeat("a", 200), 123},
+		// the 3 byte rune starting at byte 122 does not fit
+		{strings.Repeat("a", 122) + "€", 122},
+		{strings.Repeat("€", 50), 123},
+		{strings.Repeat("a", 121) + "€€", 121},
+	}
+	for _, test := range tests {
+		reason := closeReason(test.reason)
+		assert.Len(t, reason, test.length, test.reason)
+		assert.True(t, utf8.ValidString(reason), test.reason)
+		assert.True(t, strings.HasPrefix(test.reason, reason), test.reason)
+	}
+}
+
+func TestIncomingContext(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/testservice/split", nil)
+	req.Header.Set("X-User-Id", "42")
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set(GRPCMetadataHeaderPrefix+"X-Trace", "abc")
+	info := &methodInfo{svc: &serviceInfo{requestHeaders: []string{"X-User-Id"}}}
+
+	ctx := incomingContext(prefixedMetadata(context.Background(), req), req, info)
+	md, _ := metadata.FromIncomingContext(ctx)
+	assert.Equal(t, metadata.Pairs("x-user-id", "42", "x-trace", "abc"), md, "only whitelisted and prefixed headers should be metadata")
+
+	// without whitelisted headers only the prefixed metadata is passed on
+	ctx = incomingContext(prefixedMetadata(context.Background(), req), req, &methodInfo{svc: &serviceInfo{}})
+	md, _ = metadata.FromIncomingContext(ctx)
+	assert.Equal(t, metadata.Pairs("x-trace", "abc"), md)
+}
+
+func TestWSMetadataFrames(t *testing.T) {
+	tests := []struct {
+		name     string
+		frames   bool
+		value    string
+		messages []string
+		code     int
+	}{
+		{"messages only", false, "hello world", []string{`{"value":"hello"}`, `{"value":"world"}`}, websocket.CloseNormalClosure},
+		{"frames", true, "hello world", []string{
+			`{"type":"header","metadata":{"x-split":["1"]}}`,
+			`{"type":"message","message":{"value":"hello"}}`,
+			`{"type":"message","message":{"value":"world"}}`,
+			`{"type":"trailer","metadata":{"x-words":["2"]},"code":0,"status":"OK"}`,
+		}, websocket.CloseNormalClosure},
+		{"error frames", true, "hello fail", []string{
+			`{"type":"header","metadata":{"x-split":["1"]}}`,
+			`{"type":"message","message":{"value":"hello"}}`,
+			`{"type":"trailer","code":10,"status":"Aborted","message":"cannot split fail"}`,
+		}, websocket.CloseInternalServerErr},
+	}
+	for _, test := range tests {
+		base := startTestHandler(t, Config{WebSocket: handlers.WSConfig{MetadataFrames: test.frames}}, nil)
+		con, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(base, "http")+"/testservice/split", nil)
+		if !assert.NoError(t, err, test.name) {
+			continue
+		}
+		assert.NoError(t, con.WriteMessage(websocket.TextMessage, []byte(`{"value":"`+test.value+`"}`)), test.name)
+		var messages []string
+		for {
+			_, data, err := con.ReadMessage()
+			if err != nil {
+				if assert.IsType(t, &websocket.CloseError{}, err, test.name) {
+					assert.Equal(t, test.code, err.(*websocket.CloseError).Code, test.name)
+				}
+				break
+			}
+			// json frames end with a newline
+			messages = append(messages, strings.TrimSpace(string(data)))
+		}
+		con.Close()
+		assert.Equal(t, test.messages, messages, test.name)
+	}
+}
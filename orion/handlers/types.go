@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"context"
+	"mime/multipart"
 	"net"
 	"net/http"
 	"time"
 
+	"github.com/go-orion/Orion/utils/cache"
+	"github.com/gorilla/websocket"
 	"google.golang.org/grpc"
 )
 
@@ -75,7 +78,83 @@ type Middlewareable interface {
 	AddMiddleware(serviceName, method string, middleware ...string)
 }
 
+// WSUpgrader is the function type needed for websocket upgraders
+type WSUpgrader func(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (*websocket.Conn, error)
+
+// WSConfig is the configuration used for websocket connections
+type WSConfig struct {
+	// AllowedOrigins is the list of origins that can open a websocket connection, '*' allows all origins
+	// and '*.example.com' allows example.com and its subdomains, only same origin requests are allowed when empty
+	AllowedOrigins []string
+	// Subprotocols are the supported subprotocols in order of preference
+	Subprotocols []string
+	// EnableCompression negotiates per message compression with the client
+	EnableCompression bool
+	// HandshakeTimeout is the time allowed for the upgrade handshake
+	HandshakeTimeout time.Duration
+	// ReadBufferSize and WriteBufferSize are the I/O buffer sizes in bytes
+	ReadBufferSize  int
+	WriteBufferSize int
+	// ReadLimit is the maximum size in bytes of a message sent by the client, zero means no limit
+	ReadLimit int64
+	// PingInterval is the interval at which pings are sent to the client, zero disables pings
+	PingInterval time.Duration
+	// PongWait is the time allowed to receive a pong before the connection is closed, defaults to twice the PingInterval
+	PongWait time.Duration
+	// MetadataFrames sends the header and trailer metadata and the status of a stream as 'header' and 'trailer'
+	// frames, text messages are then wrapped in 'message' frames so that every text frame has a type
+	MetadataFrames bool
+}
+
+//WSConfigurable interface that is implemented by a handler that supports websocket configuration and custom upgraders
+type WSConfigurable interface {
+	AddWSConfig(serviceName, method string, config WSConfig)
+	AddDefaultWSConfig(serviceName string, config WSConfig)
+	AddWSUpgrader(serviceName, method string, upgrader WSUpgrader)
+	AddDefaultWSUpgrader(serviceName string, upgrader WSUpgrader)
+}
+
+// CodecOptions are the options used by codecs when serializing HTTP responses, they are supported by the JSONPB codec
+type CodecOptions struct {
+	// EmitDefaults renders fields with zero values
+	EmitDefaults bool
+	// OrigName uses the original proto field names instead of lowerCamelCase names
+	OrigName bool
+	// EnumsAsInts renders enums as integers instead of their names
+	EnumsAsInts bool
+}
+
+//FileSink receives the file parts of multipart requests, a part can only be read until the sink returns
+type FileSink func(ctx context.Context, part *multipart.Part) error
+
+//FileSinkable interface that is implemented by a handler that supports streaming multipart file uploads
+type FileSinkable interface {
+	AddFileSink(serviceName, method string, sink FileSink)
+}
+
+//Mountable interface that is implemented by a handler that can serve arbitrary http handlers at path prefixes
+type Mountable interface {
+	AddMount(prefix string, handler http.Handler)
+}
+
+//Validatable interface that is implemented by a handler that can validate its registrations before it is run
+type Validatable interface {
+	Validate() error
+}
+
+//AdminServable interface that is implemented by a handler that can serve its admin services on a separate listener
+type AdminServable interface {
+	RunAdmin(adminListener net.Listener) error
+}
+
+//AdminAuthFunc authorizes calls to admin services like reflection and channelz, the call is rejected when it returns an error
+type AdminAuthFunc func(ctx context.Context, fullMethod string) error
+
 //CommonConfig is the config that is common across both http and grpc handlers
 type CommonConfig struct {
 	NoDefaultInterceptors bool
+	// CacheStore stores the responses of methods with the CACHE option, caching is disabled when nil
+	CacheStore cache.Store
+	// CacheConfigs are the cache configs of the methods of the server, they are used to invalidate cached responses
+	CacheConfigs *CacheConfigs
 }
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"reflect"
+	"strings"
 
 	"github.com/go-orion/Orion/interceptors"
 	"github.com/go-orion/Orion/orion/modifiers"
@@ -94,21 +95,59 @@ func chainStreamServer(interceptors ...grpc.StreamServerInterceptor) grpc.Stream
 
 //GetInterceptors fetches interceptors from a given GRPC service
 func GetInterceptors(svc interface{}, config CommonConfig) grpc.UnaryServerInterceptor {
-	return chainUnaryServer(getInterceptors(svc, config, []string{})...)
+	return GetInterceptorsWithMethodOptions(svc, config, []string{}, nil)
 }
 
 //GetStreamInterceptors fetches stream interceptors from a given GRPC service
 func GetStreamInterceptors(svc interface{}, config CommonConfig) grpc.StreamServerInterceptor {
-	return chainStreamServer(getStreamInterceptors(svc, config)...)
+	return GetStreamInterceptorsWithMethodOptions(svc, config, []string{}, nil)
+}
+
+//GetStreamInterceptorsWithMethodOptions fetches all stream interceptors including those provided by method middlewares and method options
+func GetStreamInterceptorsWithMethodOptions(svc interface{}, config CommonConfig, middlewares, options []string) grpc.StreamServerInterceptor {
+	interceptor, err := BuildStreamInterceptors(svc, config, middlewares, options)
+	reportMiddlewareError(err)
+	return interceptor
 }
 
 //GetInterceptorsWithMethodMiddlewares fetchs all middleware including those provided by method middlewares
 func GetInterceptorsWithMethodMiddlewares(svc interface{}, config CommonConfig, middlewares []string) grpc.UnaryServerInterceptor {
-	return chainUnaryServer(getInterceptors(svc, config, middlewares)...)
+	return GetInterceptorsWithMethodOptions(svc, config, middlewares, nil)
+}
+
+//GetInterceptorsWithMethodOptions fetchs all middleware including those provided by method middlewares and method options
+func GetInterceptorsWithMethodOptions(svc interface{}, config CommonConfig, middlewares, options []string) grpc.UnaryServerInterceptor {
+	interceptor, err := BuildInterceptors(svc, config, middlewares, options)
+	reportMiddlewareError(err)
+	return interceptor
 }
 
-func getInterceptors(svc interface{}, config CommonConfig, middlewares []string) []grpc.UnaryServerInterceptor {
+//BuildInterceptors composes the interceptor chain of a method, it should be built once and reused for all calls,
+//middlewares that cannot be resolved are skipped and reported in the returned error
+func BuildInterceptors(svc interface{}, config CommonConfig, middlewares, options []string) (grpc.UnaryServerInterceptor, error) {
+	opts, err := getInterceptors(svc, config, middlewares, options)
+	return chainUnaryServer(opts...), err
+}
+
+//BuildStreamInterceptors composes the stream interceptor chain of a method, it should be built once and reused for all calls,
+//middlewares that cannot be resolved are skipped and reported in the returned error
+func BuildStreamInterceptors(svc interface{}, config CommonConfig, middlewares, options []string) (grpc.StreamServerInterceptor, error) {
+	opts, err := getStreamInterceptors(svc, config, middlewares, options)
+	return chainStreamServer(opts...), err
+}
+
+func reportMiddlewareError(err error) {
+	if err != nil {
+		log.Error(context.Background(), "error", err, "middleware", "could not fetch middleware")
+		notifier.NotifyWithLevel(err, "critical")
+	}
+}
+
+func getInterceptors(svc interface{}, config CommonConfig, middlewares, options []string) ([]grpc.UnaryServerInterceptor, error) {
 	opts := []grpc.UnaryServerInterceptor{optionsInterceptor}
+	if len(options) > 0 {
+		opts = append(opts, methodOptionsInterceptor(options))
+	}
 
 	// check and add default interceptors
 	if !config.NoDefaultInterceptors {
@@ -123,13 +162,27 @@ func getInterceptors(svc interface{}, config CommonConfig, middlewares []string)
 	}
 
 	// check and add method interceptors
-	opts = append(opts, GetMethodInterceptors(svc, config, middlewares)...)
+	methodInterceptors, err := getMethodInterceptors(svc, middlewares)
+	opts = append(opts, methodInterceptors...)
+
+	// cache runs last so that responses are only served to requests allowed by the other interceptors
+	if config.CacheStore != nil {
+		if cacheConfig, ok := getCacheConfig(options); ok && validateCacheHeaders(svc, cacheConfig) == nil {
+			opts = append(opts, cacheInterceptor(config.CacheStore, config.CacheConfigs, cacheConfig))
+		} else if !config.CacheConfigs.empty() {
+			// methods that are not cached can still invalidate cached responses
+			opts = append(opts, invalidationInterceptor(config.CacheStore, config.CacheConfigs))
+		}
+	}
 
-	return opts
+	return opts, err
 }
 
-func getStreamInterceptors(svc interface{}, config CommonConfig) []grpc.StreamServerInterceptor {
+func getStreamInterceptors(svc interface{}, config CommonConfig, middlewares, options []string) ([]grpc.StreamServerInterceptor, error) {
 	opts := []grpc.StreamServerInterceptor{optionsStreamInterceptor}
+	if len(options) > 0 {
+		opts = append(opts, methodOptionsStreamInterceptor(options))
+	}
 
 	// check and add default interceptors
 	if !config.NoDefaultInterceptors {
@@ -143,7 +196,11 @@ func getStreamInterceptors(svc interface{}, config CommonConfig) []grpc.StreamSe
 		opts = append(opts, interceptor.GetStreamInterceptors()...)
 	}
 
-	return opts
+	// check and add method interceptors
+	methodInterceptors, err := getMethodStreamInterceptors(svc, middlewares)
+	opts = append(opts, methodInterceptors...)
+
+	return opts, err
 }
 
 func getMiddleware(svc interface{}, middleware string) (grpc.UnaryServerInterceptor, error) {
@@ -161,23 +218,72 @@ func getMiddleware(svc interface{}, middleware string) (grpc.UnaryServerIntercep
 	return nil, errors.New("could not find middleware " + middleware)
 }
 
-//GetMethodInterceptors fetches all interceptors including method middlewares
-func GetMethodInterceptors(svc interface{}, config CommonConfig, middlewares []string) []grpc.UnaryServerInterceptor {
+// getMethodInterceptors resolves method middlewares, the errors of all middlewares that cannot be resolved are combined
+func getMethodInterceptors(svc interface{}, middlewares []string) ([]grpc.UnaryServerInterceptor, error) {
 	interceptors := make([]grpc.UnaryServerInterceptor, 0)
+	problems := make([]string, 0)
 	for _, middleware := range middlewares {
 		interceptor, err := getMiddleware(svc, middleware)
 		if err != nil {
-			log.Error(context.Background(), "error", err, "middleware", "could not fetch middleware")
-			notifier.NotifyWithLevel(err, "critical")
-		} else {
-			if interceptor != nil {
-				interceptors = append(interceptors, interceptor)
+			problems = append(problems, err.Error())
+		} else if interceptor != nil {
+			interceptors = append(interceptors, interceptor)
+		}
+	}
+	return interceptors, combineErrors(problems)
+}
+
+//GetMethodInterceptors fetches all interceptors including method middlewares
+func GetMethodInterceptors(svc interface{}, config CommonConfig, middlewares []string) []grpc.UnaryServerInterceptor {
+	interceptors, err := getMethodInterceptors(svc, middlewares)
+	reportMiddlewareError(err)
+	return interceptors
+}
+
+func getStreamMiddleware(svc interface{}, middleware string) (grpc.StreamServerInterceptor, error) {
+	r := reflect.TypeOf(svc)
+	if m, ok := r.MethodByName(middleware); ok {
+		if m.Type.NumIn() == 1 && m.Type.NumOut() == 1 && !m.Type.IsVariadic() {
+			t := reflect.TypeOf(grpc.StreamServerInterceptor(nil))
+			if r.ConvertibleTo(m.Type.In(0)) && m.Type.Out(0).ConvertibleTo(t) {
+				v := m.Func.Call([]reflect.Value{reflect.ValueOf(svc)})
+				return v[0].Interface().(grpc.StreamServerInterceptor), nil
 			}
 		}
+		return nil, errors.New("stream middleware should be defined as 'func (" + r.String() + ") " + middleware + "() grpc.StreamServerInterceptor'")
+	}
+	return nil, errors.New("could not find middleware " + middleware)
+}
+
+// getMethodStreamInterceptors resolves method stream middlewares, the errors of all middlewares that cannot be resolved are combined
+func getMethodStreamInterceptors(svc interface{}, middlewares []string) ([]grpc.StreamServerInterceptor, error) {
+	interceptors := make([]grpc.StreamServerInterceptor, 0)
+	problems := make([]string, 0)
+	for _, middleware := range middlewares {
+		interceptor, err := getStreamMiddleware(svc, middleware)
+		if err != nil {
+			problems = append(problems, err.Error())
+		} else if interceptor != nil {
+			interceptors = append(interceptors, interceptor)
+		}
 	}
+	return interceptors, combineErrors(problems)
+}
+
+//GetMethodStreamInterceptors fetches all stream interceptors of method middlewares
+func GetMethodStreamInterceptors(svc interface{}, config CommonConfig, middlewares []string) []grpc.StreamServerInterceptor {
+	interceptors, err := getMethodStreamInterceptors(svc, middlewares)
+	reportMiddlewareError(err)
 	return interceptors
 }
 
+func combineErrors(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
 type streamServer struct {
 	grpc.ServerStream
 	ctx context.Context
@@ -201,6 +307,22 @@ func optionsStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.
 	return handler(srv, newServer)
 }
 
+// methodOptionsStreamInterceptor makes the method options available to stream middlewares
+func methodOptionsStreamInterceptor(opts []string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		modifiers.SetMethodOptions(ss.Context(), opts)
+		return handler(srv, ss)
+	}
+}
+
+// methodOptionsInterceptor makes the method options available to middlewares
+func methodOptionsInterceptor(opts []string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		modifiers.SetMethodOptions(ctx, opts)
+		return handler(ctx, req)
+	}
+}
+
 func optionsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	ctx = options.AddToOptions(ctx, "", "")
 	ctx = loggers.AddToLogContext(ctx, "grpcMethod", info.FullMethod)
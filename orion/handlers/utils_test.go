@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-orion/Orion/orion/modifiers"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// streamService records the stream interceptors called in calls
+type streamService struct {
+	calls *[]string
+}
+
+func (s streamService) record(name string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		*s.calls = append(*s.calls, name)
+		if opts := modifiers.GetMethodOptions(ss.Context()); len(opts) > 0 {
+			*s.calls = append(*s.calls, name+" "+strings.Join(opts, ","))
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (s streamService) GetStreamInterceptors() []grpc.StreamServerInterceptor {
+	return []grpc.StreamServerInterceptor{s.record("service")}
+}
+
+func (s streamService) Audit() grpc.StreamServerInterceptor {
+	return s.record("audit")
+}
+
+func (s streamService) Trace() grpc.StreamServerInterceptor {
+	return s.record("trace")
+}
+
+// Unary is a middleware for unary methods and cannot be used for streams
+func (s streamService) Unary() grpc.UnaryServerInterceptor {
+	return nil
+}
+
+// testServerStream is a server stream with a context only
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestBuildStreamInterceptors(t *testing.T) {
+	tests := []struct {
+		name        string
+		middlewares []string
+		options     []string
+		calls       []string
+		err         string
+	}{
+		{"service interceptors", nil, nil, []string{"service", "handler"}, ""},
+		{"method middlewares in order", []string{"Trace", "Audit"}, nil, []string{"service", "trace", "audit", "handler"}, ""},
+		{"method options", []string{"Audit"}, []string{"ETAG", "CODEC=PROTO"}, []string{"service", "service ETAG,CODEC=PROTO", "audit", "audit ETAG,CODEC=PROTO", "handler"}, ""},
+		{"missing middleware", []string{"Missing", "Audit"}, nil, []string{"service", "audit", "handler"}, "could not find middleware Missing"},
+		{"unary middleware", []string{"Unary"}, nil, []string{"service", "handler"}, "stream middleware should be defined as 'func (handlers.streamService) Unary() grpc.StreamServerInterceptor'"},
+		{"all problems", []string{"Missing", "Unary"}, nil, []string{"service", "handler"}, "could not find middleware Missing; stream middleware should be defined as"},
+	}
+	for _, test := range tests {
+		calls := make([]string, 0)
+		svc := streamService{calls: &calls}
+		interceptor, err := BuildStreamInterceptors(svc, CommonConfig{NoDefaultInterceptors: true}, test.middlewares, test.options)
+		if test.err == "" {
+			assert.NoError(t, err, test.name)
+		} else if assert.Error(t, err, test.name) {
+			assert.Contains(t, err.Error(), test.err, test.name)
+		}
+		handler := func(srv interface{}, ss grpc.ServerStream) error {
+			calls = append(calls, "handler")
+			return nil
+		}
+		ss := testServerStream{ctx: context.Background()}
+		assert.NoError(t, interceptor(svc, ss, &grpc.StreamServerInfo{FullMethod: "/test.StreamService/Split"}, handler), test.name)
+		assert.Equal(t, test.calls, calls, test.name)
+	}
+}
+
+func TestHasMethodOption(t *testing.T) {
+	tests := []struct {
+		options []string
+		option  string
+		ok      bool
+	}{
+		{nil, "ETAG", false},
+		{[]string{"ETAG"}, "etag", true},
+		{[]string{"CODEC=PROTO"}, "CODEC", true},
+		{[]string{"CODEC=PROTO"}, "codec=proto", true},
+		{[]string{"CODEC=PROTO"}, "CODE", false},
+		{[]string{"READ_TIMEOUT=5S", "ETAG"}, "WRITE_TIMEOUT", false},
+	}
+	for _, test := range tests {
+		var found bool
+		interceptor := methodOptionsStreamInterceptor(test.options)
+		ss := testServerStream{ctx: context.Background()}
+		optionsStreamInterceptor(nil, ss, &grpc.StreamServerInfo{}, func(srv interface{}, ss grpc.ServerStream) error {
+			return interceptor(srv, ss, &grpc.StreamServerInfo{}, func(srv interface{}, ss grpc.ServerStream) error {
+				found = modifiers.HasMethodOption(ss.Context(), test.option)
+				return nil
+			})
+		})
+		assert.Equal(t, test.ok, found, "%v %s", test.options, test.option)
+	}
+}
@@ -3,6 +3,7 @@ package orion
 import (
 	"context"
 	"errors"
+	"io"
 	"net"
 	"net/http"
 	_ "net/http/pprof" // import pprof
@@ -15,6 +16,7 @@ import (
 	"github.com/afex/hystrix-go/plugins"
 	logg "github.com/go-kit/kit/log"
 	"github.com/go-orion/Orion/utils"
+	"github.com/go-orion/Orion/utils/accesslog"
 	"github.com/go-orion/Orion/utils/errors/notifier"
 	"github.com/go-orion/Orion/utils/log"
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
@@ -33,6 +35,7 @@ var (
 		PrometheusInitializer(),
 		PprofInitializer(),
 		ErrorLoggingInitializer(),
+		AccessLogInitializer(),
 	}
 )
 
@@ -61,6 +64,11 @@ func PrometheusInitializer() Initializer {
 	return &prometheusInitializer{}
 }
 
+//AccessLogInitializer returns a Initializer implementation for access logs
+func AccessLogInitializer() Initializer {
+	return &accessLogInitializer{}
+}
+
 //PprofInitializer returns a Initializer implementation for Pprof
 func PprofInitializer() Initializer {
 	return &pprofInitializer{}
@@ -237,6 +245,58 @@ func (p *pprofInitializer) ReInit(svr Server) error {
 	return nil
 }
 
+type accessLogInitializer struct {
+	file *os.File
+}
+
+func (a *accessLogInitializer) Init(svr Server) error {
+	config := svr.GetOrionConfig().AccessLogConfig
+	if !config.Enabled {
+		accesslog.SetDefault(nil)
+		return nil
+	}
+	trustedProxies, err := accesslog.ParseNetworks(config.TrustedProxies)
+	if err != nil {
+		log.Error(context.Background(), "accessLog", "invalid trusted proxies", "error", err)
+		return err
+	}
+	sink := config.Sink
+	if sink == nil {
+		var w io.Writer
+		switch strings.ToLower(strings.TrimSpace(config.Output)) {
+		case "", "stdout":
+			w = os.Stdout
+		case "stderr":
+			w = os.Stderr
+		default:
+			f, err := os.OpenFile(config.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				log.Error(context.Background(), "accessLog", "could not open output", "error", err)
+				return err
+			}
+			a.file, w = f, f
+		}
+		sink = accesslog.NewJSONSink(w, config.Fields)
+	}
+	accesslog.SetDefault(&accesslog.Logger{
+		Sink:           sink,
+		Sampler:        accesslog.ErrorsAndRate(config.SampleRate),
+		TrustedProxies: trustedProxies,
+	})
+	log.Info(context.Background(), "accessLog", config.Output, "sampleRate", config.SampleRate)
+	return nil
+}
+
+func (a *accessLogInitializer) ReInit(svr Server) error {
+	file := a.file
+	a.file = nil
+	err := a.Init(svr)
+	if file != nil {
+		file.Close()
+	}
+	return err
+}
+
 type errorLoggingInitializer struct{}
 
 func (e *errorLoggingInitializer) Init(svr Server) error {
@@ -2,7 +2,12 @@ package modifiers
 
 import (
 	"context"
+	"net/http"
+	"strings"
+	"time"
 
+	"github.com/go-orion/Orion/utils/accesslog"
+	"github.com/go-orion/Orion/utils/headers"
 	"github.com/go-orion/Orion/utils/options"
 )
 
@@ -15,8 +20,24 @@ const (
 	JSONPB       = "JSONPB"
 	ProtoBuf     = "PROTO"
 	IgnoreError  = "IGNORE_ERROR"
+	bypassCache  = "OrionBypassCache"
+	invalidate   = "OrionInvalidateCache"
+	methodOpts   = "OrionMethodOptions"
 )
 
+// CacheInvalidation is a cached response to be deleted once the current method succeeds
+type CacheInvalidation struct {
+	// Method is the full grpc method name, e.g. '/pkg.Service/Method'
+	Method string
+	// Request is the request message of the cached response
+	Request interface{}
+}
+
+// SerializeOut forces the output to use the codec registered for the serialization type for http request
+func SerializeOut(ctx context.Context, serType string) {
+	options.AddToOptions(ctx, serializeOut, strings.ToUpper(serType))
+}
+
 // SerializeOutJSON forces the output to be json.Marshal for http request
 func SerializeOutJSON(ctx context.Context) {
 	options.AddToOptions(ctx, serializeOut, JSON)
@@ -67,3 +88,101 @@ func IsGRPCRequest(ctx context.Context) bool {
 	_, found := opt.Get(RequestGRPC)
 	return found
 }
+
+// SetCacheControl sets the Cache-Control header of the http response
+func SetCacheControl(ctx context.Context, value string) {
+	if hdr := headers.ResponseHeadersFromContext(ctx); hdr != nil {
+		hdr.Set("Cache-Control", value)
+	}
+}
+
+// AddVary adds request headers the http response varies on to the Vary header
+func AddVary(ctx context.Context, headerNames ...string) {
+	if hdr := headers.ResponseHeadersFromContext(ctx); hdr != nil {
+		for _, name := range headerNames {
+			hdr.Add("Vary", name)
+		}
+	}
+}
+
+// SetLastModified sets the Last-Modified header of the http response, conditional requests
+// with If-Modified-Since are answered with 304 when the response has not changed since
+func SetLastModified(ctx context.Context, t time.Time) {
+	if hdr := headers.ResponseHeadersFromContext(ctx); hdr != nil {
+		hdr.Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+	}
+}
+
+// SetETag sets the entity tag of the http response, it overrides the tag computed by the ETAG option
+func SetETag(ctx context.Context, tag string) {
+	if hdr := headers.ResponseHeadersFromContext(ctx); hdr != nil {
+		if !strings.HasPrefix(tag, "\"") && !strings.HasPrefix(tag, "W/") {
+			tag = "\"" + tag + "\""
+		}
+		hdr.Set("ETag", tag)
+	}
+}
+
+// BypassCache skips the response cache for this request, when called from the service the response is not cached
+func BypassCache(ctx context.Context) {
+	options.AddToOptions(ctx, bypassCache, true)
+}
+
+// IsCacheBypassed checks if the response cache should be skipped for this request
+func IsCacheBypassed(ctx context.Context) bool {
+	opt := options.FromContext(ctx)
+	_, found := opt.Get(bypassCache)
+	return found
+}
+
+// InvalidateCache deletes the cached response of method for req once the current method succeeds,
+// the request headers used in the cache key are taken from the current request
+func InvalidateCache(ctx context.Context, method string, req interface{}) {
+	invalidations := append(GetCacheInvalidations(ctx), CacheInvalidation{Method: method, Request: req})
+	options.AddToOptions(ctx, invalidate, invalidations)
+}
+
+// GetCacheInvalidations gets the cached responses to be deleted for the given request
+func GetCacheInvalidations(ctx context.Context) []CacheInvalidation {
+	opt := options.FromContext(ctx)
+	if val, found := opt.Get(invalidate); found {
+		if invalidations, ok := val.([]CacheInvalidation); ok {
+			return invalidations
+		}
+	}
+	return nil
+}
+
+// SetPrincipal records the authenticated principal of the request in the access log
+func SetPrincipal(ctx context.Context, principal string) {
+	if entry := accesslog.FromContext(ctx); entry != nil {
+		entry.Principal = principal
+	}
+}
+
+// SetMethodOptions records the options of the method serving the request, it is called by Orion handlers
+func SetMethodOptions(ctx context.Context, opts []string) {
+	options.AddToOptions(ctx, methodOpts, opts)
+}
+
+// GetMethodOptions gets the options of the method serving the request, as set by 'ORION:OPTION' annotations
+func GetMethodOptions(ctx context.Context) []string {
+	opt := options.FromContext(ctx)
+	if val, found := opt.Get(methodOpts); found {
+		if opts, ok := val.([]string); ok {
+			return opts
+		}
+	}
+	return nil
+}
+
+// HasMethodOption checks if the method serving the request has the option, options with values match on their name
+func HasMethodOption(ctx context.Context, option string) bool {
+	for _, opt := range GetMethodOptions(ctx) {
+		name := strings.TrimSpace(strings.SplitN(opt, "=", 2)[0])
+		if strings.EqualFold(opt, option) || strings.EqualFold(name, option) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,343 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: orion/options/options.proto
+
+package options
+
+import (
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	descriptor "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+// HTTPRule binds a method to an HTTP route, it is the equivalent of the 'ORION:URL:' annotation
+type HTTPRule struct {
+	// methods served by the route, e.g. ["GET", "POST"], POST is used when empty
+	Methods []string `protobuf:"bytes,1,rep,name=methods,proto3" json:"methods,omitempty"`
+	// path of the route, it can contain route variables, e.g. "/api/1.0/upper/{msg}", the generated url is used when empty
+	Path                 string   `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *HTTPRule) Reset()         { *m = HTTPRule{} }
+func (m *HTTPRule) String() string { return proto.CompactTextString(m) }
+func (*HTTPRule) ProtoMessage()    {}
+func (*HTTPRule) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b4aeb8c6bb19813, []int{0}
+}
+
+func (m *HTTPRule) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HTTPRule.Unmarshal(m, b)
+}
+func (m *HTTPRule) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HTTPRule.Marshal(b, m, deterministic)
+}
+func (m *HTTPRule) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HTTPRule.Merge(m, src)
+}
+func (m *HTTPRule) XXX_Size() int {
+	return xxx_messageInfo_HTTPRule.Size(m)
+}
+func (m *HTTPRule) XXX_DiscardUnknown() {
+	xxx_messageInfo_HTTPRule.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HTTPRule proto.InternalMessageInfo
+
+func (m *HTTPRule) GetMethods() []string {
+	if m != nil {
+		return m.Methods
+	}
+	return nil
+}
+
+func (m *HTTPRule) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+// Auth selects the middlewares authenticating the calls of a method, they run before the other middlewares
+type Auth struct {
+	// middlewares are the names of the authenticating middlewares
+	Middlewares []string `protobuf:"bytes,1,rep,name=middlewares,proto3" json:"middlewares,omitempty"`
+	// skip disables the authentication configured on the service for a method
+	Skip                 bool     `protobuf:"varint,2,opt,name=skip,proto3" json:"skip,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Auth) Reset()         { *m = Auth{} }
+func (m *Auth) String() string { return proto.CompactTextString(m) }
+func (*Auth) ProtoMessage()    {}
+func (*Auth) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b4aeb8c6bb19813, []int{1}
+}
+
+func (m *Auth) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Auth.Unmarshal(m, b)
+}
+func (m *Auth) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Auth.Marshal(b, m, deterministic)
+}
+func (m *Auth) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Auth.Merge(m, src)
+}
+func (m *Auth) XXX_Size() int {
+	return xxx_messageInfo_Auth.Size(m)
+}
+func (m *Auth) XXX_DiscardUnknown() {
+	xxx_messageInfo_Auth.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Auth proto.InternalMessageInfo
+
+func (m *Auth) GetMiddlewares() []string {
+	if m != nil {
+		return m.Middlewares
+	}
+	return nil
+}
+
+func (m *Auth) GetSkip() bool {
+	if m != nil {
+		return m.Skip
+	}
+	return false
+}
+
+// MethodOptions are the Orion options of a method
+type MethodOptions struct {
+	// http binds the method to an HTTP route
+	Http *HTTPRule `protobuf:"bytes,1,opt,name=http,proto3" json:"http,omitempty"`
+	// options of the method, e.g. "DEPRECATED", they are the equivalent of 'ORION:OPTION:' annotations
+	Options []string `protobuf:"bytes,2,rep,name=options,proto3" json:"options,omitempty"`
+	// middlewares of the method, they are the equivalent of 'ORION:MIDDLEWARE:' annotations
+	Middlewares []string `protobuf:"bytes,3,rep,name=middlewares,proto3" json:"middlewares,omitempty"`
+	// read_timeout overrides the server read timeout for the method, e.g. "5s"
+	ReadTimeout string `protobuf:"bytes,4,opt,name=read_timeout,json=readTimeout,proto3" json:"read_timeout,omitempty"`
+	// write_timeout overrides the server write timeout for the method, e.g. "10s"
+	WriteTimeout string `protobuf:"bytes,5,opt,name=write_timeout,json=writeTimeout,proto3" json:"write_timeout,omitempty"`
+	// auth overrides the authentication configured on the service
+	Auth                 *Auth    `protobuf:"bytes,6,opt,name=auth,proto3" json:"auth,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MethodOptions) Reset()         { *m = MethodOptions{} }
+func (m *MethodOptions) String() string { return proto.CompactTextString(m) }
+func (*MethodOptions) ProtoMessage()    {}
+func (*MethodOptions) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b4aeb8c6bb19813, []int{2}
+}
+
+func (m *MethodOptions) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MethodOptions.Unmarshal(m, b)
+}
+func (m *MethodOptions) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MethodOptions.Marshal(b, m, deterministic)
+}
+func (m *MethodOptions) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MethodOptions.Merge(m, src)
+}
+func (m *MethodOptions) XXX_Size() int {
+	return xxx_messageInfo_MethodOptions.Size(m)
+}
+func (m *MethodOptions) XXX_DiscardUnknown() {
+	xxx_messageInfo_MethodOptions.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MethodOptions proto.InternalMessageInfo
+
+func (m *MethodOptions) GetHttp() *HTTPRule {
+	if m != nil {
+		return m.Http
+	}
+	return nil
+}
+
+func (m *MethodOptions) GetOptions() []string {
+	if m != nil {
+		return m.Options
+	}
+	return nil
+}
+
+func (m *MethodOptions) GetMiddlewares() []string {
+	if m != nil {
+		return m.Middlewares
+	}
+	return nil
+}
+
+func (m *MethodOptions) GetReadTimeout() string {
+	if m != nil {
+		return m.ReadTimeout
+	}
+	return ""
+}
+
+func (m *MethodOptions) GetWriteTimeout() string {
+	if m != nil {
+		return m.WriteTimeout
+	}
+	return ""
+}
+
+func (m *MethodOptions) GetAuth() *Auth {
+	if m != nil {
+		return m.Auth
+	}
+	return nil
+}
+
+// ServiceOptions are the Orion options of a service, they apply to all methods of the service
+type ServiceOptions struct {
+	// options of all methods, e.g. "DEPRECATED"
+	Options []string `protobuf:"bytes,1,rep,name=options,proto3" json:"options,omitempty"`
+	// middlewares of all methods, they run before the middlewares of a method
+	Middlewares []string `protobuf:"bytes,2,rep,name=middlewares,proto3" json:"middlewares,omitempty"`
+	// read_timeout overrides the server read timeout for all methods, e.g. "5s"
+	ReadTimeout string `protobuf:"bytes,3,opt,name=read_timeout,json=readTimeout,proto3" json:"read_timeout,omitempty"`
+	// write_timeout overrides the server write timeout for all methods, e.g. "10s"
+	WriteTimeout string `protobuf:"bytes,4,opt,name=write_timeout,json=writeTimeout,proto3" json:"write_timeout,omitempty"`
+	// auth authenticates the calls of all methods
+	Auth                 *Auth    `protobuf:"bytes,5,opt,name=auth,proto3" json:"auth,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ServiceOptions) Reset()         { *m = ServiceOptions{} }
+func (m *ServiceOptions) String() string { return proto.CompactTextString(m) }
+func (*ServiceOptions) ProtoMessage()    {}
+func (*ServiceOptions) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b4aeb8c6bb19813, []int{3}
+}
+
+func (m *ServiceOptions) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ServiceOptions.Unmarshal(m, b)
+}
+func (m *ServiceOptions) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ServiceOptions.Marshal(b, m, deterministic)
+}
+func (m *ServiceOptions) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ServiceOptions.Merge(m, src)
+}
+func (m *ServiceOptions) XXX_Size() int {
+	return xxx_messageInfo_ServiceOptions.Size(m)
+}
+func (m *ServiceOptions) XXX_DiscardUnknown() {
+	xxx_messageInfo_ServiceOptions.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ServiceOptions proto.InternalMessageInfo
+
+func (m *ServiceOptions) GetOptions() []string {
+	if m != nil {
+		return m.Options
+	}
+	return nil
+}
+
+func (m *ServiceOptions) GetMiddlewares() []string {
+	if m != nil {
+		return m.Middlewares
+	}
+	return nil
+}
+
+func (m *ServiceOptions) GetReadTimeout() string {
+	if m != nil {
+		return m.ReadTimeout
+	}
+	return ""
+}
+
+func (m *ServiceOptions) GetWriteTimeout() string {
+	if m != nil {
+		return m.WriteTimeout
+	}
+	return ""
+}
+
+func (m *ServiceOptions) GetAuth() *Auth {
+	if m != nil {
+		return m.Auth
+	}
+	return nil
+}
+
+var E_Method = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*MethodOptions)(nil),
+	Field:         51177,
+	Name:          "orion.method",
+	Tag:           "bytes,51177,opt,name=method",
+	Filename:      "orion/options/options.proto",
+}
+
+var E_Service = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.ServiceOptions)(nil),
+	ExtensionType: (*ServiceOptions)(nil),
+	Field:         51177,
+	Name:          "orion.service",
+	Tag:           "bytes,51177,opt,name=service",
+	Filename:      "orion/options/options.proto",
+}
+
+func init() {
+	proto.RegisterType((*HTTPRule)(nil), "orion.HTTPRule")
+	proto.RegisterType((*Auth)(nil), "orion.Auth")
+	proto.RegisterType((*MethodOptions)(nil), "orion.MethodOptions")
+	proto.RegisterType((*ServiceOptions)(nil), "orion.ServiceOptions")
+	proto.RegisterExtension(E_Method)
+	proto.RegisterExtension(E_Service)
+}
+
+func init() { proto.RegisterFile("orion/options/options.proto", fileDescriptor_0b4aeb8c6bb19813) }
+
+var fileDescriptor_0b4aeb8c6bb19813 = []byte{
+	// 391 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x93, 0x4f, 0x4e, 0xe3, 0x30,
+	0x14, 0xc6, 0x95, 0x26, 0xfd, 0xe7, 0xb4, 0x33, 0x92, 0x35, 0x23, 0x59, 0x33, 0xd2, 0x34, 0x93,
+	0x6e, 0xba, 0x99, 0x44, 0x33, 0xb3, 0x41, 0x85, 0x0d, 0xac, 0xd8, 0x40, 0x91, 0xe9, 0x8a, 0x0d,
+	0x4a, 0x1b, 0x93, 0x58, 0x34, 0x75, 0xe4, 0x38, 0xf4, 0x18, 0x9c, 0x86, 0xc3, 0x70, 0x04, 0x6e,
+	0x81, 0xf2, 0x1c, 0x57, 0x0d, 0x15, 0x85, 0x4d, 0x6c, 0xbf, 0xef, 0xf9, 0xcb, 0xef, 0x3d, 0xdb,
+	0xe8, 0xa7, 0x90, 0x5c, 0xac, 0x43, 0x91, 0x2b, 0x2e, 0xd6, 0x85, 0x19, 0x83, 0x5c, 0x0a, 0x25,
+	0x70, 0x1b, 0xc4, 0x1f, 0x5e, 0x22, 0x44, 0xb2, 0x62, 0x21, 0x04, 0x17, 0xe5, 0x5d, 0x18, 0xb3,
+	0x62, 0x29, 0x79, 0xae, 0x84, 0xd4, 0x89, 0xfe, 0x11, 0xea, 0x9d, 0xcf, 0xe7, 0x57, 0xb4, 0x5c,
+	0x31, 0x4c, 0x50, 0x37, 0x63, 0x2a, 0x15, 0x71, 0x41, 0x2c, 0xcf, 0x9e, 0xf4, 0xa9, 0x59, 0x62,
+	0x8c, 0x9c, 0x3c, 0x52, 0x29, 0x69, 0x79, 0xd6, 0xa4, 0x4f, 0x61, 0xee, 0x9f, 0x20, 0xe7, 0xb4,
+	0x54, 0x29, 0xf6, 0x90, 0x9b, 0xf1, 0x38, 0x5e, 0xb1, 0x4d, 0x24, 0x99, 0xd9, 0xb9, 0x1b, 0xaa,
+	0x76, 0x17, 0xf7, 0x3c, 0x87, 0xdd, 0x3d, 0x0a, 0x73, 0xff, 0xd9, 0x42, 0xc3, 0x0b, 0x70, 0x9f,
+	0x69, 0x70, 0x3c, 0x46, 0x4e, 0xaa, 0x54, 0x4e, 0x2c, 0xcf, 0x9a, 0xb8, 0xff, 0xbe, 0x06, 0x50,
+	0x41, 0x60, 0xe0, 0x28, 0x88, 0x15, 0x62, 0x5d, 0x28, 0x69, 0x69, 0xc4, 0x7a, 0xf9, 0x16, 0xc3,
+	0xde, 0xc7, 0xf8, 0x8d, 0x06, 0x92, 0x45, 0xf1, 0xad, 0xe2, 0x19, 0x13, 0xa5, 0x22, 0x0e, 0x14,
+	0xe3, 0x56, 0xb1, 0xb9, 0x0e, 0xe1, 0x31, 0x1a, 0x6e, 0x24, 0x57, 0x6c, 0x9b, 0xd3, 0x86, 0x9c,
+	0x01, 0x04, 0x4d, 0xd2, 0x08, 0x39, 0x51, 0xa9, 0x52, 0xd2, 0x01, 0x50, 0xb7, 0x06, 0xad, 0x7a,
+	0x41, 0x41, 0xf0, 0x9f, 0x2c, 0xf4, 0xe5, 0x9a, 0xc9, 0x07, 0xbe, 0x64, 0xa6, 0xb8, 0x1d, 0x6e,
+	0xeb, 0x20, 0x77, 0xeb, 0x63, 0x6e, 0xfb, 0x13, 0xdc, 0xce, 0x01, 0xee, 0xf6, 0x3b, 0xdc, 0xd3,
+	0x4b, 0xd4, 0xd1, 0x07, 0x8e, 0x7f, 0x05, 0xfa, 0xe2, 0x04, 0xe6, 0xe2, 0x04, 0x8d, 0xb3, 0x22,
+	0x2f, 0x8f, 0x36, 0x98, 0x7c, 0xab, 0x4d, 0x1a, 0x2a, 0xad, 0x5d, 0xa6, 0x14, 0x75, 0x0b, 0xdd,
+	0x06, 0x3c, 0xda, 0x33, 0x6c, 0x36, 0x68, 0xeb, 0xf8, 0xbd, 0x76, 0x6c, 0xca, 0xd4, 0x18, 0x9d,
+	0xfd, 0xbd, 0x09, 0x13, 0xae, 0xd2, 0x72, 0x11, 0x2c, 0x45, 0x16, 0x26, 0xe2, 0x8f, 0x7e, 0x05,
+	0x33, 0xf8, 0x36, 0x5e, 0xc4, 0x71, 0x3d, 0x2e, 0x3a, 0xf0, 0xcf, 0xff, 0xaf, 0x03, 0x00, 0x3b,
+	0x31, 0x65, 0xef, 0x31, 0x03, 0x00, 0x00,
+}
@@ -3,7 +3,9 @@ package orion
 import (
 	"time"
 
+	"github.com/go-orion/Orion/orion/client"
 	"github.com/go-orion/Orion/orion/handlers"
+	"github.com/go-orion/Orion/utils/cache"
 	"google.golang.org/grpc"
 )
 
@@ -23,11 +25,11 @@ const (
 // Server is the interface that needs to be implemented by any orion server
 // 'DefaultServerImpl' should be enough for most users.
 type Server interface {
-	//Start starts the orion server, this is non blocking call
-	Start()
+	//Start starts the orion server, this is non blocking call, it returns an error when the server does not serve
+	Start() error
 	//RegisterService registers the service to origin server
 	RegisterService(sd *grpc.ServiceDesc, sf interface{}) error
-	//Wait waits for the Server loop to exit
+	//Wait waits for the Server loop to exit, it returns the reason Start did not serve, e.g. an invalid route table
 	Wait() error
 	//Stop stops the Server
 	Stop(timeout time.Duration) error
@@ -39,6 +41,12 @@ type Server interface {
 	AddInitializers(ins ...Initializer)
 }
 
+//ClientProvider is the interface implemented by servers that manage client connections to other services
+type ClientProvider interface {
+	GetClientConn(name string) (*grpc.ClientConn, error)
+	GetClientInvoker(name string) (ClientInvoker, error)
+}
+
 //Initializer is the interface needed to be implemented by custom initializers
 type Initializer interface {
 	Init(svr Server) error
@@ -79,3 +87,30 @@ type Decoder = handlers.Decoder
 
 //HTTPHandler is the http interceptor
 type HTTPHandler = handlers.HTTPHandler
+
+//WSUpgrader is the function type needed for websocket upgraders
+type WSUpgrader = handlers.WSUpgrader
+
+//WSConfig is the configuration used for websocket connections
+type WSConfig = handlers.WSConfig
+
+//CodecOptions are the options used by codecs when serializing HTTP responses
+type CodecOptions = handlers.CodecOptions
+
+//CacheStore is the interface implemented by response cache backends
+type CacheStore = cache.Store
+
+//AdminAuthFunc authorizes calls to admin services like gRPC reflection and channelz
+type AdminAuthFunc = handlers.AdminAuthFunc
+
+//GRPCServerOption is an option passed to grpc.NewServer
+type GRPCServerOption = grpc.ServerOption
+
+//ClientConfig is the configuration of client connections to a service
+type ClientConfig = client.Config
+
+//ClientInvoker calls a service over the transport in its client configuration, it is used by generated Orion clients
+type ClientInvoker = client.Invoker
+
+//FileSink is the function type needed for receiving multipart file uploads
+type FileSink = handlers.FileSink
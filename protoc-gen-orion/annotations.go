@@ -0,0 +1,405 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-orion/Orion/orion/options"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// annotations are the Orion annotations of a service or a method, they are read from 'ORION:' comments
+// and from the options defined in orion/options/options.proto
+type annotations struct {
+	infos []*commentsInfo
+	// auth is the authentication of orion/options/options.proto, nil when it is not set
+	auth *options.Auth
+}
+
+// fileAnnotations holds the annotations of the services and methods of a file by source code path, e.g. '6,0' for
+// the first service and '6,0,2,1' for its second method
+type fileAnnotations map[string]*annotations
+
+func (f fileAnnotations) get(path string) *annotations {
+	if a, ok := f[path]; ok {
+		return a
+	}
+	return &annotations{}
+}
+
+// methodInfos returns the annotations applied to a method, the authentication middlewares run first followed by
+// the middlewares of the service and of the method
+func (f fileAnnotations) methodInfos(svcPath, methodPath string) []*commentsInfo {
+	svc, method := f.get(svcPath), f.get(methodPath)
+	infos := make([]*commentsInfo, 0)
+	auth := svc.auth
+	if method.auth != nil {
+		auth = method.auth
+	}
+	if auth != nil && !auth.GetSkip() && len(auth.GetMiddlewares()) > 0 {
+		infos = append(infos, &commentsInfo{
+			Middleware: true,
+			Value:      strings.Join(auth.GetMiddlewares(), ","),
+		})
+	}
+	infos = append(infos, svc.infos...)
+	return append(infos, method.infos...)
+}
+
+var (
+	httpMethods = map[string]bool{
+		"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "OPTIONS": true, "HEAD": true,
+	}
+	// flagOptions are the options of the HTTP handler that take no value
+	flagOptions = map[string]bool{
+		optionIgnoreNR:      true,
+		optionNoCompression: true,
+		optionETag:          true,
+		optionDeprecated:    true,
+	}
+	// codecOptions are the options of the JSONPB codec, they are flags that can be set to TRUE or FALSE to override
+	// the options of the server
+	codecOptions = map[string]bool{
+		optionEmitDefaults: true,
+		optionOrigName:     true,
+		optionEnumsAsInts:  true,
+	}
+	// valueOptions validate the values of the options of the HTTP handler that take a value
+	valueOptions = map[string]func(string) error{
+		optionMaxBodySize:  validateSize,
+		optionCodec:        validateNotEmpty,
+		optionReadTimeout:  validateDuration,
+		optionWriteTimeout: validateDuration,
+	}
+	// other options are passed to services, see modifiers.GetMethodOptions
+	customOption   = regexp.MustCompile(`^[A-Z][A-Z0-9_]*(=\S+)?$`)
+	middlewareName = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+	headerName     = regexp.MustCompile(`^[A-Za-z0-9\-]+$`)
+	sizeValue      = regexp.MustCompile(`^[0-9]+\s*(GB|MB|KB|B)?$`)
+)
+
+// readAnnotations reads the annotations of all services and methods of a file, unknown and malformed annotations
+// are reported as errors
+func readAnnotations(file *descriptor.FileDescriptorProto) (fileAnnotations, error) {
+	comments := extractComments(file)
+	result := make(fileAnnotations)
+	errs := make([]string, 0)
+	report := func(path string, err error) {
+		if line := sourceLine(file, path); line > 0 {
+			errs = append(errs, fmt.Sprintf("%s:%d: %s", file.GetName(), line, err))
+			return
+		}
+		errs = append(errs, fmt.Sprintf("%s: %s", file.GetName(), err))
+	}
+	for index, svc := range file.GetService() {
+		path := fmt.Sprintf("6,%d", index) // 6 means service.
+		a := new(annotations)
+		for _, option := range commentAnnotations(comments, path, func(err error) { report(path, err) }) {
+			if option.Encoder || !(option.Option || option.Middleware) {
+				report(path, fmt.Errorf("service %s: ORION:URL is only supported on methods", svc.GetName()))
+				continue
+			}
+			a.infos = append(a.infos, option)
+		}
+		if err := serviceOptions(svc.GetOptions(), a); err != nil {
+			report(path, fmt.Errorf("service %s: %s", svc.GetName(), err))
+		}
+		result[path] = a
+
+		for i, method := range svc.GetMethod() {
+			methodPath := fmt.Sprintf("%s,2,%d", path, i) // 2 means method in a service.
+			m := new(annotations)
+			m.infos = commentAnnotations(comments, methodPath, func(err error) { report(methodPath, err) })
+			if err := methodOptions(method.GetOptions(), m); err != nil {
+				report(methodPath, fmt.Errorf("method %s: %s", method.GetName(), err))
+			}
+			result[methodPath] = m
+			if err := validateCodecOptions(result.methodInfos(path, methodPath)); err != nil {
+				report(methodPath, fmt.Errorf("method %s: %s", method.GetName(), err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return result, nil
+}
+
+// commentAnnotations parses the 'ORION:' annotations in the leading comments of a service or method
+func commentAnnotations(comments map[string]*descriptor.SourceCodeInfo_Location, path string, report func(error)) []*commentsInfo {
+	infos := make([]*commentsInfo, 0)
+	loc, ok := comments[path]
+	if !ok {
+		return infos
+	}
+	text := strings.TrimSuffix(loc.GetLeadingComments(), "\n")
+	for _, line := range strings.Split(text, "\n") {
+		option, err := parseComments(line)
+		if err != nil {
+			report(err)
+			continue
+		}
+		if option != nil {
+			infos = append(infos, option)
+		}
+	}
+	return infos
+}
+
+// serviceOptions converts the orion.service option of a service to annotations
+func serviceOptions(opts *descriptor.ServiceOptions, a *annotations) error {
+	if opts == nil || !proto.HasExtension(opts, options.E_Service) {
+		return nil
+	}
+	ext, err := proto.GetExtension(opts, options.E_Service)
+	if err != nil {
+		return fmt.Errorf("invalid orion.service option: %s", err)
+	}
+	svc := ext.(*options.ServiceOptions)
+	if err := appendOptions(a, svc.GetOptions(), svc.GetMiddlewares(), svc.GetReadTimeout(), svc.GetWriteTimeout()); err != nil {
+		return err
+	}
+	a.auth = svc.GetAuth()
+	return validateAuth(a.auth)
+}
+
+// methodOptions converts the orion.method option of a method to annotations
+func methodOptions(opts *descriptor.MethodOptions, a *annotations) error {
+	if opts == nil || !proto.HasExtension(opts, options.E_Method) {
+		return nil
+	}
+	ext, err := proto.GetExtension(opts, options.E_Method)
+	if err != nil {
+		return fmt.Errorf("invalid orion.method option: %s", err)
+	}
+	method := ext.(*options.MethodOptions)
+	if rule := method.GetHttp(); rule != nil {
+		methods := make([]string, 0)
+		for _, m := range rule.GetMethods() {
+			methods = append(methods, strings.ToUpper(strings.TrimSpace(m)))
+		}
+		if len(methods) == 0 {
+			methods = append(methods, "POST")
+		}
+		info := &commentsInfo{
+			Method:  strings.Join(methods, "/"),
+			Path:    strings.TrimSpace(rule.GetPath()),
+			Encoder: true,
+			Decoder: true,
+		}
+		if err := validateURL(info); err != nil {
+			return err
+		}
+		a.infos = append(a.infos, info)
+	}
+	if err := appendOptions(a, method.GetOptions(), method.GetMiddlewares(), method.GetReadTimeout(), method.GetWriteTimeout()); err != nil {
+		return err
+	}
+	a.auth = method.GetAuth()
+	return validateAuth(a.auth)
+}
+
+func appendOptions(a *annotations, values, middlewares []string, readTimeout, writeTimeout string) error {
+	values = append([]string{}, values...)
+	if readTimeout != "" {
+		values = append(values, optionReadTimeout+"="+readTimeout)
+	}
+	if writeTimeout != "" {
+		values = append(values, optionWriteTimeout+"="+writeTimeout)
+	}
+	for _, value := range values {
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if err := validateOption(value); err != nil {
+			return err
+		}
+		a.infos = append(a.infos, &commentsInfo{
+			Option: true,
+			Value:  value,
+		})
+	}
+	if len(middlewares) > 0 {
+		info := &commentsInfo{
+			Middleware: true,
+			Value:      strings.Join(middlewares, ","),
+		}
+		if err := validateMiddlewares(info.Value); err != nil {
+			return err
+		}
+		a.infos = append(a.infos, info)
+	}
+	return nil
+}
+
+func validateAuth(auth *options.Auth) error {
+	if auth == nil || len(auth.GetMiddlewares()) == 0 {
+		return nil
+	}
+	if auth.GetSkip() {
+		return fmt.Errorf("auth sets both skip and middlewares")
+	}
+	return validateMiddlewares(strings.Join(auth.GetMiddlewares(), ","))
+}
+
+// validateURL checks the methods and path of an 'ORION:URL' annotation, e.g. 'GET/POST /api/1.0/upper/{msg}'
+func validateURL(info *commentsInfo) error {
+	if strings.TrimSpace(info.Method) == "" {
+		return fmt.Errorf("ORION:URL without HTTP method")
+	}
+	for _, method := range strings.Split(info.Method, "/") {
+		if !httpMethods[strings.TrimSpace(method)] {
+			return fmt.Errorf("ORION:URL: unknown HTTP method '%s'", method)
+		}
+	}
+	path := strings.TrimSpace(info.Path)
+	if path == "" {
+		return nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("ORION:URL: path '%s' does not start with '/'", path)
+	}
+	depth := 0
+	for _, c := range path {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+		if depth < 0 {
+			return fmt.Errorf("ORION:URL: unbalanced braces in path '%s'", path)
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("ORION:URL: unbalanced braces in path '%s'", path)
+	}
+	return nil
+}
+
+// validateOption checks an 'ORION:OPTION' value, options of the HTTP handler are checked by name and value
+func validateOption(value string) error {
+	name, optionValue := value, ""
+	hasValue := false
+	if i := strings.Index(value, "="); i >= 0 {
+		name, optionValue, hasValue = value[:i], value[i+1:], true
+	}
+	switch {
+	case value == "":
+		return fmt.Errorf("ORION:OPTION without value")
+	case strings.Fields(value)[0] == optionCache:
+		return validateCache(value)
+	case flagOptions[name]:
+		if hasValue {
+			return fmt.Errorf("ORION:OPTION: %s does not take a value", name)
+		}
+	case codecOptions[name]:
+		if _, err := strconv.ParseBool(optionValue); hasValue && err != nil {
+			return fmt.Errorf("ORION:OPTION: invalid %s value '%s', expected TRUE or FALSE", name, optionValue)
+		}
+	case valueOptions[name] != nil:
+		if !hasValue {
+			return fmt.Errorf("ORION:OPTION: %s requires a value, e.g. %s=<value>", name, name)
+		}
+		if err := valueOptions[name](optionValue); err != nil {
+			return fmt.Errorf("ORION:OPTION: invalid %s value '%s': %s", name, optionValue, err)
+		}
+	case !customOption.MatchString(value):
+		return fmt.Errorf("ORION:OPTION: malformed option '%s'", value)
+	}
+	return nil
+}
+
+// validateCodecOptions checks that the codec options applied to a method are not combined with a codec other than
+// JSONPB, the other codecs do not support them
+func validateCodecOptions(infos []*commentsInfo) error {
+	codec, codecOption := "", ""
+	for _, info := range infos {
+		if !info.Option {
+			continue
+		}
+		name, value := strings.ToUpper(strings.TrimSpace(info.Value)), ""
+		if i := strings.Index(name, "="); i >= 0 {
+			name, value = name[:i], strings.TrimSpace(name[i+1:])
+		}
+		switch {
+		case name == optionCodec:
+			codec = value
+		case codecOptions[name]:
+			codecOption = name
+		}
+	}
+	if codecOption != "" && codec != "" && codec != "JSONPB" {
+		return fmt.Errorf("ORION:OPTION: %s is only supported by CODEC=JSONPB, not CODEC=%s", codecOption, codec)
+	}
+	return nil
+}
+
+// validateCache checks a CACHE option, e.g. 'CACHE TTL=30S HEADERS=X-USER-ID,ACCEPT-LANGUAGE'
+func validateCache(value string) error {
+	for _, field := range strings.Fields(value)[1:] {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("ORION:OPTION: CACHE: malformed setting '%s', e.g. TTL=30S", field)
+		}
+		switch parts[0] {
+		case "TTL":
+			if err := validateDuration(parts[1]); err != nil {
+				return fmt.Errorf("ORION:OPTION: CACHE: invalid TTL '%s': %s", parts[1], err)
+			}
+		case "HEADERS":
+			for _, hdr := range strings.Split(parts[1], ",") {
+				if !headerName.MatchString(hdr) {
+					return fmt.Errorf("ORION:OPTION: CACHE: malformed header name '%s'", hdr)
+				}
+			}
+		default:
+			return fmt.Errorf("ORION:OPTION: CACHE: unknown setting '%s', want TTL or HEADERS", parts[0])
+		}
+	}
+	return nil
+}
+
+func validateMiddlewares(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); !middlewareName.MatchString(name) {
+			return fmt.Errorf("ORION:MIDDLEWARE: malformed middleware name '%s'", name)
+		}
+	}
+	return nil
+}
+
+func validateSize(value string) error {
+	if !sizeValue.MatchString(value) {
+		return fmt.Errorf("expected a size, e.g. 10MB")
+	}
+	return nil
+}
+
+func validateDuration(value string) error {
+	_, err := time.ParseDuration(strings.ToLower(value))
+	return err
+}
+
+func validateNotEmpty(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("empty value")
+	}
+	return nil
+}
+
+// sourceLine returns the line of the element at path in the proto file, 0 when there is no source info
+func sourceLine(file *descriptor.FileDescriptorProto, path string) int32 {
+	for _, loc := range file.GetSourceCodeInfo().GetLocation() {
+		var p []string
+		for _, n := range loc.Path {
+			p = append(p, fmt.Sprint(n))
+		}
+		if strings.Join(p, ",") == path && len(loc.Span) > 0 {
+			return loc.Span[0] + 1
+		}
+	}
+	return 0
+}
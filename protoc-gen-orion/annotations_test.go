@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOption(t *testing.T) {
+	tests := []struct {
+		value string
+		err   bool
+	}{
+		{"DEPRECATED", false},
+		{"DEPRECATED=TRUE", true},
+		{"MAX_BODY_SIZE=10MB", false},
+		{"MAX_BODY_SIZE", true},
+		{"MAX_BODY_SIZE=TEN", true},
+		{"READ_TIMEOUT=5S", false},
+		{"READ_TIMEOUT=SOON", true},
+		{"CODEC=JSONPB", false},
+		{"EMIT_DEFAULTS", false},
+		{"EMIT_DEFAULTS=FALSE", false},
+		{"ORIG_NAME=TRUE", false},
+		{"ENUMS_AS_INTS=MAYBE", true},
+		{"CACHE", false},
+		{"CACHE TTL=30S HEADERS=X-USER-ID,ACCEPT-LANGUAGE", false},
+		{"CACHE TTL=NEVER", true},
+		{"CACHE HEADERS=X-USER-ID,", true},
+		{"CACHE SIZE=10", true},
+		{"CACHE 30S", true},
+		{"CUSTOM_OPTION", false},
+		{"CUSTOM_OPTION=VALUE", false},
+		{"custom option", true},
+		{"", true},
+	}
+	for _, test := range tests {
+		err := validateOption(test.value)
+		assert.Equal(t, test.err, err != nil, "%s: %v", test.value, err)
+	}
+}
+
+func TestValidateCodecOptions(t *testing.T) {
+	tests := []struct {
+		options []string
+		err     bool
+	}{
+		{[]string{"EMIT_DEFAULTS"}, false},
+		{[]string{"CODEC=JSONPB", "ORIG_NAME=FALSE"}, false},
+		{[]string{"CODEC=JSON"}, false},
+		{[]string{"CODEC=JSON", "EMIT_DEFAULTS"}, true},
+		{[]string{"ENUMS_AS_INTS", "CODEC=PROTOBUF"}, true},
+	}
+	for _, test := range tests {
+		infos := make([]*commentsInfo, 0)
+		for _, option := range test.options {
+			infos = append(infos, &commentsInfo{Option: true, Value: option})
+		}
+		err := validateCodecOptions(infos)
+		assert.Equal(t, test.err, err != nil, "%v: %v", test.options, err)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/format"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-orion/Orion/orion/options"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// testComment returns the source info of a leading comment of the element at path
+func testComment(comment string, line int32, path ...int32) *descriptor.SourceCodeInfo_Location {
+	return &descriptor.SourceCodeInfo_Location{
+		Path:            path,
+		Span:            []int32{line, 0, 0},
+		LeadingComments: proto.String(comment),
+	}
+}
+
+func testField(name string, number int32, typ descriptor.FieldDescriptorProto_Type, typeName string) *descriptor.FieldDescriptorProto {
+	f := &descriptor.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     typ.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+func testMethod(name, input, output string, clientStream, serverStream bool) *descriptor.MethodDescriptorProto {
+	return &descriptor.MethodDescriptorProto{
+		Name:            proto.String(name),
+		InputType:       proto.String(input),
+		OutputType:      proto.String(output),
+		ClientStreaming: proto.Bool(clientStream),
+		ServerStreaming: proto.Bool(serverStream),
+	}
+}
+
+// testCommonFile is imported by testEchoFile, it has no services and is never generated
+func testCommonFile() *descriptor.FileDescriptorProto {
+	return &descriptor.FileDescriptorProto{
+		Name:    proto.String("common/types.proto"),
+		Package: proto.String("common"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptor.FileOptions{GoPackage: proto.String("github.com/example/common;common")},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name:  proto.String("Ref"),
+				Field: []*descriptor.FieldDescriptorProto{testField("id", 1, descriptor.FieldDescriptorProto_TYPE_STRING, "")},
+			},
+		},
+	}
+}
+
+// testEchoFile has unary methods with annotations, all kinds of streams and a message of another package,
+// comments are the leading comments of the service and of its methods by index
+func testEchoFile(comments map[int]string) *descriptor.FileDescriptorProto {
+	file := &descriptor.FileDescriptorProto{
+		Name:       proto.String("echo/echo.proto"),
+		Package:    proto.String("echo.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"common/types.proto"},
+		Options:    &descriptor.FileOptions{GoPackage: proto.String("github.com/example/echo/echopb;echopb")},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("EchoRequest"),
+				Field: []*descriptor.FieldDescriptorProto{
+					testField("msg", 1, descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+					testField("count", 2, descriptor.FieldDescriptorProto_TYPE_INT64, ""),
+				},
+			},
+			{
+				Name: proto.String("EchoResponse"),
+				Field: []*descriptor.FieldDescriptorProto{
+					testField("msg", 1, descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+				},
+				NestedType: []*descriptor.DescriptorProto{
+					{
+						Name:  proto.String("Meta"),
+						Field: []*descriptor.FieldDescriptorProto{testField("ref", 1, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".common.Ref")},
+					},
+				},
+			},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{
+			{
+				Name: proto.String("EchoService"),
+				Method: []*descriptor.MethodDescriptorProto{
+					testMethod("Echo", ".echo.v1.EchoRequest", ".echo.v1.EchoResponse", false, false),
+					testMethod("Lookup", ".common.Ref", ".echo.v1.EchoResponse.Meta", false, false),
+					testMethod("Watch", ".echo.v1.EchoRequest", ".echo.v1.EchoResponse", false, true),
+					testMethod("Collect", ".echo.v1.EchoRequest", ".echo.v1.EchoResponse", true, false),
+					testMethod("Chat", ".echo.v1.EchoRequest", ".echo.v1.EchoResponse", true, true),
+				},
+			},
+		},
+		SourceCodeInfo: &descriptor.SourceCodeInfo{
+			Location: []*descriptor.SourceCodeInfo_Location{
+				testComment(" EchoRequest is echoed back\n", 5, 4, 0),
+				testComment(" msg is the message to echo\n", 6, 4, 0, 2, 0),
+			},
+		},
+	}
+	for index, comment := range comments {
+		path := []int32{6, 0}
+		line := int32(20)
+		if index >= 0 {
+			path = append(path, 2, int32(index))
+			line += 3 * int32(index+1)
+		}
+		file.SourceCodeInfo.Location = append(file.SourceCodeInfo.Location, testComment(comment, line, path...))
+	}
+	return file
+}
+
+// testEchoComments annotate the service and its unary methods, -1 is the service
+var testEchoComments = map[int]string{
+	-1: " EchoService echoes messages\n ORION:MIDDLEWARE: Audit\n",
+	0:  " Echo echoes a message\n ORION:URL: GET/POST/OPTIONS /api/echo/{msg}\n ORION:OPTION: ETAG\n",
+	1:  " ORION:URL: GET /api/lookup/{id}\n ORION:MIDDLEWARE: Auth, Trace\n",
+	2:  " ORION:URL: GET /api/watch\n",
+}
+
+// golden renders the files of a response, or its error, in a single document
+func golden(t *testing.T, response *plugin.CodeGeneratorResponse) []byte {
+	buf := new(bytes.Buffer)
+	if response.Error != nil {
+		buf.WriteString("-- error --\n" + response.GetError() + "\n")
+	}
+	for _, file := range response.GetFile() {
+		content := file.GetContent()
+		if strings.HasSuffix(file.GetName(), ".go") {
+			formatted, err := format.Source([]byte(content))
+			if assert.NoError(t, err, file.GetName()) {
+				assert.Equal(t, string(formatted), content, "%s should be formatted", file.GetName())
+			}
+		}
+		buf.WriteString("-- " + file.GetName() + " --\n" + content)
+	}
+	return buf.Bytes()
+}
+
+func TestGenerateResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		parameter string
+		comments  map[int]string
+		// setup sets the options of the echo file
+		setup func(t *testing.T, file *descriptor.FileDescriptorProto)
+	}{
+		{"clients", "", testEchoComments, nil},
+		{"openapi", "openapi=true,openapi_version_prefix", testEchoComments, nil},
+		{"source_relative", "paths=source_relative", nil, nil},
+		{"import_map", "Mcommon/types.proto=github.com/example/types,Mecho/echo.proto=github.com/example/echo", nil, nil},
+		{"option_extensions", "", map[int]string{1: " ORION:OPTION: ETAG\n"}, func(t *testing.T, file *descriptor.FileDescriptorProto) {
+			svc := file.GetService()[0]
+			svc.Options = new(descriptor.ServiceOptions)
+			assert.NoError(t, proto.SetExtension(svc.Options, options.E_Service, &options.ServiceOptions{
+				Middlewares: []string{"Audit"},
+				ReadTimeout: "5s",
+				Auth:        &options.Auth{Middlewares: []string{"Token"}},
+			}))
+			svc.Method[0].Options = new(descriptor.MethodOptions)
+			assert.NoError(t, proto.SetExtension(svc.Method[0].Options, options.E_Method, &options.MethodOptions{
+				Http:    &options.HTTPRule{Methods: []string{"get", "post"}, Path: "/api/echo/{msg}"},
+				Options: []string{"MAX_BODY_SIZE=1MB"},
+				Auth:    &options.Auth{Skip: true},
+			}))
+			svc.Method[1].Options = new(descriptor.MethodOptions)
+			assert.NoError(t, proto.SetExtension(svc.Method[1].Options, options.E_Method, &options.MethodOptions{
+				Http: &options.HTTPRule{Path: "/api/lookup"},
+			}))
+		}},
+		{"annotation_errors", "", map[int]string{
+			-1: " ORION:URL: GET /api/echo\n",
+			0:  " ORION:URL: FETCH /api/echo\n",
+			1:  " ORION:OPTION: MAX_BODY_SIZE=TEN\n",
+			2:  " ORION:CACHE: 30S\n",
+			4:  " ORION:OPTION: CODEC=JSON\n ORION:OPTION: EMIT_DEFAULTS\n",
+		}, func(t *testing.T, file *descriptor.FileDescriptorProto) {
+			method := file.GetService()[0].Method[3]
+			method.Options = new(descriptor.MethodOptions)
+			assert.NoError(t, proto.SetExtension(method.Options, options.E_Method, &options.MethodOptions{
+				Http:         &options.HTTPRule{Path: "api/collect"},
+				WriteTimeout: "never",
+			}))
+		}},
+		{"unknown_parameter", "openapi=true,plugins=grpc", nil, nil},
+		{"unknown_paths", "paths=module", nil, nil},
+	}
+	for _, test := range tests {
+		file := testEchoFile(test.comments)
+		if test.setup != nil {
+			test.setup(t, file)
+		}
+		request := &plugin.CodeGeneratorRequest{
+			FileToGenerate: []string{"echo/echo.proto"},
+			Parameter:      proto.String(test.parameter),
+			ProtoFile:      []*descriptor.FileDescriptorProto{testCommonFile(), file},
+		}
+		got := golden(t, generateResponse(request))
+		name := filepath.Join("testdata", test.name+".golden")
+		if *update {
+			if err := ioutil.WriteFile(name, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(name)
+		if assert.NoError(t, err, test.name) {
+			assert.Equal(t, string(want), string(got), "%s differs from %s, run 'go test -update' after checking the changes", test.name, name)
+		}
+	}
+}
+
+func TestGenerateResponseMissingFile(t *testing.T) {
+	request := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{"echo/echo.proto", "echo/missing.proto"},
+		ProtoFile:      []*descriptor.FileDescriptorProto{testCommonFile(), testEchoFile(nil)},
+	}
+	response := generateResponse(request)
+	assert.Equal(t, "could not find file named echo/missing.proto", response.GetError())
+	assert.Empty(t, response.GetFile(), "no files should be generated when a file fails")
+}
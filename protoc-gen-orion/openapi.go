@@ -0,0 +1,530 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/micro/protobuf/protoc-gen-go/generator"
+)
+
+// OpenAPI v3 document, only the parts generated from proto files are modelled
+type openAPI struct {
+	OpenAPI    string                  `json:"openapi"`
+	Info       openAPIInfo             `json:"info"`
+	Tags       []openAPITag            `json:"tags,omitempty"`
+	Paths      map[string]*openAPIPath `json:"paths"`
+	Components openAPIComponents       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type openAPITag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*openAPISchema `json:"schemas"`
+}
+
+// openAPIPath holds the operations of a path by lower case HTTP method
+type openAPIPath map[string]*openAPIOperation
+
+type openAPIOperation struct {
+	OperationID    string                      `json:"operationId"`
+	Tags           []string                    `json:"tags,omitempty"`
+	Description    string                      `json:"description,omitempty"`
+	Deprecated     bool                        `json:"deprecated,omitempty"`
+	Parameters     []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody    *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses      map[string]*openAPIResponse `json:"responses"`
+	Streaming      string                      `json:"x-orion-streaming,omitempty"`
+	WebSocket      bool                        `json:"x-orion-websocket,omitempty"`
+	RequestSchema  *openAPISchema              `json:"x-orion-request-schema,omitempty"`
+	ResponseSchema *openAPISchema              `json:"x-orion-response-schema,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Required    bool           `json:"required"`
+	Description string         `json:"description,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                     `json:"required"`
+	Content  map[string]*openAPIMedia `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                    `json:"description"`
+	Headers     map[string]*openAPIHeader `json:"headers,omitempty"`
+	Content     map[string]*openAPIMedia  `json:"content,omitempty"`
+}
+
+type openAPIHeader struct {
+	Description string         `json:"description,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIMedia struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	AllOf                []*openAPISchema          `json:"allOf,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+	Enum                 []int32                   `json:"enum,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+// protoMessage is a message descriptor along with the file it is defined in and its source code path
+type protoMessage struct {
+	file *descriptor.FileDescriptorProto
+	desc *descriptor.DescriptorProto
+	path string
+}
+
+type protoEnum struct {
+	file *descriptor.FileDescriptorProto
+	desc *descriptor.EnumDescriptorProto
+	path string
+}
+
+// protoIndex indexes the messages and enums of all files by their fully qualified name, e.g. '.echo.Outer.Inner'
+type protoIndex struct {
+	messages map[string]*protoMessage
+	enums    map[string]*protoEnum
+	comments map[*descriptor.FileDescriptorProto]map[string]string
+}
+
+func newProtoIndex(files []*descriptor.FileDescriptorProto) *protoIndex {
+	idx := &protoIndex{
+		messages: make(map[string]*protoMessage),
+		enums:    make(map[string]*protoEnum),
+		comments: make(map[*descriptor.FileDescriptorProto]map[string]string),
+	}
+	for _, file := range files {
+		prefix := ""
+		if file.GetPackage() != "" {
+			prefix = "." + file.GetPackage()
+		}
+		for i, enum := range file.GetEnumType() {
+			idx.enums[prefix+"."+enum.GetName()] = &protoEnum{file: file, desc: enum, path: fmt.Sprintf("5,%d", i)}
+		}
+		idx.addMessages(file, prefix, "4", file.GetMessageType())
+		idx.comments[file] = descriptions(file)
+	}
+	return idx
+}
+
+func (idx *protoIndex) addMessages(file *descriptor.FileDescriptorProto, prefix, path string, msgs []*descriptor.DescriptorProto) {
+	for i, msg := range msgs {
+		name := prefix + "." + msg.GetName()
+		msgPath := fmt.Sprintf("%s,%d", path, i)
+		idx.messages[name] = &protoMessage{file: file, desc: msg, path: msgPath}
+		for j, enum := range msg.GetEnumType() {
+			idx.enums[name+"."+enum.GetName()] = &protoEnum{file: file, desc: enum, path: fmt.Sprintf("%s,4,%d", msgPath, j)}
+		}
+		idx.addMessages(file, name, msgPath+",3", msg.GetNestedType())
+	}
+}
+
+func (idx *protoIndex) description(file *descriptor.FileDescriptorProto, path string) string {
+	return idx.comments[file][path]
+}
+
+// descriptions returns the comments of a file by source code path, annotations are removed from the comments
+func descriptions(file *descriptor.FileDescriptorProto) map[string]string {
+	comments := make(map[string]string)
+	for _, loc := range file.GetSourceCodeInfo().GetLocation() {
+		lines := make([]string, 0)
+		for _, line := range strings.Split(loc.GetLeadingComments()+"\n"+loc.GetTrailingComments(), "\n") {
+			line = strings.TrimSpace(line)
+			if option, err := parseComments(line); line == "" || option != nil || err != nil {
+				continue
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		var p []string
+		for _, n := range loc.Path {
+			p = append(p, strconv.Itoa(int(n)))
+		}
+		comments[strings.Join(p, ",")] = strings.Join(lines, "\n")
+	}
+	return comments
+}
+
+// openAPIRoute is an annotated route of a method
+type openAPIRoute struct {
+	methods []string
+	path    string
+}
+
+// openAPIGenerator builds the OpenAPI document of a service
+type openAPIGenerator struct {
+	idx           *protoIndex
+	file          *descriptor.FileDescriptorProto
+	versionPrefix bool
+	doc           *openAPI
+}
+
+var pathVariable = regexp.MustCompile(`\{([^{}:]+)(?::((?:[^{}]|\{[^{}]*\})+))?\}`)
+
+// generateOpenAPI builds the OpenAPI v3 document of a service, routes are documented as they are served by the
+// HTTP handler, request and response bodies use the field names of the default json codec
+func generateOpenAPI(idx *protoIndex, file *descriptor.FileDescriptorProto, ann fileAnnotations, index int, versionPrefix bool) *openAPI {
+	svc := file.GetService()[index]
+	fullName := svc.GetName()
+	if file.GetPackage() != "" {
+		fullName = file.GetPackage() + "." + svc.GetName()
+	}
+	svcPath := fmt.Sprintf("6,%d", index)
+	g := &openAPIGenerator{
+		idx:           idx,
+		file:          file,
+		versionPrefix: versionPrefix,
+		doc: &openAPI{
+			OpenAPI: "3.0.3",
+			Info: openAPIInfo{
+				Title:       fullName,
+				Description: idx.description(file, svcPath),
+				Version:     openAPIVersion(file.GetPackage()),
+			},
+			Tags:  []openAPITag{{Name: svc.GetName(), Description: idx.description(file, svcPath)}},
+			Paths: make(map[string]*openAPIPath),
+			Components: openAPIComponents{
+				Schemas: make(map[string]*openAPISchema),
+			},
+		},
+	}
+	for i, method := range svc.GetMethod() {
+		methodPath := fmt.Sprintf("%s,2,%d", svcPath, i)
+		var route *openAPIRoute
+		deprecated := false
+		for _, option := range ann.methodInfos(svcPath, methodPath) {
+			if option.Option && strings.TrimSpace(option.Value) == optionDeprecated {
+				deprecated = true
+			}
+			if option.Encoder {
+				route = &openAPIRoute{path: strings.TrimSpace(option.Path)}
+				for _, m := range strings.Split(option.Method, "/") {
+					if m = strings.ToUpper(strings.TrimSpace(m)); m != "" && m != "OPTIONS" {
+						route.methods = append(route.methods, m)
+					}
+				}
+			}
+		}
+		op := openAPIOperation{
+			OperationID: svc.GetName() + "_" + method.GetName(),
+			Tags:        []string{svc.GetName()},
+			Description: idx.description(file, methodPath),
+			Deprecated:  deprecated,
+		}
+		url := routeURL(fullName, method.GetName(), versionPrefix)
+		switch {
+		case method.GetClientStreaming():
+			g.addClientStream(url, op, method)
+		case method.GetServerStreaming():
+			g.addServerStream(url, op, method)
+		default:
+			g.addUnary(url, op, method, route)
+		}
+	}
+	return g.doc
+}
+
+// openAPIVersion uses the version of a versioned proto package as the version of the document
+func openAPIVersion(pkg string) string {
+	parts := strings.Split(strings.ToLower(pkg), ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if versionSegment.MatchString(parts[i]) {
+			return parts[i]
+		}
+	}
+	return "1.0"
+}
+
+func (g *openAPIGenerator) addOperation(path, method string, op *openAPIOperation) {
+	item, ok := g.doc.Paths[path]
+	if !ok {
+		item = &openAPIPath{}
+		g.doc.Paths[path] = item
+	}
+	(*item)[strings.ToLower(method)] = op
+}
+
+func (g *openAPIGenerator) addUnary(url string, op openAPIOperation, method *descriptor.MethodDescriptorProto, route *openAPIRoute) {
+	methods := []string{"POST"}
+	path := url
+	if route != nil {
+		if len(route.methods) > 0 {
+			methods = route.methods
+		}
+		if route.path != "" {
+			path = route.path
+		}
+	}
+	path, params := g.pathParameters(path, method.GetInputType())
+	for _, m := range methods {
+		o := op
+		if len(methods) > 1 {
+			o.OperationID = op.OperationID + "_" + strings.ToLower(m)
+		}
+		o.Parameters = params
+		if m != "GET" && m != "HEAD" && m != "DELETE" {
+			o.RequestBody = g.requestBody(method.GetInputType(), contentTypeJSON, contentTypeProtobuf)
+		}
+		o.Responses = g.responses("OK", method.GetOutputType(), contentTypeJSON, contentTypeProtobuf)
+		g.addOperation(path, m, &o)
+	}
+}
+
+func (g *openAPIGenerator) addServerStream(url string, op openAPIOperation, method *descriptor.MethodDescriptorProto) {
+	// websocket upgrades and chunked HTTP
+	get := op
+	get.OperationID = op.OperationID + "_get"
+	get.Streaming = "server"
+	get.WebSocket = true
+	get.RequestSchema = g.schemaRef(method.GetInputType())
+	get.ResponseSchema = g.schemaRef(method.GetOutputType())
+	get.Responses = g.responses("stream of messages as chunked HTTP", method.GetOutputType(), contentTypeNDJSON, contentTypeProtoStream)
+	get.Responses["101"] = &openAPIResponse{Description: "websocket connection, the request is the first message"}
+	g.addOperation(url, "GET", &get)
+
+	// chunked HTTP only
+	post := op
+	post.OperationID = op.OperationID + "_post"
+	post.Streaming = "server"
+	post.RequestBody = g.requestBody(method.GetInputType(), contentTypeJSON, contentTypeProtobuf)
+	post.Responses = g.responses("stream of messages as chunked HTTP", method.GetOutputType(), contentTypeNDJSON, contentTypeProtoStream)
+	g.addOperation(url, "POST", &post)
+}
+
+func (g *openAPIGenerator) addClientStream(url string, op openAPIOperation, method *descriptor.MethodDescriptorProto) {
+	op.Streaming = "client"
+	if method.GetServerStreaming() {
+		op.Streaming = "bidirectional"
+	}
+	op.WebSocket = true
+	op.RequestSchema = g.schemaRef(method.GetInputType())
+	op.ResponseSchema = g.schemaRef(method.GetOutputType())
+	op.Responses = map[string]*openAPIResponse{
+		"101":     {Description: "websocket connection, requests and responses are sent as websocket messages"},
+		"400":     {Description: "websocket connection required"},
+		"default": g.errorResponse(),
+	}
+	g.addOperation(url, "GET", &op)
+}
+
+// pathParameters documents the variables of a route path, e.g. '/users/{id:[0-9]+}', the path is returned without
+// the patterns of the variables
+func (g *openAPIGenerator) pathParameters(path, input string) (string, []*openAPIParameter) {
+	params := make([]*openAPIParameter, 0)
+	path = pathVariable.ReplaceAllStringFunc(path, func(match string) string {
+		parts := pathVariable.FindStringSubmatch(match)
+		name := strings.TrimSpace(parts[1])
+		param := &openAPIParameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &openAPISchema{Type: "string"},
+		}
+		if msg, ok := g.idx.messages[input]; ok {
+			for i, f := range msg.desc.GetField() {
+				// variables are bound to fields ignoring case, like the HTTP handler does
+				if strings.EqualFold(f.GetName(), name) || strings.EqualFold(generator.CamelCase(f.GetName()), name) {
+					if schema := g.fieldSchema(f); schema.Type != "" && schema.Type != "object" && schema.Type != "array" {
+						param.Schema = schema
+					}
+					param.Description = g.idx.description(msg.file, fmt.Sprintf("%s,2,%d", msg.path, i))
+				}
+			}
+		}
+		if parts[2] != "" {
+			param.Schema = &openAPISchema{Type: param.Schema.Type, Format: param.Schema.Format, Pattern: "^" + parts[2] + "$"}
+		}
+		params = append(params, param)
+		return "{" + name + "}"
+	})
+	return path, params
+}
+
+func (g *openAPIGenerator) requestBody(input string, contentTypes ...string) *openAPIRequestBody {
+	body := &openAPIRequestBody{
+		Required: true,
+		Content:  make(map[string]*openAPIMedia),
+	}
+	for _, contentType := range contentTypes {
+		body.Content[contentType] = &openAPIMedia{Schema: g.schemaRef(input)}
+	}
+	return body
+}
+
+func (g *openAPIGenerator) responses(description, output string, contentTypes ...string) map[string]*openAPIResponse {
+	resp := &openAPIResponse{
+		Description: description,
+		Content:     make(map[string]*openAPIMedia),
+	}
+	for _, contentType := range contentTypes {
+		resp.Content[contentType] = &openAPIMedia{Schema: g.schemaRef(output)}
+	}
+	return map[string]*openAPIResponse{
+		"200":     resp,
+		"default": g.errorResponse(),
+	}
+}
+
+func (g *openAPIGenerator) errorResponse() *openAPIResponse {
+	return &openAPIResponse{
+		Description: "error, the status code is mapped from the gRPC status code",
+		Headers: map[string]*openAPIHeader{
+			grpcStatusHeader: {
+				Description: "gRPC status code of the error",
+				Schema:      &openAPISchema{Type: "integer", Format: "int32"},
+			},
+		},
+		Content: map[string]*openAPIMedia{
+			"text/plain": {Schema: &openAPISchema{Type: "string"}},
+		},
+	}
+}
+
+// schemaRef returns a reference to the schema of a message, schemas are added to the components on first use
+func (g *openAPIGenerator) schemaRef(name string) *openAPISchema {
+	key := strings.TrimPrefix(name, ".")
+	ref := &openAPISchema{Ref: "#/components/schemas/" + key}
+	if _, ok := g.doc.Components.Schemas[key]; ok {
+		return ref
+	}
+	msg, ok := g.idx.messages[name]
+	if !ok {
+		return &openAPISchema{Type: "object", Description: "unknown type " + key}
+	}
+	schema := &openAPISchema{
+		Type:        "object",
+		Description: g.idx.description(msg.file, msg.path),
+		Properties:  make(map[string]*openAPISchema),
+	}
+	// added before the fields so that recursive messages refer to it
+	g.doc.Components.Schemas[key] = schema
+	for i, f := range msg.desc.GetField() {
+		fieldSchema := g.fieldSchema(f)
+		if description := g.idx.description(msg.file, fmt.Sprintf("%s,2,%d", msg.path, i)); description != "" {
+			fieldSchema = withDescription(fieldSchema, description)
+		}
+		if f.OneofIndex != nil {
+			// oneof fields are wrapped in an object named after the oneof by the json codec, the generated
+			// oneof fields have no json tags so the Go field names are used
+			oneof := generator.CamelCase(msg.desc.GetOneofDecl()[f.GetOneofIndex()].GetName())
+			wrapper, ok := schema.Properties[oneof]
+			if !ok {
+				wrapper = &openAPISchema{
+					Type:        "object",
+					Description: "only one of the properties is set",
+					Properties:  make(map[string]*openAPISchema),
+				}
+				schema.Properties[oneof] = wrapper
+			}
+			wrapper.Properties[generator.CamelCase(f.GetName())] = fieldSchema
+			continue
+		}
+		schema.Properties[f.GetName()] = fieldSchema
+	}
+	return ref
+}
+
+func withDescription(schema *openAPISchema, description string) *openAPISchema {
+	if schema.Ref != "" {
+		// siblings of $ref are ignored
+		return &openAPISchema{AllOf: []*openAPISchema{schema}, Description: description}
+	}
+	schema.Description = description
+	return schema
+}
+
+// fieldSchema returns the schema of a field as serialized by the json codec
+func (g *openAPIGenerator) fieldSchema(f *descriptor.FieldDescriptorProto) *openAPISchema {
+	var schema *openAPISchema
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		if msg, ok := g.idx.messages[f.GetTypeName()]; ok && msg.desc.GetOptions().GetMapEntry() {
+			// map keys are always strings in json
+			return &openAPISchema{
+				Type:                 "object",
+				AdditionalProperties: g.fieldSchema(msg.desc.GetField()[1]),
+			}
+		}
+		schema = g.schemaRef(f.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		schema = &openAPISchema{Type: "integer", Format: "int32"}
+		if enum, ok := g.idx.enums[f.GetTypeName()]; ok {
+			names := make([]string, 0)
+			for _, value := range enum.desc.GetValue() {
+				schema.Enum = append(schema.Enum, value.GetNumber())
+				names = append(names, fmt.Sprintf("%d: %s", value.GetNumber(), value.GetName()))
+			}
+			schema.Description = strings.TrimPrefix(f.GetTypeName(), ".") + " (" + strings.Join(names, ", ") + ")"
+		}
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		schema = &openAPISchema{Type: "number", Format: "double"}
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		schema = &openAPISchema{Type: "number", Format: "float"}
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		schema = &openAPISchema{Type: "integer", Format: "int64"}
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		schema = &openAPISchema{Type: "integer", Format: "uint64"}
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		schema = &openAPISchema{Type: "integer", Format: "int32"}
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		schema = &openAPISchema{Type: "integer", Format: "uint32"}
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		schema = &openAPISchema{Type: "boolean"}
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		schema = &openAPISchema{Type: "string", Format: "byte"}
+	default:
+		schema = &openAPISchema{Type: "string"}
+	}
+	if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return &openAPISchema{Type: "array", Items: schema}
+	}
+	return schema
+}
+
+// openAPIFileName returns the name of the OpenAPI document of a service, e.g. 'echo.echoservice.openapi.json'
+func openAPIFileName(file *descriptor.FileDescriptorProto, svc *descriptor.ServiceDescriptorProto) string {
+	return strings.TrimSuffix(strings.ToLower(path.Base(file.GetName())), ".proto") + "." + strings.ToLower(svc.GetName()) + ".openapi.json"
+}
+
+// openAPIFile renders the OpenAPI document of a service
+func openAPIFile(name string, doc *openAPI) *plugin.CodeGeneratorResponse_File {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		logError(err, "failed to marshal OpenAPI document")
+	}
+	file := new(plugin.CodeGeneratorResponse_File)
+	file.Content = proto.String(string(data) + "\n")
+	file.Name = proto.String(name)
+	return file
+}
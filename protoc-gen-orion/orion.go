@@ -1,7 +1,8 @@
 // protoc-gen-orion is a plugin for the Google protocol buffer compiler to generate
 // Orion Go code.  Run it by building this program and putting it in your path with
 // the name
-// 	protoc-gen-orion
+//
+//	protoc-gen-orion
 //
 // The generated code is documented in the package comment for
 // the library.
@@ -9,10 +10,13 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"go/format"
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"text/template"
@@ -33,6 +37,19 @@ const (
 	MIDDLEWARES = "MIDDLEWARES"
 )
 
+// plugin parameters
+const (
+	// paramOpenAPI generates an OpenAPI v3 document for every service
+	paramOpenAPI = "openapi"
+	// paramOpenAPIVersionPrefix documents the '/<version>/<service>/<method>' urls served with the VersionPrefix config
+	paramOpenAPIVersionPrefix = "openapi_version_prefix"
+	// paramPaths places the generated files in their Go import path with 'import', the default,
+	// or next to the proto files with 'source_relative'
+	paramPaths = "paths"
+	// paramImportPath sets the import path of the generated files without a go_package option
+	paramImportPath = "import_path"
+)
+
 type commentsInfo struct {
 	Method     string
 	Path       string
@@ -47,6 +64,10 @@ type data struct {
 	FileName    string
 	PackageName string
 	Services    []*service
+	Imports     []*goImport
+	HasClients  bool
+	packages    *goPackages
+	goPackage   goPackage
 }
 
 type service struct {
@@ -58,6 +79,8 @@ type service struct {
 	Options        []*orionOption
 	Middlewares    []*orionMiddleware
 	Streams        []*stream
+	Client         *orionClient
+	OpenAPISpec    string
 }
 
 type encoder struct {
@@ -96,13 +119,54 @@ type orionMiddleware struct {
 	Names      string
 }
 
+type orionClient struct {
+	ServName string
+	Methods  []*clientMethod
+}
+
+type clientMethod struct {
+	ServName       string
+	ServiceDescVar string
+	Name           string
+	FullMethod     string
+	Input          string
+	Output         string
+	HTTPMethod     string
+	Path           string
+	Route          bool
+	ClientStream   bool
+	ServerStream   bool
+	StreamIndex    int
+}
+
+type goImport struct {
+	Alias string
+	Path  string
+}
+
+// goType is the Go type generated for a proto message
+type goType struct {
+	file *descriptor.FileDescriptorProto
+	name string
+}
+
 var tmpl = `// Code generated by protoc-gen-orion. DO NOT EDIT.
 // source: {{ .FileName }}
 
 package {{ .PackageName }}
 {{ if .Services }}
 import (
+{{- if .HasClients }}
+	context "context"
+{{ end }}
 	orion "github.com/go-orion/Orion/orion"
+{{- if .HasClients }}
+	orion_client "github.com/go-orion/Orion/orion/client"
+	grpc "google.golang.org/grpc"
+{{- end }}
+{{- range .Imports }}
+	{{.Alias}} "{{.Path}}"
+{{- end }}
 )
 
 // If you see error please update your orion-protoc-gen by running 'go get -u github.com/go-orion/Orion/protoc-gen-orion'
@@ -133,7 +197,8 @@ func Register{{.SvcName}}{{.MethodName}}Decoder(svr orion.Server, decoder orion.
 {{ end }}
 //Streams
 {{ range .Streams }}
-// {{ . }}
+// {{.MethodName}} in {{.SvcName}} is a {{ if and .ClientStream .ServerStream }}bidirectional{{ else if .ClientStream }}client{{ else }}server{{ end }} streaming method,
+// it is served over websockets{{ if not .ClientStream }} and chunked HTTP{{ end }}
 {{ end }}
 // Register{{.ServName}}OrionServer registers {{.ServName}} to Orion server
 // Services need to pass either ServiceFactory or ServiceFactoryV2 implementation
@@ -163,7 +228,90 @@ func Register{{.ServName}}DefaultEncoder(svr orion.Server, encoder orion.Encoder
 func Register{{.ServName}}DefaultDecoder(svr orion.Server, decoder orion.Decoder) {
 	orion.RegisterDefaultDecoder(svr, "{{.ServName}}", decoder)
 }
+{{- if .OpenAPISpec }}
+
+// {{.ServName}}OpenAPI is the OpenAPI v3 document of {{.ServName}}
+const {{.ServName}}OpenAPI = {{.OpenAPISpec}}
+
+// Register{{.ServName}}OpenAPI serves the OpenAPI v3 document of {{.ServName}} at path on the HTTP handler
+func Register{{.ServName}}OpenAPI(svr orion.Server, path string) {
+	orion.RegisterOpenAPI(svr, path, []byte({{.ServName}}OpenAPI))
+}
+{{- end }}
+{{ with .Client }}
+// Client
+type orion{{.ServName}}Client struct {
+	inv orion_client.Invoker
+}
+
+// New{{.ServName}}OrionClient creates a {{.ServName}}Client calling the service through the invoker,
+// the invoker returned by orion.GetClientInvoker uses the transport configured for the service
+func New{{.ServName}}OrionClient(inv orion_client.Invoker) {{.ServName}}Client {
+	return &orion{{.ServName}}Client{inv}
+}
+{{ range .Methods }}
+{{- if not (or .ClientStream .ServerStream) }}
+func (c *orion{{.ServName}}Client) {{.Name}}(ctx context.Context, in *{{.Input}}, opts ...grpc.CallOption) (*{{.Output}}, error) {
+	out := new({{.Output}})
+{{- if .Route }}
+	opts = append([]grpc.CallOption{orion_client.HTTPRoute("{{.HTTPMethod}}", "{{.Path}}")}, opts...)
+{{- end }}
+	err := c.inv.Invoke(ctx, "{{.FullMethod}}", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+{{ else }}
+func (c *orion{{.ServName}}Client) {{.Name}}(ctx context.Context, {{ if not .ClientStream }}in *{{.Input}}, {{ end }}opts ...grpc.CallOption) ({{.ServName}}_{{.Name}}Client, error) {
+	stream, err := c.inv.NewStream(ctx, &{{.ServiceDescVar}}.Streams[{{.StreamIndex}}], "{{.FullMethod}}", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &orion{{.ServName}}{{.Name}}Client{stream}
+{{- if not .ClientStream }}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+{{- end }}
+	return x, nil
+}
+
+type orion{{.ServName}}{{.Name}}Client struct {
+	grpc.ClientStream
+}
+{{ if .ClientStream }}
+func (x *orion{{.ServName}}{{.Name}}Client) Send(m *{{.Input}}) error {
+	return x.ClientStream.SendMsg(m)
+}
+{{ end }}
+{{- if .ServerStream }}
+func (x *orion{{.ServName}}{{.Name}}Client) Recv() (*{{.Output}}, error) {
+	m := new({{.Output}})
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+{{ else }}
+func (x *orion{{.ServName}}{{.Name}}Client) CloseAndRecv() (*{{.Output}}, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new({{.Output}})
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
 {{ end }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- end }}
 `
 
 // Error reports a problem, including an error, and exits the program.
@@ -195,19 +343,71 @@ func main() {
 		logFail("no files to generate")
 	}
 
+	writeResponse(generateResponse(request))
+}
+
+// generateResponse generates the files of a request, problems of the request are reported in the error of the
+// response so that protoc can show them
+func generateResponse(request *plugin.CodeGeneratorRequest) *plugin.CodeGeneratorResponse {
 	response := new(plugin.CodeGeneratorResponse)
 	response.File = make([]*plugin.CodeGeneratorResponse_File, 0)
 
+	params, err := newGeneratorParams(request.GetParameter())
+	if err != nil {
+		response.Error = proto.String(err.Error())
+		return response
+	}
+
+	files := make(map[string]*descriptor.FileDescriptorProto)
 	for _, file := range request.GetProtoFile() {
+		files[file.GetName()] = file
+	}
+	packages := newGoPackages(request, params)
+	types := indexTypes(request.GetProtoFile())
+	idx := newProtoIndex(request.GetProtoFile())
+	errs := make([]string, 0)
+	// only the requested files are generated, the other files are their imports
+	for _, name := range request.GetFileToGenerate() {
+		file, ok := files[name]
+		if !ok {
+			errs = append(errs, "could not find file named "+name)
+			continue
+		}
 		// check if file has any service
 		if len(file.Service) > 0 {
-			f := generateFile(populate(file))
+			ann, err := readAnnotations(file)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			d := populate(file, types, ann, packages)
+			if params.openAPI {
+				for i, svc := range file.GetService() {
+					doc := generateOpenAPI(idx, file, ann, i, params.openAPIVersionPrefix)
+					response.File = append(response.File, openAPIFile(packages.outputName(file, openAPIFileName(file, svc)), doc))
+					spec, err := json.Marshal(doc)
+					if err != nil {
+						logError(err, "failed to marshal OpenAPI document")
+					}
+					d.Services[i].OpenAPISpec = strconv.Quote(string(spec))
+				}
+			}
+			f := generateFile(d)
+			f.Name = proto.String(packages.outputName(file, path.Base(strings.ToLower(file.GetName()))+".orion.pb.go"))
 			response.File = append(response.File, f)
 		}
 	}
+	if len(errs) > 0 {
+		// invalid annotations fail the generation, protoc reports the error
+		response.Error = proto.String(strings.Join(errs, "\n"))
+		response.File = nil
+	}
+	return response
+}
 
-	// Send back the results.
-	data, err = proto.Marshal(response)
+// writeResponse sends back the results
+func writeResponse(response *plugin.CodeGeneratorResponse) {
+	data, err := proto.Marshal(response)
 	if err != nil {
 		logError(err, "failed to marshal output proto")
 	}
@@ -217,6 +417,25 @@ func main() {
 	}
 }
 
+// parseParameters parses the comma separated 'key=value' parameters passed to the plugin,
+// e.g. '--orion_out=openapi=true:.', keys without a value are set to 'true'
+func parseParameters(parameter string) map[string]string {
+	params := make(map[string]string)
+	for _, param := range strings.Split(parameter, ",") {
+		param = strings.TrimSpace(param)
+		if param == "" {
+			continue
+		}
+		parts := strings.SplitN(param, "=", 2)
+		if len(parts) == 1 {
+			params[parts[0]] = "true"
+			continue
+		}
+		params[parts[0]] = parts[1]
+	}
+	return params
+}
+
 func generateFile(d *data) *plugin.CodeGeneratorResponse_File {
 	t := template.New("file")
 	t, err := t.Parse(tmpl)
@@ -230,25 +449,150 @@ func generateFile(d *data) *plugin.CodeGeneratorResponse_File {
 		logError(err, "failed parsing template")
 	}
 
+	// the generated code is formatted like the code of protoc-gen-go, which also sorts the imports
+	content, err := format.Source(buf.Bytes())
+	if err != nil {
+		logError(err, "failed formatting generated code for "+d.FileName)
+	}
+
 	file := new(plugin.CodeGeneratorResponse_File)
-	file.Content = proto.String(buf.String())
-	file.Name = proto.String(strings.ToLower(d.FileName) + ".orion.pb.go")
+	file.Content = proto.String(string(content))
 	return file
 }
 
-func populate(file *descriptor.FileDescriptorProto) *data {
+func populate(file *descriptor.FileDescriptorProto, types map[string]goType, ann fileAnnotations, packages *goPackages) *data {
 	d := new(data)
 	d.FileName = *file.Name
-	d.PackageName = strings.Replace(file.GetPackage(), ".", "_", 10)
+	d.packages = packages
+	d.goPackage = packages.of(file)
+	d.PackageName = d.goPackage.name
 
 	d.Services = make([]*service, 0)
-	generate(d, file)
+	d.Imports = make([]*goImport, 0)
+	generate(d, file, types, ann)
 
 	return d
 }
 
-func generate(d *data, file *descriptor.FileDescriptorProto) {
-	comments := extractComments(file)
+// indexTypes maps the fully qualified names of all messages, e.g. '.echo.Outer.Inner', to the Go types generated for them
+func indexTypes(files []*descriptor.FileDescriptorProto) map[string]goType {
+	types := make(map[string]goType)
+	var add func(file *descriptor.FileDescriptorProto, prefix string, names []string, msgs []*descriptor.DescriptorProto)
+	add = func(file *descriptor.FileDescriptorProto, prefix string, names []string, msgs []*descriptor.DescriptorProto) {
+		for _, msg := range msgs {
+			nested := append(append([]string{}, names...), msg.GetName())
+			name := prefix + "." + msg.GetName()
+			types[name] = goType{file: file, name: generator.CamelCaseSlice(nested)}
+			add(file, name, nested, msg.GetNestedType())
+		}
+	}
+	for _, file := range files {
+		prefix := ""
+		if file.GetPackage() != "" {
+			prefix = "." + file.GetPackage()
+		}
+		add(file, prefix, nil, file.GetMessageType())
+	}
+	return types
+}
+
+// goTypeName returns the Go type of a proto message used in file, types of other Go packages are imported
+func goTypeName(d *data, types map[string]goType, name string) (string, error) {
+	t, ok := types[name]
+	if !ok {
+		return "", fmt.Errorf("unknown type %s", name)
+	}
+	goPkg := d.packages.of(t.file)
+	if goPkg.importPath == d.goPackage.importPath {
+		return t.name, nil
+	}
+	importPath, pkg := goPkg.importPath, goPkg.name
+	for _, imp := range d.Imports {
+		if imp.Path == importPath {
+			return imp.Alias + "." + t.name, nil
+		}
+	}
+	// aliases must not clash with other imports of the generated file
+	alias := pkg
+	for i := 1; isImportAlias(d, alias); i++ {
+		alias = pkg + strconv.Itoa(i)
+	}
+	d.Imports = append(d.Imports, &goImport{Alias: alias, Path: importPath})
+	return alias + "." + t.name, nil
+}
+
+func isImportAlias(d *data, alias string) bool {
+	switch alias {
+	case "context", "orion", "orion_client", "grpc":
+		return true
+	}
+	for _, imp := range d.Imports {
+		if imp.Alias == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// populateClient fills in the typed client of a service, no client is generated when the Go types of its messages
+// can not be resolved
+func populateClient(d *data, file *descriptor.FileDescriptorProto, types map[string]goType, svc *descriptor.ServiceDescriptorProto, s *service, routes map[string]*commentsInfo) {
+	imports := len(d.Imports)
+	c := new(orionClient)
+	c.ServName = s.ServName
+	c.Methods = make([]*clientMethod, 0)
+	streamIndex := 0
+	for _, method := range svc.GetMethod() {
+		m := new(clientMethod)
+		m.ServName = s.ServName
+		m.ServiceDescVar = s.ServiceDescVar
+		m.Name = generator.CamelCase(method.GetName())
+		m.FullMethod = "/" + svc.GetName() + "/" + method.GetName()
+		if file.GetPackage() != "" {
+			m.FullMethod = "/" + file.GetPackage() + "." + svc.GetName() + "/" + method.GetName()
+		}
+		var err error
+		if m.Input, err = goTypeName(d, types, method.GetInputType()); err == nil {
+			m.Output, err = goTypeName(d, types, method.GetOutputType())
+		}
+		if err != nil {
+			log.Print("protoc-gen-orion: warning: no client generated for ", svc.GetName(), ": ", err)
+			d.Imports = d.Imports[:imports]
+			return
+		}
+		m.ClientStream = method.GetClientStreaming()
+		m.ServerStream = method.GetServerStreaming()
+		if m.ClientStream || m.ServerStream {
+			m.StreamIndex = streamIndex
+			streamIndex++
+		} else if route, ok := routes[method.GetName()]; ok {
+			m.Route = true
+			m.HTTPMethod = clientHTTPMethod(route.Method)
+			m.Path = route.Path
+		}
+		c.Methods = append(c.Methods, m)
+	}
+	s.Client = c
+	d.HasClients = true
+}
+
+// clientHTTPMethod picks the HTTP method used by clients from the methods of an 'ORION:URL' annotation,
+// POST is preferred as the request is sent as the body
+func clientHTTPMethod(methods string) string {
+	picked := ""
+	for _, method := range strings.Split(methods, "/") {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		switch {
+		case method == "POST":
+			return method
+		case picked == "" && method != "" && method != "OPTIONS" && method != "HEAD":
+			picked = method
+		}
+	}
+	return picked
+}
+
+func generate(d *data, file *descriptor.FileDescriptorProto, types map[string]goType, ann fileAnnotations) {
 	for index, svc := range file.GetService() {
 
 		origServName := svc.GetName()
@@ -266,148 +610,150 @@ func generate(d *data, file *descriptor.FileDescriptorProto) {
 		s.ServName = servName
 		d.Services = append(d.Services, s)
 
-		// ** --- START -- Find comments in grpc services
 		path := fmt.Sprintf("6,%d", index) // 6 means service.
+
+		// routes of 'ORION:URL' annotations, used by the generated client
+		routes := make(map[string]*commentsInfo)
 		for i, method := range svc.GetMethod() {
 			commentPath := fmt.Sprintf("%s,2,%d", path, i) // 2 means method in a service.
-			if loc, ok := comments[commentPath]; ok {
-				text := strings.TrimSuffix(loc.GetLeadingComments(), "\n")
-				for _, line := range strings.Split(text, "\n") {
-					// ** --- END -- Find comments in grpc services
-
-					if option := parseComments(line); option != nil {
-						if method.GetClientStreaming() || method.GetServerStreaming() {
-							str := new(stream)
-							str.SvcName = svc.GetName()
-							str.MethodName = method.GetName()
-							str.ClientStream = method.GetClientStreaming()
-							str.ServerStream = method.GetServerStreaming()
-							if option.Encoder || option.Decoder {
-								str.Path = option.Path
-								str.Methods = option.Method
-							}
-							s.Streams = append(s.Streams, str)
-						} else { // dont add others for streaming use cases
-							if option.Encoder {
-								methods := strings.Split(option.Method, "/")
-								for i := range methods {
-									if strings.ToLower(methods[i]) == "options" {
-									}
-									methods[i] = "\"" + methods[i] + "\""
-								}
-								methodsString := strings.Join(methods, ", ")
-
-								// populate encoder
-								enc := new(encoder)
-								enc.SvcName = svc.GetName()
-								enc.MethodName = method.GetName()
-								enc.Path = option.Path
-								enc.Methods = methodsString
-								s.Encoders = append(s.Encoders, enc)
-
-								// popluate handler
-								han := new(handler)
-								han.SvcName = svc.GetName()
-								han.MethodName = method.GetName()
-								han.Path = option.Path
-								s.Handlers = append(s.Handlers, han)
-							}
-
-							if option.Decoder {
-								// popluate decoder
-								dec := new(decoder)
-								dec.SvcName = svc.GetName()
-								dec.MethodName = method.GetName()
-								s.Decoders = append(s.Decoders, dec)
-							}
+			// annotations of the service apply to all its methods
+			for _, option := range ann.methodInfos(path, commentPath) {
+				// options and middlewares apply to unary and streaming methods
+				if option.Option {
+					opt := new(orionOption)
+					opt.SvcName = svc.GetName()
+					opt.MethodName = method.GetName()
+					opt.OptionType = strings.TrimSpace(option.Value)
+					s.Options = append(s.Options, opt)
+				}
 
-							if option.Option {
-								opt := new(orionOption)
-								opt.SvcName = svc.GetName()
-								opt.MethodName = method.GetName()
-								opt.OptionType = strings.TrimSpace(option.Value)
-								s.Options = append(s.Options, opt)
-							}
+				if option.Middleware {
+					mid := new(orionMiddleware)
+					mid.SvcName = svc.GetName()
+					mid.MethodName = method.GetName()
+					names := strings.Split(option.Value, ",")
+					for i := range names {
+						names[i] = "\"" + strings.TrimSpace(names[i]) + "\""
+					}
+					mid.Names = strings.Join(names, ", ")
+					s.Middlewares = append(s.Middlewares, mid)
+				}
 
-							if option.Middleware {
-								mid := new(orionMiddleware)
-								mid.SvcName = svc.GetName()
-								mid.MethodName = method.GetName()
-								names := strings.Split(option.Value, ",")
-								for i := range names {
-									names[i] = "\"" + strings.TrimSpace(names[i]) + "\""
-								}
-								mid.Names = strings.Join(names, ", ")
-								s.Middlewares = append(s.Middlewares, mid)
+				if method.GetClientStreaming() || method.GetServerStreaming() {
+					if option.Encoder || option.Decoder {
+						str := new(stream)
+						str.SvcName = svc.GetName()
+						str.MethodName = method.GetName()
+						str.ClientStream = method.GetClientStreaming()
+						str.ServerStream = method.GetServerStreaming()
+						str.Path = option.Path
+						str.Methods = option.Method
+						s.Streams = append(s.Streams, str)
+					}
+				} else { // dont add encoders and decoders for streaming use cases
+					if option.Encoder {
+						routes[method.GetName()] = option
+						methods := strings.Split(option.Method, "/")
+						for i := range methods {
+							if strings.ToLower(methods[i]) == "options" {
 							}
+							methods[i] = "\"" + methods[i] + "\""
 						}
+						methodsString := strings.Join(methods, ", ")
+
+						// populate encoder
+						enc := new(encoder)
+						enc.SvcName = svc.GetName()
+						enc.MethodName = method.GetName()
+						enc.Path = option.Path
+						enc.Methods = methodsString
+						s.Encoders = append(s.Encoders, enc)
+
+						// popluate handler
+						han := new(handler)
+						han.SvcName = svc.GetName()
+						han.MethodName = method.GetName()
+						han.Path = option.Path
+						s.Handlers = append(s.Handlers, han)
+					}
+
+					if option.Decoder {
+						// popluate decoder
+						dec := new(decoder)
+						dec.SvcName = svc.GetName()
+						dec.MethodName = method.GetName()
+						s.Decoders = append(s.Decoders, dec)
 					}
 				}
 			}
 		}
+		populateClient(d, file, types, svc, s, routes)
 	}
 }
 
-func parseCommentURL(parts []string) *commentsInfo {
+func parseCommentURL(parts []string) (*commentsInfo, error) {
 	if len(parts) > 2 {
 		values := strings.SplitN(strings.TrimSpace(parts[2]), " ", 2)
-		if len(values) == 2 {
-			return &commentsInfo{
-				Method:  strings.ToUpper(values[0]),
-				Path:    values[1],
-				Encoder: true,
-				Decoder: true,
-			}
-		}
-		return &commentsInfo{
+		info := &commentsInfo{
 			Method:  strings.ToUpper(values[0]),
 			Path:    "",
 			Encoder: true,
 			Decoder: true,
 		}
+		if len(values) == 2 {
+			info.Path = strings.TrimSpace(values[1])
+		}
+		return info, validateURL(info)
 	}
 	return &commentsInfo{
 		Decoder: true,
-	}
+	}, nil
 }
 
-func parseMiddlewares(parts []string) *commentsInfo {
-	if len(parts) > 2 {
+func parseMiddlewares(parts []string) (*commentsInfo, error) {
+	if len(parts) > 2 && strings.TrimSpace(parts[2]) != "" {
 		return &commentsInfo{
 			Middleware: true,
 			Value:      parts[2],
-		}
+		}, validateMiddlewares(parts[2])
 	}
-	return nil
+	return nil, fmt.Errorf("ORION:MIDDLEWARE without middlewares")
 }
 
-func parseCommentOptions(parts []string) *commentsInfo {
+func parseCommentOptions(parts []string) (*commentsInfo, error) {
 	if len(parts) > 2 {
-		return &commentsInfo{
+		info := &commentsInfo{
 			Option: true,
 			Value:  strings.ToUpper(parts[2]),
 		}
+		return info, validateOption(strings.TrimSpace(info.Value))
 	}
-	return nil
+	return nil, fmt.Errorf("ORION:OPTION without value")
 }
 
-func parseComments(line string) *commentsInfo {
+// parseComments parses an 'ORION:' annotation, it returns nil for other comment lines and an error for
+// unknown or malformed annotations
+func parseComments(line string) (*commentsInfo, error) {
 	parts := strings.SplitN(line, DELIM, 3)
-	if len(parts) > 1 {
-		if ORION == strings.ToUpper(strings.TrimSpace(parts[0])) {
-			switch strings.ToUpper(strings.TrimSpace(parts[1])) {
-			case URL:
-				return parseCommentURL(parts)
-			case OPTION:
-				return parseCommentOptions(parts)
-			case MIDDLEWARES:
-				fallthrough
-			case MIDDLEWARE:
-				return parseMiddlewares(parts)
-			}
-		}
+	if len(parts) < 2 || ORION != strings.ToUpper(strings.TrimSpace(parts[0])) {
+		return nil, nil
 	}
-	return nil
+	kind := strings.ToUpper(strings.TrimSpace(parts[1]))
+	if strings.ContainsAny(kind, " \t") {
+		// prose, e.g. 'Orion: the server', is not an annotation
+		return nil, nil
+	}
+	switch kind {
+	case URL:
+		return parseCommentURL(parts)
+	case OPTION:
+		return parseCommentOptions(parts)
+	case MIDDLEWARES:
+		fallthrough
+	case MIDDLEWARE:
+		return parseMiddlewares(parts)
+	}
+	return nil, fmt.Errorf("unknown annotation 'ORION:%s'", strings.TrimSpace(parts[1]))
 }
 
 func extractComments(file *descriptor.FileDescriptorProto) map[string]*descriptor.SourceCodeInfo_Location {
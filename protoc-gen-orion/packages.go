@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// generatorParams are the parameters passed to the plugin, e.g. '--orion_out=paths=source_relative,openapi=true:.'
+type generatorParams struct {
+	openAPI              bool
+	openAPIVersionPrefix bool
+	// sourceRelative places the generated files next to the proto files instead of in their Go import path
+	sourceRelative bool
+	// importPath is the import path of the generated files without a go_package option
+	importPath string
+	// importMap maps proto files to Go import paths, set with 'M<file>=<import path>'
+	importMap map[string]string
+}
+
+// newGeneratorParams parses the parameters of the plugin, the parameters of protoc-gen-go that select the Go
+// packages are supported so that the generated code can be placed next to the code generated by protoc-gen-go
+func newGeneratorParams(parameter string) (*generatorParams, error) {
+	params := &generatorParams{
+		importMap: make(map[string]string),
+	}
+	for key, value := range parseParameters(parameter) {
+		switch key {
+		case paramOpenAPI:
+			params.openAPI = value == "true"
+		case paramOpenAPIVersionPrefix:
+			params.openAPIVersionPrefix = value == "true"
+		case paramPaths:
+			switch value {
+			case "import":
+				params.sourceRelative = false
+			case "source_relative":
+				params.sourceRelative = true
+			default:
+				return nil, fmt.Errorf("unknown path type '%s': want 'import' or 'source_relative'", value)
+			}
+		case paramImportPath:
+			params.importPath = value
+		default:
+			if strings.HasPrefix(key, "M") && len(key) > 1 {
+				params.importMap[key[1:]] = value
+				continue
+			}
+			return nil, fmt.Errorf("unknown parameter '%s'", key)
+		}
+	}
+	return params, nil
+}
+
+// goPackage is the Go package of the code generated for a proto file
+type goPackage struct {
+	importPath string
+	name       string
+}
+
+// goPackages resolves the Go packages of proto files the same way protoc-gen-go does
+type goPackages struct {
+	params   *generatorParams
+	generate map[string]bool
+	// names are the package names set by go_package options by import path, they apply to files of the
+	// same package without a go_package option
+	names map[string]string
+}
+
+func newGoPackages(request *plugin.CodeGeneratorRequest, params *generatorParams) *goPackages {
+	p := &goPackages{
+		params:   params,
+		generate: make(map[string]bool),
+		names:    make(map[string]string),
+	}
+	for _, name := range request.GetFileToGenerate() {
+		p.generate[name] = true
+	}
+	for _, file := range request.GetProtoFile() {
+		if _, name, ok := goPackageOption(file); ok && p.generate[file.GetName()] {
+			p.names[p.importPath(file)] = name
+		}
+	}
+	return p
+}
+
+// of returns the Go package of a proto file
+func (p *goPackages) of(file *descriptor.FileDescriptorProto) goPackage {
+	pkg := goPackage{
+		importPath: p.importPath(file),
+	}
+	if _, name, ok := goPackageOption(file); ok {
+		pkg.name = name
+	} else if name, ok := p.names[pkg.importPath]; ok {
+		pkg.name = name
+	} else if p.params.importPath != "" && p.generate[file.GetName()] {
+		pkg.name = cleanPackageName(path.Base(p.params.importPath))
+	} else if file.GetPackage() != "" {
+		pkg.name = cleanPackageName(file.GetPackage())
+	} else {
+		pkg.name = cleanPackageName(strings.TrimSuffix(path.Base(file.GetName()), path.Ext(file.GetName())))
+	}
+	return pkg
+}
+
+func (p *goPackages) importPath(file *descriptor.FileDescriptorProto) string {
+	if importPath, ok := p.params.importMap[file.GetName()]; ok {
+		return importPath
+	}
+	if p.params.importPath != "" && p.generate[file.GetName()] {
+		return p.params.importPath
+	}
+	if importPath, _, _ := goPackageOption(file); importPath != "" {
+		return importPath
+	}
+	return path.Dir(file.GetName())
+}
+
+// outputName returns the name of a file generated for a proto file, e.g. 'echo.proto.orion.pb.go', it is placed
+// in the directory of the Go import path or next to the proto file with 'paths=source_relative'
+func (p *goPackages) outputName(file *descriptor.FileDescriptorProto, name string) string {
+	if p.params.sourceRelative {
+		return path.Join(path.Dir(file.GetName()), name)
+	}
+	return path.Join(p.importPath(file), name)
+}
+
+// goPackageOption interprets the go_package option of a file, it can set the import path, the package name or both,
+// e.g. 'github.com/go-orion/Orion/orion;orion'
+func goPackageOption(file *descriptor.FileDescriptorProto) (importPath, name string, ok bool) {
+	opt := file.GetOptions().GetGoPackage()
+	if opt == "" {
+		return "", "", false
+	}
+	if i := strings.Index(opt, ";"); i >= 0 {
+		return opt[:i], cleanPackageName(opt[i+1:]), true
+	}
+	if i := strings.LastIndex(opt, "/"); i >= 0 {
+		return opt, cleanPackageName(opt[i+1:]), true
+	}
+	return "", cleanPackageName(opt), true
+}
+
+// cleanPackageName converts a name to a valid Go package name, e.g. 'echo.v1' to 'echo_v1'
+func cleanPackageName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// the plugin does not import the Orion runtime, the values below mirror orion/handlers/http and are kept in sync
+// by the tests of the plugin
+const (
+	optionIgnoreNR      = "IGNORE_NR"
+	optionNoCompression = "NO_COMPRESSION"
+	optionMaxBodySize   = "MAX_BODY_SIZE"
+	optionCodec         = "CODEC"
+	optionEmitDefaults  = "EMIT_DEFAULTS"
+	optionOrigName      = "ORIG_NAME"
+	optionEnumsAsInts   = "ENUMS_AS_INTS"
+	optionETag          = "ETAG"
+	optionReadTimeout   = "READ_TIMEOUT"
+	optionWriteTimeout  = "WRITE_TIMEOUT"
+	optionDeprecated    = "DEPRECATED"
+	optionCache         = "CACHE"
+
+	contentTypeJSON        = "application/json"
+	contentTypeProtobuf    = "application/protobuf"
+	contentTypeNDJSON      = "application/x-ndjson"
+	contentTypeProtoStream = "application/x-protobuf-stream"
+
+	grpcStatusHeader = "Grpc-Status"
+)
+
+var versionSegment = regexp.MustCompile(`^v[0-9]+((alpha|beta)[0-9]*)?$`)
+
+// routeURL returns the url the HTTP handler generates for a method, see httphandler.RouteURL
+func routeURL(serviceName, method string, versioned bool) string {
+	parts := strings.Split(strings.ToLower(serviceName), ".")
+	method = strings.ToLower(method)
+	if versioned {
+		for i := len(parts) - 2; i >= 0; i-- {
+			if versionSegment.MatchString(parts[i]) {
+				return "/" + parts[i] + "/" + parts[len(parts)-1] + "/" + method
+			}
+		}
+	}
+	name := parts[0]
+	if len(parts) > 1 {
+		name = parts[1]
+	}
+	return "/" + name + "/" + method
+}
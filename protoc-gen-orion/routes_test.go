@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-orion/Orion/orion/handlers"
+	httphandler "github.com/go-orion/Orion/orion/handlers/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteURL(t *testing.T) {
+	services := []string{"echo_proto.EchoService", "echo.v1.EchoService", "api.v2beta1.users.UserService", "Simple", "a.b.c.Svc"}
+	for _, svc := range services {
+		for _, versioned := range []bool{false, true} {
+			assert.Equal(t, httphandler.RouteURL(svc, "GetUser", versioned), routeURL(svc, "GetUser", versioned), svc)
+		}
+	}
+}
+
+func TestHTTPHandlerConstants(t *testing.T) {
+	assert.Equal(t, httphandler.IgnoreNR, optionIgnoreNR)
+	assert.Equal(t, httphandler.NoCompression, optionNoCompression)
+	assert.Equal(t, httphandler.MaxBodySize, optionMaxBodySize)
+	assert.Equal(t, httphandler.CodecOption, optionCodec)
+	assert.Equal(t, httphandler.EmitDefaults, optionEmitDefaults)
+	assert.Equal(t, httphandler.OrigName, optionOrigName)
+	assert.Equal(t, httphandler.EnumsAsInts, optionEnumsAsInts)
+	assert.Equal(t, httphandler.ETag, optionETag)
+	assert.Equal(t, httphandler.ReadTimeout, optionReadTimeout)
+	assert.Equal(t, httphandler.WriteTimeout, optionWriteTimeout)
+	assert.Equal(t, httphandler.Deprecated, optionDeprecated)
+	assert.Equal(t, handlers.CacheOption, optionCache)
+	assert.Equal(t, httphandler.ContentTypeJSON, contentTypeJSON)
+	assert.Equal(t, httphandler.ContentTypeProtobuf, contentTypeProtobuf)
+	assert.Equal(t, httphandler.ContentTypeNDJSON, contentTypeNDJSON)
+	assert.Equal(t, httphandler.ContentTypeProtoStream, contentTypeProtoStream)
+	assert.Equal(t, httphandler.GRPCStatusHeader, grpcStatusHeader)
+}
@@ -0,0 +1,321 @@
+package accesslog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// names of the fields that can be logged
+const (
+	FieldTime      = "time"
+	FieldTransport = "transport"
+	FieldMethod    = "method"
+	FieldRoute     = "route"
+	FieldPath      = "path"
+	FieldStatus    = "status"
+	FieldCode      = "code"
+	FieldBytesIn   = "bytes_in"
+	FieldBytesOut  = "bytes_out"
+	FieldClientIP  = "client_ip"
+	FieldUserAgent = "user_agent"
+	FieldTraceID   = "trace_id"
+	FieldPrincipal = "principal"
+	FieldDuration  = "duration"
+	FieldError     = "error"
+)
+
+type contextKey string
+
+var (
+	entryKey contextKey = "OrionAccessLogEntry"
+
+	// DefaultFields are the fields logged when none are configured
+	DefaultFields = []string{FieldTime, FieldTransport, FieldMethod, FieldRoute, FieldPath, FieldStatus, FieldCode,
+		FieldBytesIn, FieldBytesOut, FieldClientIP, FieldUserAgent, FieldTraceID, FieldPrincipal, FieldDuration, FieldError}
+
+	defaultLogger atomic.Value
+)
+
+//Entry is a single access log record
+type Entry struct {
+	Time      time.Time
+	Transport string
+	// Method is the HTTP method, it is empty for gRPC calls
+	Method string
+	// Route is the route template of HTTP requests or the full method of gRPC calls
+	Route    string
+	Path     string
+	Status   int
+	Code     string
+	BytesIn  int64
+	BytesOut int64
+	ClientIP string
+	// UserAgent is the user agent of the client
+	UserAgent string
+	TraceID   string
+	Principal string
+	Duration  time.Duration
+	Error     error
+}
+
+//IsError checks if the entry records a failed request
+func (e *Entry) IsError() bool {
+	return e.Error != nil || e.Status >= http.StatusBadRequest || (e.Code != "" && e.Code != "OK")
+}
+
+func (e *Entry) value(field string) interface{} {
+	switch field {
+	case FieldTime:
+		return e.Time.UTC().Format(time.RFC3339Nano)
+	case FieldTransport:
+		return e.Transport
+	case FieldMethod:
+		return e.Method
+	case FieldRoute:
+		return e.Route
+	case FieldPath:
+		return e.Path
+	case FieldStatus:
+		return e.Status
+	case FieldCode:
+		return e.Code
+	case FieldBytesIn:
+		return e.BytesIn
+	case FieldBytesOut:
+		return e.BytesOut
+	case FieldClientIP:
+		return e.ClientIP
+	case FieldUserAgent:
+		return e.UserAgent
+	case FieldTraceID:
+		return e.TraceID
+	case FieldPrincipal:
+		return e.Principal
+	case FieldDuration:
+		return e.Duration.Seconds()
+	case FieldError:
+		if e.Error != nil {
+			return e.Error.Error()
+		}
+	}
+	return nil
+}
+
+//NewContext stores the entry of the current request in the context so that it can be annotated by handlers
+func NewContext(ctx context.Context, e *Entry) context.Context {
+	return context.WithValue(ctx, entryKey, e)
+}
+
+//FromContext fetches the entry of the current request, it returns nil when access logging is disabled
+func FromContext(ctx context.Context) *Entry {
+	if e, ok := ctx.Value(entryKey).(*Entry); ok {
+		return e
+	}
+	return nil
+}
+
+//Sampler decides which entries are written
+type Sampler interface {
+	Sample(e *Entry) bool
+}
+
+//SamplerFunc is an adapter to use functions as Sampler
+type SamplerFunc func(e *Entry) bool
+
+//Sample calls f(e)
+func (f SamplerFunc) Sample(e *Entry) bool {
+	return f(e)
+}
+
+//ErrorsAndRate samples all failed requests and the given ratio of successful requests, e.g. 0.01 for 1%
+func ErrorsAndRate(rate float64) Sampler {
+	return SamplerFunc(func(e *Entry) bool {
+		return e.IsError() || rate >= 1 || (rate > 0 && rand.Float64() < rate)
+	})
+}
+
+//Sink writes access log entries
+type Sink interface {
+	Write(e *Entry) error
+}
+
+type jsonSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	fields []string
+}
+
+//NewJSONSink creates a Sink that writes the selected fields of each entry as a JSON line, empty fields are omitted
+func NewJSONSink(w io.Writer, fields []string) Sink {
+	if len(fields) == 0 {
+		fields = DefaultFields
+	}
+	return &jsonSink{w: w, fields: fields}
+}
+
+func (j *jsonSink) Write(e *Entry) error {
+	record := make(map[string]interface{}, len(j.fields))
+	for _, field := range j.fields {
+		if value := e.value(strings.ToLower(strings.TrimSpace(field))); value != nil && value != "" {
+			record[field] = value
+		}
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.w.Write(append(data, '\n'))
+	return err
+}
+
+//Logger samples and writes access log entries
+type Logger struct {
+	Sink    Sink
+	Sampler Sampler
+	// TrustedProxies are the networks of the proxies whose X-Forwarded-For and X-Real-IP headers are trusted
+	TrustedProxies []*net.IPNet
+}
+
+//Log writes the entry if it is sampled
+func (l *Logger) Log(e *Entry) error {
+	if l == nil || l.Sink == nil {
+		return nil
+	}
+	if l.Sampler != nil && !l.Sampler.Sample(e) {
+		return nil
+	}
+	return l.Sink.Write(e)
+}
+
+//SetDefault sets the logger used by Orion handlers and interceptors, nil disables access logging
+func SetDefault(l *Logger) {
+	defaultLogger.Store(&l)
+}
+
+//Default returns the logger used by Orion handlers and interceptors
+func Default() *Logger {
+	if l, ok := defaultLogger.Load().(**Logger); ok {
+		return *l
+	}
+	return nil
+}
+
+//Enabled checks if access logging is enabled
+func Enabled() bool {
+	return Default() != nil
+}
+
+//Log writes the entry using the default logger
+func Log(e *Entry) error {
+	return Default().Log(e)
+}
+
+// ClientIP returns the address of the client, X-Forwarded-For and X-Real-IP are only used when the request comes from
+// one of the trusted proxies, the client is then the rightmost address of X-Forwarded-For that is not a trusted proxy
+func ClientIP(hdr http.Header, remoteAddr string, trustedProxies []*net.IPNet) string {
+	ip := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		ip = host
+	}
+	if !trusted(ip, trustedProxies) {
+		return ip
+	}
+	hops := make([]string, 0)
+	for _, value := range hdr["X-Forwarded-For"] {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	if len(hops) == 0 {
+		if realIP := strings.TrimSpace(hdr.Get("X-Real-IP")); realIP != "" {
+			return realIP
+		}
+		return ip
+	}
+	// addresses left of an untrusted hop could have been sent by the client
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip = hops[i]
+		if !trusted(ip, trustedProxies) {
+			break
+		}
+	}
+	return ip
+}
+
+// trusted checks if ip belongs to one of the networks
+func trusted(ip string, networks []*net.IPNet) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseNetworks parses IP addresses and CIDR networks like '10.0.0.1' or '10.0.0.0/8'
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %s", value)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			value = fmt.Sprintf("%s/%d", value, bits)
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %s", value)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+//TraceID returns the trace id of the span in the context, it is empty when there is no span
+func TraceID(ctx context.Context) string {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return ""
+	}
+	carrier := opentracing.TextMapCarrier{}
+	if err := span.Tracer().Inject(span.Context(), opentracing.TextMap, carrier); err != nil {
+		return ""
+	}
+	for key, value := range carrier {
+		switch strings.ToLower(key) {
+		case "x-b3-traceid", "traceparent":
+			return value
+		case "uber-trace-id":
+			return strings.Split(value, ":")[0]
+		}
+	}
+	return ""
+}
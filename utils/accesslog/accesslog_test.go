@@ -0,0 +1,85 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONSink(t *testing.T) {
+	buf := new(bytes.Buffer)
+	sink := NewJSONSink(buf, []string{FieldRoute, FieldStatus, FieldPrincipal})
+	err := sink.Write(&Entry{Route: "/api/{id}", Status: 200, UserAgent: "test"})
+	assert.NoError(t, err)
+
+	record := make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "/api/{id}", record[FieldRoute])
+	assert.Equal(t, 200.0, record[FieldStatus])
+	_, found := record[FieldUserAgent]
+	assert.False(t, found, "unselected field should NOT be written")
+	_, found = record[FieldPrincipal]
+	assert.False(t, found, "empty field should NOT be written")
+}
+
+func TestErrorsAndRate(t *testing.T) {
+	sampler := ErrorsAndRate(0)
+	assert.True(t, sampler.Sample(&Entry{Status: 500}), "errors should be sampled")
+	assert.True(t, sampler.Sample(&Entry{Code: "Internal"}), "errors should be sampled")
+	assert.True(t, sampler.Sample(&Entry{Error: errors.New("failed")}), "errors should be sampled")
+	assert.False(t, sampler.Sample(&Entry{Status: 200, Code: "OK"}), "successes should NOT be sampled")
+	assert.True(t, ErrorsAndRate(1).Sample(&Entry{Status: 200}), "successes should be sampled")
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseNetworks([]string{"10.0.0.0/24", "192.168.1.1", "::1"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		realIP     string
+		ip         string
+	}{
+		{"no headers", "10.0.0.1:1234", nil, "", "10.0.0.1"},
+		{"untrusted remote", "172.16.0.1:1234", []string{"10.0.0.3"}, "10.0.0.2", "172.16.0.1"},
+		{"real ip", "10.0.0.1:1234", nil, "172.16.0.2", "172.16.0.2"},
+		{"rightmost untrusted hop", "10.0.0.1:1234", []string{"1.1.1.1, 172.16.0.3, 192.168.1.1"}, "172.16.0.2", "172.16.0.3"},
+		{"several headers", "[::1]:1234", []string{"1.1.1.1, 172.16.0.3", "10.0.0.5"}, "", "172.16.0.3"},
+		{"all hops trusted", "10.0.0.1:1234", []string{"10.0.0.4, 10.0.0.5"}, "", "10.0.0.4"},
+		{"no port", "10.0.0.1", []string{"172.16.0.3"}, "", "172.16.0.3"},
+	}
+	for _, test := range tests {
+		hdr := http.Header{}
+		for _, value := range test.xff {
+			hdr.Add("X-Forwarded-For", value)
+		}
+		if test.realIP != "" {
+			hdr.Set("X-Real-IP", test.realIP)
+		}
+		assert.Equal(t, test.ip, ClientIP(hdr, test.remoteAddr, proxies), test.name)
+	}
+
+	// forwarding headers are ignored without trusted proxies
+	hdr := http.Header{"X-Forwarded-For": {"172.16.0.3"}, "X-Real-Ip": {"172.16.0.2"}}
+	assert.Equal(t, "10.0.0.1", ClientIP(hdr, "10.0.0.1:1234", nil))
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks([]string{"10.0.0.0/8", " 127.0.0.1 ", "", "::1"})
+	if assert.NoError(t, err) && assert.Len(t, networks, 3) {
+		assert.Equal(t, "10.0.0.0/8", networks[0].String())
+		assert.Equal(t, "127.0.0.1/32", networks[1].String())
+		assert.Equal(t, "::1/128", networks[2].String())
+	}
+	_, err = ParseNetworks([]string{"localhost"})
+	assert.Error(t, err)
+	_, err = ParseNetworks([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+//Store is the interface implemented by response cache backends
+type Store interface {
+	//Get fetches the value stored for key, found is false for missing or expired keys
+	Get(ctx context.Context, key string) (value []byte, found bool, err error)
+	//Set stores the value for key, it expires after ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	//Delete removes the value stored for key
+	Delete(ctx context.Context, key string) error
+}
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+type lruStore struct {
+	mu    sync.Mutex
+	size  int
+	items map[string]*list.Element
+	order *list.List
+}
+
+//NewLRUStore creates an in-memory Store that holds at most size entries, evicting the least recently used
+func NewLRUStore(size int) Store {
+	if size <= 0 {
+		size = 1
+	}
+	return &lruStore{
+		size:  size,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+func (l *lruStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := elem.Value.(*entry)
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		l.remove(elem)
+		return nil, false, nil
+	}
+	l.order.MoveToFront(elem)
+	return e.value, true, nil
+}
+
+func (l *lruStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	if elem, ok := l.items[key]; ok {
+		e := elem.Value.(*entry)
+		e.value, e.expires = value, expires
+		l.order.MoveToFront(elem)
+		return nil
+	}
+	l.items[key] = l.order.PushFront(&entry{key: key, value: value, expires: expires})
+	for l.order.Len() > l.size {
+		l.remove(l.order.Back())
+	}
+	return nil
+}
+
+func (l *lruStore) Delete(ctx context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.items[key]; ok {
+		l.remove(elem)
+	}
+	return nil
+}
+
+func (l *lruStore) remove(elem *list.Element) {
+	l.order.Remove(elem)
+	delete(l.items, elem.Value.(*entry).key)
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewLRUStore(2)
+
+	store.Set(ctx, "a", []byte("1"), 0)
+	store.Set(ctx, "b", []byte("2"), 0)
+	// access a so that b is the least recently used
+	value, found, err := store.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, found, "key should be found")
+	assert.Equal(t, []byte("1"), value)
+
+	store.Set(ctx, "c", []byte("3"), 0)
+	_, found, _ = store.Get(ctx, "b")
+	assert.False(t, found, "least recently used key should be evicted")
+	_, found, _ = store.Get(ctx, "a")
+	assert.True(t, found, "key should be found")
+
+	store.Delete(ctx, "a")
+	_, found, _ = store.Get(ctx, "a")
+	assert.False(t, found, "deleted key should NOT be found")
+}
+
+func TestLRUStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	store := NewLRUStore(10)
+	store.Set(ctx, "a", []byte("1"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	_, found, _ := store.Get(ctx, "a")
+	assert.False(t, found, "expired key should NOT be found")
+}
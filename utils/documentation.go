@@ -13,3 +13,5 @@ package utils
 //go:generate godoc2ghmd -ex -file=options/README.md github.com/go-orion/Orion/utils/options
 //go:generate godoc2ghmd -ex -file=pubsub/README.md github.com/go-orion/Orion/utils/pubsub
 //go:generate godoc2ghmd -ex -file=log/README.md github.com/go-orion/Orion/utils/log
+//go:generate godoc2ghmd -ex -file=cache/README.md github.com/go-orion/Orion/utils/cache
+//go:generate godoc2ghmd -ex -file=accesslog/README.md github.com/go-orion/Orion/utils/accesslog